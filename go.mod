@@ -4,15 +4,12 @@ go 1.26
 
 require (
 	github.com/alitto/pond v1.9.2
-	github.com/charlievieth/fastwalk v1.0.14
 	github.com/google/go-cmp v0.7.0
 	github.com/zeebo/xxh3 v1.1.0
 	golang.org/x/mod v0.36.0
 	golang.org/x/sync v0.20.0
+	golang.org/x/sys v0.44.0
 	golang.org/x/tools v0.45.0
 )
 
-require (
-	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
-	golang.org/x/sys v0.44.0 // indirect
-)
+require github.com/klauspost/cpuid/v2 v2.3.0 // indirect
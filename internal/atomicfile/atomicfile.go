@@ -0,0 +1,102 @@
+// Package atomicfile replaces files without exposing partially written
+// content to readers or to interrupted runs.
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const tempPattern = ".goimports-rereviser-*.tmp"
+
+// preservedModeBits are the mode bits copied from the file being replaced.
+const preservedModeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// WriteFile writes data to name like os.WriteFile, but through a temp file in
+// the same directory that is renamed over name, so name always holds either
+// the old or the new content.
+//
+// When name exists its mode bits and, where the platform and privileges allow
+// it, its owner are preserved; perm is only used for new files. When name is a
+// symlink the link itself is kept and its final target is replaced; dangling
+// links are reported as errors. As with any rename-based write, other hard
+// links to name keep the old content.
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	target, err := resolveTarget(name)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(target)
+	switch {
+	case err == nil:
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", target)
+		}
+		perm = info.Mode() & preservedModeBits
+	case errors.Is(err, fs.ErrNotExist):
+		info = nil
+	default:
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), tempPattern)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if info != nil {
+		if err := chown(tmp, info); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	// Chmod after chown, which may clear the setuid and setgid bits.
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		return err
+	}
+	cleanup = false
+	return nil
+}
+
+// resolveTarget returns the file that WriteFile replaces for name: name
+// itself, or the final target when name is a symlink.
+func resolveTarget(name string) (string, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return name, nil
+		}
+		return "", err
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return name, nil
+	}
+
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve symlink %s: %w", name, err)
+	}
+	return target, nil
+}
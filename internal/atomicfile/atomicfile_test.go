@@ -0,0 +1,141 @@
+package atomicfile
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteFilePreservesMode(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []fs.FileMode{0o600, 0o755} {
+		path := filepath.Join(t.TempDir(), "file.go")
+		if err := os.WriteFile(path, []byte("old"), mode); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("failed to chmod fixture: %v", err)
+		}
+
+		if err := WriteFile(path, []byte("new"), 0o644); err != nil {
+			t.Fatalf("WriteFile returned error: %v", err)
+		}
+
+		assertContent(t, path, "new")
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat file: %v", err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != mode {
+			t.Fatalf("mode = %v, want %v", info.Mode().Perm(), mode)
+		}
+		assertNoTempFiles(t, filepath.Dir(path))
+	}
+}
+
+func TestWriteFileCreatesMissingFileWithPerm(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "file.go")
+	if err := WriteFile(path, []byte("new"), 0o640); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	assertContent(t, path, "new")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+		t.Fatalf("mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0o640))
+	}
+}
+
+func TestWriteFileReplacesSymlinkTarget(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	targetDir := filepath.Join(dir, "target")
+	if err := os.Mkdir(targetDir, 0o755); err != nil {
+		t.Fatalf("failed to create target dir: %v", err)
+	}
+	target := filepath.Join(targetDir, "file.go")
+	if err := os.WriteFile(target, []byte("old"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	link := filepath.Join(dir, "link.go")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	if err := WriteFile(link, []byte("new"), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("failed to lstat link: %v", err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("expected %s to remain a symlink, got mode %v", link, info.Mode())
+	}
+	assertContent(t, target, "new")
+	assertNoTempFiles(t, dir)
+	assertNoTempFiles(t, targetDir)
+}
+
+func TestWriteFileRejectsDanglingSymlink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link.go")
+	if err := os.Symlink(filepath.Join(dir, "missing.go"), link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	err := WriteFile(link, []byte("new"), 0o644)
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve symlink") {
+		t.Fatalf("expected symlink resolution error, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "missing.go")); statErr == nil {
+		t.Fatalf("expected dangling symlink target not to be created")
+	}
+}
+
+func TestWriteFileRejectsNonRegularFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := WriteFile(dir, []byte("new"), 0o644); err == nil {
+		t.Fatalf("expected error when writing over a directory")
+	}
+	assertNoTempFiles(t, filepath.Dir(dir))
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if string(got) != want {
+		t.Fatalf("content of %s = %q, want %q", path, got, want)
+	}
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, tempPattern))
+	if err != nil {
+		t.Fatalf("failed to glob temp files: %v", err)
+	}
+	if len(matches) > 0 {
+		t.Fatalf("temp files left behind: %v", matches)
+	}
+}
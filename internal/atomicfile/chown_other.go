@@ -0,0 +1,13 @@
+//go:build !unix
+
+package atomicfile
+
+import (
+	"io/fs"
+	"os"
+)
+
+// chown is a no-op on platforms without Unix file ownership.
+func chown(*os.File, fs.FileInfo) error {
+	return nil
+}
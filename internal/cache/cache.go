@@ -11,9 +11,9 @@ import (
 	"path/filepath"
 
 	"github.com/zeebo/xxh3"
-)
 
-const cacheTempPattern = ".goimports-rereviser-*"
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
+)
 
 const (
 	cacheDirPerm  fs.FileMode = 0o700
@@ -119,45 +119,6 @@ func EnsureCacheDir(cacheDir string) error {
 	return nil
 }
 
-// writeFileAtomic writes payload to a sibling temp file and renames it over
-// path so readers never observe a partially-written file. It is name-atomic
-// but not crash-durable: there is no fsync of either the file or its parent
-// directory, so a power loss between rename and the next fs flush can leave
-// path missing or with the old contents. This is intentional for a cache
-// where a missing entry is harmless (the formatter just re-processes the
-// file on the next run) and the durability cost is not worth paying.
-func writeFileAtomic(path string, payload []byte) error {
-	dir := filepath.Dir(path)
-	tmp, err := os.CreateTemp(dir, cacheTempPattern)
-	if err != nil {
-		return err
-	}
-	tmpName := tmp.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpName)
-		}
-	}()
-
-	if err := tmp.Chmod(cacheFilePerm); err != nil {
-		_ = tmp.Close()
-		return err
-	}
-	if _, err := tmp.Write(payload); err != nil {
-		_ = tmp.Close()
-		return err
-	}
-	if err := tmp.Close(); err != nil {
-		return err
-	}
-	if err := os.Rename(tmpName, path); err != nil {
-		return err
-	}
-	cleanup = false
-	return nil
-}
-
 // writeCacheEntry persists the given entry. When Size/ModTime are zero the
 // entry is stored in the legacy hash-only format to stay backward compatible
 // and minimize file size.
@@ -170,13 +131,13 @@ func writeCacheEntry(cacheDir, absPath string, entry CacheEntry) error {
 	}
 	cacheFile := cacheFilePath(cacheDir, absPath)
 	if entry.Size == 0 && entry.ModTime == 0 && entry.Fingerprint == "" {
-		return writeFileAtomic(cacheFile, []byte(entry.Hash))
+		return atomicfile.WriteFile(cacheFile, []byte(entry.Hash), cacheFilePerm)
 	}
 	payload, err := json.Marshal(entry)
 	if err != nil {
 		return err
 	}
-	return writeFileAtomic(cacheFile, payload)
+	return atomicfile.WriteFile(cacheFile, payload, cacheFilePerm)
 }
 
 func fileMetadata(path string) (size, modTime int64, err error) {
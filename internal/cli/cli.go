@@ -7,20 +7,36 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
+	"syscall"
 
 	"github.com/alitto/pond"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
 	internalcache "github.com/zchee/goimports-rereviser/v4/internal/cache"
+	"github.com/zchee/goimports-rereviser/v4/internal/diff"
 	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
 	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+	"github.com/zchee/goimports-rereviser/v4/internal/pkgdeps"
 	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+	"github.com/zchee/goimports-rereviser/v4/pkg/std"
 )
 
-const cacheDirName = "goimports-rereviser"
+const (
+	cacheDirName = "goimports-rereviser"
+	// pkgdepsCacheDirName is the subdirectory of the cache directory holding
+	// package information persisted by pkgdeps.
+	pkgdepsCacheDirName = "pkgdeps"
+	// stdCacheDirName is the subdirectory of the cache directory holding the
+	// std packages of Go toolchains resolved by std.UseActiveToolchain.
+	stdCacheDirName = "std"
+)
 
 var writeCacheEntry = internalcache.WriteCacheEntry
 
@@ -34,11 +50,21 @@ type VersionInfo struct {
 
 // Config holds goimports-rereviser configuration options.
 type Config struct {
+	configPath         string
 	projectName        string
 	companyPkgPrefixes string
 	output             string
 	excludes           string
+	includes           string
 	importsOrder       string
+	importGroups       string
+	importAliases      string
+	importRewrites     string
+	importRules        string
+	report             string
+	gitDiff            string
+	daemonSocket       string
+	maxDepth           int
 
 	shouldShowVersionOnly bool
 	shouldShowVersion     bool
@@ -46,24 +72,36 @@ type Config struct {
 	listFileName     bool
 	setExitStatus    bool
 	isRecursive      bool
+	followSymlinks   bool
 	isUseCache       bool
 	useMetadataCache bool
+	staged           bool
+	noDaemon         bool
+	watch            bool
+	preload          bool
 
 	shouldRemoveUnusedImports   bool
+	shouldTypeCheckUnused       bool
+	shouldAddMissingImports     bool
 	shouldSetAlias              bool
+	shouldRemoveRedundantAlias  bool
 	shouldFormat                bool
 	shouldSeparateNamedImports  bool
 	shouldSkipBlanked           bool
 	shouldApplyToGeneratedFiles bool
+	shouldGroupWorkspace        bool
 }
 
 var cfg = Config{}
 
 func init() {
-	flag.StringVar(&cfg.projectName, "project-name", "", `Your project name(ex.: github.com/zchee/goimports-rereviser). Optional parameter.`)
+	flag.StringVar(&cfg.configPath, "config", "", `Path to a JSON configuration file whose keys are option names, e.g. {"rm-unused": true, "excludes": [".git/", "proto/*.go"]}. By default '`+configFileName+`' is searched from the first target path upwards to the root of its module, or of its workspace. Options given on the command line take precedence. Optional parameter.`)
+	flag.StringVar(&cfg.projectName, "project-name", "", `Your project name(ex.: github.com/zchee/goimports-rereviser). Files of modules nested in a target directory use the path of their own module. Optional parameter.`)
 	flag.StringVar(&cfg.companyPkgPrefixes, "company-prefixes", "", `Company package prefixes which will be placed after 3rd-party group by default(if defined). Values should be comma-separated. Optional parameters.`)
-	flag.StringVar(&cfg.output, "output", "file", `Can be "file", "write" or "stdout". Whether to write the formatted content back to the file or to stdout. When "write" together with "-list-diff" will list the file name and write back to the file. Optional parameter.`)
+	flag.StringVar(&cfg.output, "output", "file", `Can be "file", "write", "stdout" or "diff". Whether to write the formatted content back to the file or to stdout. When "write" together with "-list-diff" will list the file name and write back to the file. When "diff" will print a unified diff of every changed file without writing it. Optional parameter.`)
+	flag.StringVar(&cfg.report, "report", "", `Can be "json". Print a machine-readable report of every processed file, with the import changes applied to it, followed by summary counts, to stdout. Cannot be used with -output stdout, -output diff or -list-diff. Optional parameter.`)
 	flag.StringVar(&cfg.excludes, "excludes", "", `Exclude files or dirs, example: '.git/,proto/*.go'.`)
+	flag.StringVar(&cfg.includes, "includes", "", `Only process Go files that match one of these patterns or lie in a dir that matches one, example: 'internal/,cmd/*/main.go'. Patterns have the syntax of '-excludes', which still apply. Optional parameter.`)
 	flag.StringVar(
 		&cfg.importsOrder, "imports-order", "std,general,company,project", `Your imports groups can be sorted in your way. Optional parameter.
 std - std import group.
@@ -72,16 +110,34 @@ company - inter-org or your company libs(if you set '-company-prefixes'-option,
 project - your local project dependencies.
 blanked - accepted for compatibility and ignored; blank imports are grouped by package path.
 dotted - imports with "." alias.
+workspace - imports of the other modules of the go.work workspace and of modules replaced with local directories, if '-workspace' is set. Without it in the order, they are part of the project group.
+Names of groups defined with '-import-groups' can be placed in the order as well.
 `,
 	)
+	flag.StringVar(&cfg.importGroups, "import-groups", "", `Custom import groups matched by path patterns, example: 'k8s=k8s.io/...,sigs.k8s.io/...;gen=re:/gen/'. Groups are separated by ';' and patterns by ','. A pattern is a 're:' regular expression, a '...' wildcard pattern, a glob or a path prefix. Every group must be placed in '-imports-order'. Optional parameter.`)
+	flag.StringVar(&cfg.importAliases, "aliases", "", `Required import aliases, example: 'corev1=k8s.io/api/core/v1,metav1=k8s.io/apimachinery/pkg/apis/meta/v1'. Imports of these paths are named with the alias and their uses are rewritten; an import already using one of the aliases is renamed after its path. Optional parameter.`)
+	flag.StringVar(&cfg.importRewrites, "rewrite-imports", "", `Import path rewrite rules of the form 'old-prefix => new-prefix [alias]', example: 'github.com/pkg/errors => errors,example.com/lib/v2 => example.com/lib/v3 lib'. A prefix matches whole path elements; the rule with the longest matching prefix is applied and the import keeps its comments. Uses of the package are not rewritten. Optional parameter.`)
+	flag.StringVar(&cfg.importRules, "import-rules", "", `Import rules of the form 'patterns [only patterns] [=> replacement]', separated by ';', example: 'github.com/pkg/errors => errors;internal/db/... only cmd/...;unsafe only pkg/lowlevel/...'. Patterns have the syntax of '-import-groups' and also match paths relative to the project name. Imports matching a rule are banned, or allowed only in the packages after 'only'; a replacement is applied to banned imports. Violations are printed as 'file:line:column' and, unless their replacement is written, set the exit status to 1. Optional parameter.`)
 	flag.BoolVar(&cfg.listFileName, "list-diff", false, `Option will list files whose formatting differs from goimports-reengine. Optional parameter.`)
 	flag.BoolVar(&cfg.setExitStatus, "set-exit-status", false, `set the exit status to 1 if a change is needed/made. Optional parameter.`)
 	flag.BoolVar(&cfg.isRecursive, "recursive", false, `Apply rules recursively if target is a directory. In case of ./... execution will be recursively applied by default. Optional parameter.`)
-	flag.BoolVar(&cfg.isUseCache, "use-cache", false, `Use cache to improve performance. Optional parameter.`)
+	flag.BoolVar(&cfg.followSymlinks, "follow-symlinks", false, `For recursive directory targets, walk symbolic links to directories as well. A directory reachable through several paths, e.g. because of a symlink loop, is processed once. Optional parameter.`)
+	flag.IntVar(&cfg.maxDepth, "max-depth", 0, `For recursive directory targets, the number of directory levels below the target to descend into; 0 means no limit. Optional parameter.`)
+	flag.StringVar(&cfg.gitDiff, "git-diff", "", `Only process Go files that differ from the given git revision, e.g. 'origin/main', including untracked files. Target paths, '-recursive', '-excludes', '-includes' and '-max-depth' still select which of them are processed; without target paths './...' is used. Optional parameter.`)
+	flag.BoolVar(&cfg.staged, "staged", false, `Only process Go files staged in the git index, revising their staged content instead of the work tree, e.g. in a pre-commit hook. Fixed content is written to both the index and the work tree; files that also have unstaged changes are refused. Target paths, '-recursive', '-excludes', '-includes' and '-max-depth' still select which files are processed; without target paths './...' is used. Optional parameter.`)
+	flag.StringVar(&cfg.daemonSocket, "daemon-socket", "", `Unix socket of the daemon started with 'goimports-rereviser daemon'. While a daemon is listening on it, files are revised by the daemon, which keeps package information cached between runs; otherwise they are revised in process. Defaults to '`+filepath.Join("<user cache dir>", cacheDirName, "daemon.sock")+`'. Optional parameter.`)
+	flag.BoolVar(&cfg.noDaemon, "no-daemon", false, `Always revise files in process, even when a daemon is listening. Optional parameter.`)
+	flag.BoolVar(&cfg.watch, "watch", false, `After processing the directory targets, keep watching them and fix every Go file again when it is written. '-recursive', '-excludes', '-includes', '-max-depth' and '-use-cache' apply. Only supported on Linux. Optional parameter.`)
+	flag.BoolVar(&cfg.preload, "preload", false, `For recursive directory targets, load the package information needed by '-rm-unused', '-set-alias' and similar options for the whole tree with a single 'go list ./...' call instead of one call per directory. Optional parameter.`)
+	flag.BoolVar(&cfg.isUseCache, "use-cache", false, `Use cache to improve performance. Unchanged files are skipped, and package information used by '-rm-unused', '-set-alias' and similar options is kept until the go.mod, go.sum, go.work or Go files it was loaded from, including those of locally replaced modules, change. Std packages are taken from the Go toolchain in use rather than the release the tool was built with, and cached per Go version. Optional parameter.`)
 	flag.BoolVar(&cfg.useMetadataCache, "cache-fast-skip", true, `When used with -use-cache, prefer file metadata before hashing unchanged files; disable with -cache-fast-skip=false. Has no effect without -use-cache.`)
 
 	flag.BoolVar(&cfg.shouldRemoveUnusedImports, "rm-unused", false, `Remove unused imports. Optional parameter.`)
+	flag.BoolVar(&cfg.shouldTypeCheckUnused, "typecheck-unused", false, `With '-rm-unused', decide whether an import is used by type-checking its package instead of looking at the syntax only. Slower, but not fooled by shadowed package names or dot imports. Optional parameter.`)
+	flag.BoolVar(&cfg.shouldAddMissingImports, "add-missing", false, `Add imports for packages that are referenced but not imported, like 'strings.Builder'. Candidates are std packages, the packages of the module and of its dependencies; a package must export every name used with it. Optional parameter.`)
 	flag.BoolVar(&cfg.shouldSetAlias, "set-alias", false, `Set alias for versioned package names, like 'github.com/go-pg/pg/v9'. In this case import will be set as 'pg \"github.com/go-pg/pg/v9\"'. Optional parameter.`)
+	flag.BoolVar(&cfg.shouldRemoveRedundantAlias, "rm-redundant-alias", false, `Remove import aliases that equal the package name, like 'errors "errors"'. Optional parameter.`)
+	flag.BoolVar(&cfg.shouldGroupWorkspace, "workspace", false, `Treat the modules used by the go.work file governing a file, and the modules replaced with local directories in go.mod or go.work, as part of the project, or as the 'workspace' group if it is placed in '-imports-order'. Optional parameter.`)
 	flag.BoolVar(&cfg.shouldFormat, "format", false, `Option will perform additional formatting. Optional parameter.`)
 	flag.BoolVar(&cfg.shouldSeparateNamedImports, "separate-named", false, `Option will separate named imports from the rest of the imports, per group. Optional parameter.`)
 	flag.BoolVar(&cfg.shouldSkipBlanked, "skip-blanked", false, `Option will keep side-effect blank imports ('_ "path"') sorted inline within their package-path group instead of separating them into a trailing sub-block. Optional parameter.`)
@@ -101,6 +157,13 @@ const (
 
 // Run executes the goimports-rereviser CLI and returns a process exit code.
 func Run(version VersionInfo) int {
+	if len(os.Args) > 1 && os.Args[1] == lspCommand {
+		return runLSP(version, os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == daemonCommand {
+		return runDaemon(os.Args[2:])
+	}
+
 	flag.Parse()
 
 	if cfg.shouldShowVersionOnly {
@@ -111,46 +174,29 @@ func Run(version VersionInfo) int {
 		return printVersion(version)
 	}
 
-	originPaths := flag.Args()
-	if len(originPaths) == 0 {
-		return printUsageAndExit(errors.New("no file(s) or directory(ies) specified on input"))
-	}
-
-	if len(originPaths) == 1 && originPaths[0] == "-" {
-		originPaths[0] = engine.StandardInput
-		if err := validateRequiredParam(originPaths[0]); err != nil {
-			return printUsageAndExit(err)
-		}
+	originPaths, err := targetPaths(flag.CommandLine, &cfg, flag.Args())
+	if err != nil {
+		return printUsageAndExit(err)
 	}
 
-	var opts engine.SourceFileOptions
-	if cfg.importsOrder != "" {
-		order, err := engine.StringToImportsOrders(cfg.importsOrder)
-		if err != nil {
-			return printUsageAndExit(err)
-		}
-		opts = append(opts, engine.WithImportsOrder(order))
-	}
-	if cfg.shouldRemoveUnusedImports {
-		opts = append(opts, engine.WithRemovingUnusedImports)
+	if err := validateReport(&cfg); err != nil {
+		return printUsageAndExit(err)
 	}
-	if cfg.shouldSetAlias {
-		opts = append(opts, engine.WithUsingAliasForVersionSuffix)
+	if cfg.gitDiff != "" && cfg.staged {
+		return printUsageAndExit(errors.New("-git-diff and -staged cannot be used together"))
 	}
-	if cfg.shouldFormat {
-		opts = append(opts, engine.WithCodeFormatting)
+	if cfg.maxDepth < 0 {
+		return printUsageAndExit(fmt.Errorf("invalid max depth %d specified", cfg.maxDepth))
 	}
-	if cfg.shouldSeparateNamedImports {
-		opts = append(opts, engine.WithSeparatedNamedImports)
-	}
-	if cfg.shouldSkipBlanked {
-		opts = append(opts, engine.WithSkipBlanked)
-	}
-	if !cfg.shouldApplyToGeneratedFiles {
-		opts = append(opts, engine.WithSkipGeneratedFile)
+	if cfg.watch {
+		if err := validateWatch(&cfg, originPaths); err != nil {
+			return printUsageAndExit(err)
+		}
 	}
-	if cfg.companyPkgPrefixes != "" {
-		opts = append(opts, engine.WithCompanyPackagePrefixes(cfg.companyPkgPrefixes))
+
+	opts, err := sourceFileOptions(&cfg)
+	if err != nil {
+		return printUsageAndExit(err)
 	}
 
 	slog.Info("paths", "paths", originPaths)
@@ -168,16 +214,67 @@ func Run(version VersionInfo) int {
 			slog.Error("failed to create cache directory", "err", err)
 			return exitError
 		}
+		pkgdeps.SetCacheDir(filepath.Join(cacheDir, pkgdepsCacheDirName))
+		// Resolving the std packages of the toolchain in use pays off once
+		// it is persisted; otherwise the generated list is used.
+		std.UseActiveToolchain(filepath.Join(cacheDir, stdCacheDirName))
 	}
 
-	ctx, cancel := context.WithCancelCause(context.Background())
+	// Interrupts cancel the run instead of killing the process, so files are
+	// never left partially written. Once canceled the default behavior is
+	// restored, and a second interrupt terminates immediately.
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(signalCtx, stop)
+
+	ctx, cancel := context.WithCancelCause(signalCtx)
 	defer cancel(context.Canceled)
 
-	hasChange, err := processPaths(ctx, &cfg, originPaths, cacheDir, opts)
+	if cfg.gitDiff != "" {
+		originPaths, err = gitChangedPaths(ctx, &cfg, originPaths)
+		if err != nil {
+			return printUsageAndExit(err)
+		}
+		slog.Info("git changed paths", "paths", originPaths)
+	}
+
+	fix := newFixer(&cfg)
+
+	var hasChange bool
+	if cfg.staged {
+		hasChange, err = processStaged(ctx, &cfg, originPaths, opts, fix)
+	} else {
+		hasChange, err = processPaths(ctx, &cfg, originPaths, cacheDir, opts, fix)
+	}
+	violated := errors.Is(err, errImportViolations)
+	if violated {
+		err = nil
+	}
 	if err != nil {
-		return printUsageAndExit(err)
+		if signalCtx.Err() != nil {
+			slog.Error("interrupted", "err", err)
+			return exitError
+		}
+		if !cfg.watch {
+			return printUsageAndExit(err)
+		}
+		// Files that cannot be fixed yet, e.g. while being edited, must not
+		// prevent watching them.
+		slog.Error("failed to fix files", "err", err)
+	}
+
+	if cfg.watch {
+		if err := watchPaths(ctx, &cfg, originPaths, cacheDir, opts, fix); err != nil && signalCtx.Err() == nil {
+			slog.Error("failed to watch", "err", err)
+			return exitError
+		}
+		return exitSuccess
 	}
 
+	if violated {
+		slog.Info("detect import rule violations")
+		return exitError
+	}
 	if hasChange && cfg.setExitStatus {
 		slog.Info("detect changed files")
 		return exitError
@@ -186,7 +283,92 @@ func Run(version VersionInfo) int {
 	return exitSuccess
 }
 
-func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheDir string, options engine.SourceFileOptions) (bool, error) {
+// sourceFileOptions converts the formatting flags of cfg into engine options.
+func sourceFileOptions(cfg *Config) (engine.SourceFileOptions, error) {
+	var opts engine.SourceFileOptions
+	var importGroups []engine.ImportGroup
+	if cfg.importGroups != "" {
+		groups, err := engine.StringToImportGroups(cfg.importGroups)
+		if err != nil {
+			return nil, err
+		}
+		importGroups = groups
+		opts = append(opts, engine.WithImportGroups(importGroups))
+	}
+	if cfg.importsOrder != "" || len(importGroups) > 0 {
+		order, err := engine.StringToImportsOrdersWithGroups(cfg.importsOrder, importGroups)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithImportsOrder(order))
+	}
+	if cfg.shouldRemoveUnusedImports {
+		opts = append(opts, engine.WithRemovingUnusedImports)
+	}
+	if cfg.shouldTypeCheckUnused {
+		opts = append(opts, engine.WithTypeCheckedUnusedImports)
+	}
+	if cfg.shouldAddMissingImports {
+		opts = append(opts, engine.WithAddingMissingImports)
+	}
+	if cfg.shouldSetAlias {
+		opts = append(opts, engine.WithUsingAliasForVersionSuffix)
+	}
+	if cfg.shouldRemoveRedundantAlias {
+		opts = append(opts, engine.WithRemovingRedundantAliases)
+	}
+	if cfg.importAliases != "" {
+		aliases, err := engine.StringToImportAliases(cfg.importAliases)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithRequiredAliases(aliases))
+	}
+	if cfg.importRewrites != "" {
+		rewrites, err := engine.StringToImportRewrites(cfg.importRewrites)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithImportRewrites(rewrites))
+	}
+	if cfg.importRules != "" {
+		rules, err := engine.StringToImportRules(cfg.importRules)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithImportRules(rules))
+	}
+	if cfg.shouldGroupWorkspace {
+		opts = append(opts, engine.WithWorkspaceModules)
+	}
+	if cfg.shouldFormat {
+		opts = append(opts, engine.WithCodeFormatting)
+	}
+	if cfg.shouldSeparateNamedImports {
+		opts = append(opts, engine.WithSeparatedNamedImports)
+	}
+	if cfg.shouldSkipBlanked {
+		opts = append(opts, engine.WithSkipBlanked)
+	}
+	if !cfg.shouldApplyToGeneratedFiles {
+		opts = append(opts, engine.WithSkipGeneratedFile)
+	}
+	if cfg.companyPkgPrefixes != "" {
+		opts = append(opts, engine.WithCompanyPackagePrefixes(cfg.companyPkgPrefixes))
+	}
+
+	return opts, nil
+}
+
+// processPaths revises the files and directories of originPaths with options,
+// through fix when it is not nil.
+func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheDir string, options engine.SourceFileOptions, fix *fixer) (bool, error) {
+	return processTargets(ctx, cfg, originPaths, nil, cacheDir, options, fix)
+}
+
+// processTargets is like processPaths, but limits the directories of
+// originPaths to files when it is not nil, see engine.SourceDir.WithFiles.
+func processTargets(ctx context.Context, cfg *Config, originPaths, files []string, cacheDir string, options engine.SourceFileOptions, fix *fixer) (bool, error) {
 	select {
 	case <-ctx.Done():
 		return false, ctx.Err()
@@ -213,6 +395,29 @@ func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheD
 		return sharedPool
 	}
 
+	var reports *reportCollector
+	if cfg.report != "" {
+		reports = &reportCollector{}
+	}
+	violations := newViolationPrinter(os.Stderr, cfg)
+	fixFile := violations.wrap(fix.fixFile)
+	newSourceDir := func(projectName, path string) *engine.SourceDir {
+		dir := newTargetDir(cfg, projectName, path).
+			WithWorkerPool(getSharedPool()).
+			WithFixFunc(fixFile)
+		if reports != nil {
+			dir = dir.WithReport(reports.add)
+		}
+		if files != nil {
+			dir = dir.WithFiles(files)
+		}
+		// A daemon keeps package information cached on its own.
+		if cfg.preload && fix == nil && needsPackageInfo(cfg) {
+			dir = dir.WithPackagePreload()
+		}
+		return dir
+	}
+
 	g := &errgroup.Group{}
 	g.SetLimit(runtime.GOMAXPROCS(0))
 
@@ -220,25 +425,47 @@ func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheD
 		pathValue := original
 
 		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			slog.Info("processing path", "path", pathValue)
+			_, isDir := internalwalk.IsDir(pathValue)
 			originProjectName, err := determineProjectName(cfg.projectName, pathValue)
 			if err != nil {
-				return fmt.Errorf("could not determine project name for path %s: %w", pathValue, err)
+				// A directory outside of any module may still contain modules,
+				// whose files the SourceDir classifies against their own module.
+				var undefinedErr *modulepath.UndefinedModuleError
+				if !isDir || !errors.As(err, &undefinedErr) {
+					return fmt.Errorf("could not determine project name for path %s: %w", pathValue, err)
+				}
 			}
 
-			if _, ok := internalwalk.IsDir(pathValue); ok {
-				cacheFingerprint := formatterCacheFingerprint(cfg, originProjectName)
+			if isDir {
+				if cfg.output == "diff" {
+					dir := newSourceDir(originProjectName, pathValue)
+
+					unformattedFiles, err := dir.DiffContext(ctx, options...)
+					if err != nil {
+						return fmt.Errorf("failed to diff unformatted files %s: %w", pathValue, err)
+					}
+					if unformattedFiles != nil {
+						fmt.Print(unformattedFiles.Diff())
+						markChanged()
+					}
+					return nil
+				}
+
 				if cfg.listFileName {
-					dir := engine.NewSourceDir(originProjectName, pathValue, cfg.isRecursive, cfg.excludes).
-						WithWorkerPool(getSharedPool())
+					dir := newSourceDir(originProjectName, pathValue)
 					if cfg.isUseCache && cacheDir != "" {
-						dir = dir.WithCache(cacheDir).WithCacheFingerprint(cacheFingerprint)
+						dir = dir.WithCache(cacheDir).WithCacheFingerprint(formatterCacheFingerprint(ctx, cfg, originProjectName))
 						if !cfg.useMetadataCache {
 							dir = dir.WithoutMetadataCache()
 						}
 					}
 
-					unformattedFiles, err := dir.Find(options...)
+					unformattedFiles, err := dir.FindContext(ctx, options...)
 					if err != nil {
 						return fmt.Errorf("failed to find unformatted files %s: %w", pathValue, err)
 					}
@@ -249,16 +476,15 @@ func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheD
 					return nil
 				}
 
-				dir := engine.NewSourceDir(originProjectName, pathValue, cfg.isRecursive, cfg.excludes).
-					WithWorkerPool(getSharedPool())
+				dir := newSourceDir(originProjectName, pathValue)
 				if cfg.isUseCache && cacheDir != "" {
-					dir = dir.WithCache(cacheDir).WithCacheFingerprint(cacheFingerprint)
+					dir = dir.WithCache(cacheDir).WithCacheFingerprint(formatterCacheFingerprint(ctx, cfg, originProjectName))
 					if !cfg.useMetadataCache {
 						dir = dir.WithoutMetadataCache()
 					}
 				}
 
-				dirHasChange, err := dir.Fix(options...)
+				dirHasChange, err := dir.FixContext(ctx, options...)
 				if dirHasChange {
 					markChanged()
 				}
@@ -276,43 +502,71 @@ func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheD
 				}
 			}
 
-			var (
-				formattedOutput []byte
-				originalContent []byte
-				pathHasChange   bool
-			)
+			fileReport := engine.FileReport{Path: pathToProcess}
+			reportErr := func(err error) error {
+				if reports != nil {
+					fileReport.Error = err.Error()
+					reports.add(fileReport)
+				}
+				return err
+			}
 
 			canReadCache := pathToProcess != engine.StandardInput &&
 				cfg.output != "stdout" &&
+				cfg.output != "diff" &&
 				(!cfg.listFileName || cfg.output == "write")
 			canWriteCache := canReadCache
 
-			cacheFingerprint := formatterCacheFingerprint(cfg, originProjectName)
+			// Building the fingerprint queries the go command, which is only
+			// worth it when the cache is used.
+			var cacheFingerprint string
+			if cfg.isUseCache && cacheDir != "" {
+				cacheFingerprint = fileCacheFingerprint(ctx, cfg, originProjectName, pathToProcess)
+			}
 
 			if cfg.isUseCache && cacheDir != "" && canReadCache {
 				skip, checkErr := internalcache.ShouldSkipWithFingerprint(cacheDir, pathToProcess, cfg.useMetadataCache, cacheFingerprint)
 				if checkErr != nil {
-					return fmt.Errorf("failed to evaluate cache for %s: %w", pathToProcess, checkErr)
+					return reportErr(fmt.Errorf("failed to evaluate cache for %s: %w", pathToProcess, checkErr))
 				}
 				if skip {
+					if reports != nil {
+						fileReport.CacheHit = true
+						fileReport.Skipped = engine.SkipReasonCacheHit
+						reports.add(fileReport)
+					}
 					return nil
 				}
 			}
 
-			formattedOutput, originalContent, pathHasChange, err = engine.NewSourceFile(originProjectName, pathToProcess).Fix(options...)
+			result, err := fixFile(ctx, originProjectName, pathToProcess, options...)
 			if err != nil {
-				return fmt.Errorf("failed to fix file %s: %w", pathToProcess, err)
+				return reportErr(fmt.Errorf("failed to fix file %s: %w", pathToProcess, err))
 			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			formattedOutput, originalContent, pathHasChange := result.Content, result.Original, result.Changed
 
 			if pathHasChange {
 				markChanged()
 			}
 
-			if err := resultPostProcess(cfg, pathHasChange, pathToProcess, formattedOutput); err != nil {
-				return err
+			if err := resultPostProcess(cfg, pathHasChange, pathToProcess, originalContent, formattedOutput); err != nil {
+				return reportErr(err)
+			}
+
+			if reports != nil {
+				fileReport.Changed = pathHasChange
+				fileReport.Skipped = result.SkipReason
+				fileReport.ImportChanges = result.ImportChanges
+				fileReport.Violations = result.Violations
+				reports.add(fileReport)
 			}
 
-			if cfg.isUseCache && cacheDir != "" && canWriteCache {
+			// Files breaking import rules are not cached, so that every run
+			// reports them.
+			if cfg.isUseCache && cacheDir != "" && canWriteCache && len(result.Violations) == 0 {
 				cacheContent := originalContent
 				if pathHasChange {
 					cacheContent = formattedOutput
@@ -339,11 +593,24 @@ func processPaths(ctx context.Context, cfg *Config, originPaths []string, cacheD
 		sharedPool.StopAndWait()
 	}
 
+	if reports != nil {
+		if writeErr := reports.write(os.Stdout); writeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write report: %w", writeErr)
+		}
+	}
+
 	if err != nil {
 		return hasChange, err
 	}
 
-	return hasChange, nil
+	return hasChange, violations.err()
+}
+
+// needsPackageInfo reports whether the options of cfg load package
+// information with pkgdeps.
+func needsPackageInfo(cfg *Config) bool {
+	return cfg.shouldRemoveUnusedImports || cfg.shouldSetAlias || cfg.shouldAddMissingImports ||
+		cfg.shouldRemoveRedundantAlias || cfg.importAliases != ""
 }
 
 func defaultCacheDir() (string, error) {
@@ -354,23 +621,54 @@ func defaultCacheDir() (string, error) {
 	return filepath.Join(cacheBase, cacheDirName), nil
 }
 
-func formatterCacheFingerprint(cfg *Config, projectName string) string {
+func formatterCacheFingerprint(ctx context.Context, cfg *Config, projectName string) string {
 	return fmt.Sprintf(
-		"v3|project=%s|imports-order=%s|company-prefixes=%s|rm-unused=%t|set-alias=%t|format=%t|separate-named=%t|skip-blanked=%t|apply-generated=%t",
+		"v3|project=%s|imports-order=%s|import-groups=%s|aliases=%s|rewrite-imports=%s|import-rules=%s|company-prefixes=%s|rm-unused=%t|typecheck-unused=%t|add-missing=%t|set-alias=%t|rm-redundant-alias=%t|format=%t|separate-named=%t|skip-blanked=%t|apply-generated=%t|workspace=%t|go-version=%s",
 		projectName,
 		cfg.importsOrder,
+		cfg.importGroups,
+		cfg.importAliases,
+		cfg.importRewrites,
+		cfg.importRules,
 		cfg.companyPkgPrefixes,
 		cfg.shouldRemoveUnusedImports,
+		cfg.shouldTypeCheckUnused,
+		cfg.shouldAddMissingImports,
 		cfg.shouldSetAlias,
+		cfg.shouldRemoveRedundantAlias,
 		cfg.shouldFormat,
 		cfg.shouldSeparateNamedImports,
 		cfg.shouldSkipBlanked,
 		cfg.shouldApplyToGeneratedFiles,
+		cfg.shouldGroupWorkspace,
+		fingerprintGoVersion(ctx),
 	)
 }
 
-func resultPostProcess(cfg *Config, hasChange bool, originFilePath string, formattedOutput []byte) error {
+// fingerprintGoVersion returns the version of the active Go toolchain, whose
+// std packages are grouped as std imports.
+func fingerprintGoVersion(ctx context.Context) string {
+	tc, err := goenv.Active(ctx)
+	if err != nil {
+		return ""
+	}
+	return tc.Version
+}
+
+// fileCacheFingerprint returns the cache fingerprint of the single file at
+// path, extended for its module like SourceDir does for the files it walks.
+func fileCacheFingerprint(ctx context.Context, cfg *Config, projectName, path string) string {
+	root, _ := modulepath.RootOfDir(filepath.Dir(path))
+	return engine.ModuleCacheFingerprint(formatterCacheFingerprint(ctx, cfg, projectName), root)
+}
+
+func resultPostProcess(cfg *Config, hasChange bool, originFilePath string, originalContent, formattedOutput []byte) error {
 	switch {
+	case cfg.output == "diff":
+		if hasChange {
+			fmt.Print(string(diff.Unified(originFilePath+".orig", originFilePath, originalContent, formattedOutput)))
+		}
+
 	case hasChange && cfg.listFileName && cfg.output != "write":
 		fmt.Println(originFilePath)
 
@@ -379,7 +677,7 @@ func resultPostProcess(cfg *Config, hasChange bool, originFilePath string, forma
 
 	case cfg.output == "file" || cfg.output == "write":
 		if hasChange {
-			if err := os.WriteFile(originFilePath, formattedOutput, 0o644); err != nil {
+			if err := atomicfile.WriteFile(originFilePath, formattedOutput, 0o644); err != nil {
 				return fmt.Errorf("failed to write fixed result to file(%s): %w", originFilePath, err)
 			}
 		}
@@ -406,6 +704,47 @@ func validateRequiredParam(filePath string) error {
 	return nil
 }
 
+// targetPaths applies the config file discovered from the first argument, or
+// from the working directory, and returns the paths to process. The config is
+// applied first, since it may select the target mode, e.g. with "staged".
+func targetPaths(flags *flag.FlagSet, cfg *Config, args []string) ([]string, error) {
+	configTarget := "."
+	if len(args) > 0 && args[0] != "-" {
+		configTarget = args[0]
+	}
+	if _, err := loadConfig(flags, cfg.configPath, configTarget); err != nil {
+		return nil, err
+	}
+
+	originPaths := slices.Clone(args)
+	if len(originPaths) == 0 && useGitFiles(cfg) {
+		originPaths = []string{internalwalk.RecursivePath}
+	}
+	if len(originPaths) == 0 {
+		return nil, errors.New("no file(s) or directory(ies) specified on input")
+	}
+
+	if len(originPaths) == 1 && originPaths[0] == "-" {
+		originPaths[0] = engine.StandardInput
+		if err := validateRequiredParam(originPaths[0]); err != nil {
+			return nil, err
+		}
+	}
+	return originPaths, nil
+}
+
+// newTargetDir returns the SourceDir of the directory target path, selecting
+// its files by the recursion, exclude, include, symlink and depth options.
+func newTargetDir(cfg *Config, projectName, path string) *engine.SourceDir {
+	dir := engine.NewSourceDir(projectName, path, cfg.isRecursive, cfg.excludes).
+		WithIncludes(cfg.includes).
+		WithMaxDepth(cfg.maxDepth)
+	if cfg.followSymlinks {
+		dir = dir.WithFollowSymlinks()
+	}
+	return dir
+}
+
 func determineProjectName(projectName, filePath string) (string, error) {
 	if filePath == engine.StandardInput {
 		var err error
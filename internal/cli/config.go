@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+)
+
+const configFileName = ".goimports-rereviser.json"
+
+// configFileIgnoredFlags lists flags that only make sense on the command line.
+var configFileIgnoredFlags = map[string]struct{}{
+	"config":       {},
+	"version":      {},
+	"version-only": {},
+}
+
+// findConfigFile walks up from path looking for configFileName, the same way
+// modulepath.GoModRootPath looks for go.mod, but stops at the root of the
+// module of path, see configSearchRoot, so that a config file of an enclosing
+// project or of the home directory is never applied by accident. It returns an
+// empty string when no config file exists.
+func findConfigFile(path string) (string, error) {
+	if path == engine.StandardInput {
+		var err error
+		path, err = os.Getwd()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	stop := configSearchRoot(dir)
+	for {
+		candidate := filepath.Join(dir, configFileName)
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate, nil
+		}
+
+		d := filepath.Dir(dir)
+		if dir == stop || d == dir {
+			break
+		}
+		dir = d
+	}
+
+	return "", nil
+}
+
+// configSearchRoot returns the last directory searched for a config file of
+// dir: the directory holding the go.work file of its module, or else its
+// go.mod file. Outside of any module only dir itself is searched.
+func configSearchRoot(dir string) string {
+	root, err := modulepath.GoModRootPath(dir)
+	if err != nil || root == "" {
+		return dir
+	}
+	if workFile := modulepath.WorkFilePath(root); workFile != "" {
+		workDir := filepath.Dir(workFile)
+		if workDir == root || strings.HasPrefix(root, workDir+string(filepath.Separator)) {
+			return workDir
+		}
+	}
+	return root
+}
+
+// applyConfigFile loads the JSON object stored at configPath and sets every
+// flag it names on flags, unless the flag was already given on the command line.
+// Keys are flag names; values may be strings, booleans, numbers or arrays of
+// strings, the latter being joined with commas.
+func applyConfigFile(flags *flag.FlagSet, configPath string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+
+	explicit := make(map[string]struct{})
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = struct{}{}
+	})
+
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		raw := values[name]
+		if _, ok := configFileIgnoredFlags[name]; ok || flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in config file %s", name, configPath)
+		}
+		if _, ok := explicit[name]; ok {
+			continue
+		}
+
+		value, err := configValueString(raw)
+		if err != nil {
+			return fmt.Errorf("invalid value for option %q in config file %s: %w", name, configPath, err)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for option %q in config file %s: %w", name, configPath, err)
+		}
+	}
+
+	return nil
+}
+
+func configValueString(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("array items must be strings, got %T", item)
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", raw)
+	}
+}
+
+// loadConfig applies the explicitly requested config file, or the one
+// discovered from target, to flags. It returns the path of the applied file.
+func loadConfig(flags *flag.FlagSet, configPath, target string) (string, error) {
+	if configPath == "" {
+		var err error
+		configPath, err = findConfigFile(target)
+		if err != nil {
+			return "", err
+		}
+		if configPath == "" {
+			return "", nil
+		}
+	}
+
+	if err := applyConfigFile(flags, configPath); err != nil {
+		return "", err
+	}
+	return configPath, nil
+}
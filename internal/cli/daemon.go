@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/daemon"
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+	"github.com/zchee/goimports-rereviser/v4/pkg/std"
+)
+
+const daemonCommand = "daemon"
+
+// runDaemon serves fix requests on the daemon socket until it is interrupted.
+// Formatting flags are ignored: every request carries the options of its
+// client.
+func runDaemon(args []string) int {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return printUsageAndExit(err)
+	}
+	if flag.NArg() > 0 {
+		return printUsageAndExit(errors.New("daemon does not accept file or directory arguments"))
+	}
+
+	socketPath, err := daemonSocketPath(&cfg)
+	if err != nil {
+		slog.Error("failed to get daemon socket path", "err", err)
+		return exitError
+	}
+	version, err := executableVersion()
+	if err != nil {
+		slog.Error("failed to identify executable", "err", err)
+		return exitError
+	}
+
+	std.UseActiveToolchain("")
+
+	ln, err := daemon.Listen(socketPath)
+	if err != nil {
+		slog.Error("failed to listen", "socket", socketPath, "err", err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("daemon listening", "socket", socketPath)
+	if err := daemon.NewServer(handleDaemonRequest, version).Serve(ctx, ln); err != nil {
+		slog.Error("daemon stopped", "err", err)
+		return exitError
+	}
+	return exitSuccess
+}
+
+// handleDaemonRequest revises the content of a request with its options,
+// resolving the project name from the file unless the client sent one.
+func handleDaemonRequest(ctx context.Context, req *daemon.Request) (*daemon.Response, error) {
+	local := configFromDaemonOptions(req.Options)
+	opts, err := sourceFileOptions(&local)
+	if err != nil {
+		return nil, err
+	}
+
+	projectName, err := determineProjectName(req.ProjectName, req.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := engine.NewSourceFileFromBytes(projectName, req.Filename, req.Src).FixResultContext(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &daemon.Response{
+		Content:       result.Content,
+		Changed:       result.Changed,
+		SkipReason:    result.SkipReason,
+		ImportChanges: result.ImportChanges,
+		Violations:    result.Violations,
+	}, nil
+}
+
+func daemonOptions(cfg *Config) daemon.Options {
+	return daemon.Options{
+		ImportsOrder:          cfg.importsOrder,
+		ImportGroups:          cfg.importGroups,
+		ImportAliases:         cfg.importAliases,
+		ImportRewrites:        cfg.importRewrites,
+		ImportRules:           cfg.importRules,
+		CompanyPrefixes:       cfg.companyPkgPrefixes,
+		RemoveUnused:          cfg.shouldRemoveUnusedImports,
+		TypeCheckUnused:       cfg.shouldTypeCheckUnused,
+		AddMissing:            cfg.shouldAddMissingImports,
+		SetAlias:              cfg.shouldSetAlias,
+		RemoveRedundantAlias:  cfg.shouldRemoveRedundantAlias,
+		Format:                cfg.shouldFormat,
+		SeparateNamed:         cfg.shouldSeparateNamedImports,
+		SkipBlanked:           cfg.shouldSkipBlanked,
+		ApplyToGeneratedFiles: cfg.shouldApplyToGeneratedFiles,
+		Workspace:             cfg.shouldGroupWorkspace,
+	}
+}
+
+func configFromDaemonOptions(o daemon.Options) Config {
+	return Config{
+		importsOrder:                o.ImportsOrder,
+		importGroups:                o.ImportGroups,
+		importAliases:               o.ImportAliases,
+		importRewrites:              o.ImportRewrites,
+		importRules:                 o.ImportRules,
+		companyPkgPrefixes:          o.CompanyPrefixes,
+		shouldRemoveUnusedImports:   o.RemoveUnused,
+		shouldTypeCheckUnused:       o.TypeCheckUnused,
+		shouldAddMissingImports:     o.AddMissing,
+		shouldSetAlias:              o.SetAlias,
+		shouldRemoveRedundantAlias:  o.RemoveRedundantAlias,
+		shouldFormat:                o.Format,
+		shouldSeparateNamedImports:  o.SeparateNamed,
+		shouldSkipBlanked:           o.SkipBlanked,
+		shouldApplyToGeneratedFiles: o.ApplyToGeneratedFiles,
+		shouldGroupWorkspace:        o.Workspace,
+	}
+}
+
+func daemonSocketPath(cfg *Config) (string, error) {
+	if cfg.daemonSocket != "" {
+		return filepath.Abs(cfg.daemonSocket)
+	}
+	cacheDir, err := defaultCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, daemon.SocketName), nil
+}
+
+// executableVersion identifies the running executable by its path, size and
+// modification time, so that a daemon left running across an upgrade refuses
+// clients of the new build instead of formatting with old rules.
+var executableVersion = sync.OnceValues(func() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s|%d|%d", path, fi.Size(), fi.ModTime().UnixNano()), nil
+})
+
+// fixer revises files through the daemon. A nil *fixer, and a fixer whose
+// daemon turned out to be unavailable, revise files in process.
+type fixer struct {
+	client      *daemon.Client
+	version     string
+	env         []string
+	dir         string
+	options     daemon.Options
+	unavailable atomic.Bool
+}
+
+// newFixer returns a fixer for the daemon of cfg, or nil when the daemon is
+// disabled or its socket does not exist.
+func newFixer(cfg *Config) *fixer {
+	if cfg.noDaemon {
+		return nil
+	}
+	socketPath, err := daemonSocketPath(cfg)
+	if err != nil {
+		return nil
+	}
+	if _, err := os.Stat(socketPath); err != nil {
+		return nil
+	}
+	version, err := executableVersion()
+	if err != nil {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	slog.Info("using daemon", "socket", socketPath)
+	return &fixer{
+		client:  daemon.NewClient(socketPath),
+		version: version,
+		env:     goenv.Environ(),
+		dir:     dir,
+		options: daemonOptions(cfg),
+	}
+}
+
+// fixFile is an engine.FixFunc that reads filePath, or stdin, and revises it.
+func (f *fixer) fixFile(ctx context.Context, projectName, filePath string, options ...engine.SourceFileOption) (*engine.Result, error) {
+	if f == nil || f.unavailable.Load() {
+		return engine.NewSourceFile(projectName, filePath).FixResultContext(ctx, options...)
+	}
+
+	var (
+		src []byte
+		err error
+	)
+	if filePath == engine.StandardInput {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		return &engine.Result{}, err
+	}
+	return f.fixSource(ctx, projectName, filePath, src, options...)
+}
+
+// fixSource revises src as the content of filename. options must be the
+// options of the Config the fixer was created from; they are used when the
+// file is revised in process.
+func (f *fixer) fixSource(ctx context.Context, projectName, filename string, src []byte, options ...engine.SourceFileOption) (*engine.Result, error) {
+	if f == nil || f.unavailable.Load() {
+		return engine.NewSourceFileFromBytes(projectName, filename, src).FixResultContext(ctx, options...)
+	}
+
+	resp, err := f.client.Fix(ctx, &daemon.Request{
+		Version:     f.version,
+		Env:         f.env,
+		Dir:         f.dir,
+		Filename:    filename,
+		Src:         src,
+		ProjectName: projectName,
+		Options:     f.options,
+	})
+	if errors.Is(err, daemon.ErrUnavailable) {
+		if f.unavailable.CompareAndSwap(false, true) {
+			slog.Warn("daemon is unavailable, fixing files in process", "err", err)
+		}
+		return engine.NewSourceFileFromBytes(projectName, filename, src).FixResultContext(ctx, options...)
+	}
+	if err != nil {
+		return &engine.Result{Content: src, Original: src}, err
+	}
+
+	return &engine.Result{
+		Content:       resp.Content,
+		Original:      src,
+		Changed:       resp.Changed,
+		SkipReason:    resp.SkipReason,
+		ImportChanges: resp.ImportChanges,
+		Violations:    resp.Violations,
+	}, nil
+}
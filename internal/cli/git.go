@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/git"
+	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+)
+
+func useGitFiles(cfg *Config) bool {
+	return cfg.gitDiff != "" || cfg.staged
+}
+
+// gitChangedPaths replaces the target paths with the Go files git reports as
+// changed below them.
+func gitChangedPaths(ctx context.Context, cfg *Config, targets []string) ([]string, error) {
+	files, err := selectGitFiles(cfg, targets, func(dir string) ([]string, error) {
+		return git.ChangedFiles(ctx, dir, cfg.gitDiff)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	return paths, nil
+}
+
+// gitFile is a file reported by git and selected by a target path. path is
+// spelled below the target as given by the user, resolved is the path git
+// reported.
+type gitFile struct {
+	path     string
+	resolved string
+}
+
+// selectGitFiles keeps the files listed by git that the targets select.
+// Directory targets apply the same recursion and exclude rules as a full walk,
+// file targets are kept only when git listed them. list is called with the
+// directory of every target and returns absolute, symlink-free paths.
+func selectGitFiles(cfg *Config, targets []string, list func(dir string) ([]string, error)) ([]gitFile, error) {
+	var (
+		files []gitFile
+		seen  = make(map[string]struct{})
+	)
+	add := func(path, resolved string) {
+		if _, ok := seen[path]; !ok {
+			seen[path] = struct{}{}
+			files = append(files, gitFile{path: path, resolved: resolved})
+		}
+	}
+
+	for _, target := range targets {
+		if target == engine.StandardInput {
+			return nil, errors.New("-git-diff and -staged cannot be used with stdin")
+		}
+
+		dirPath, isDir := internalwalk.IsDir(target)
+		absPath, err := filepath.Abs(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		gitDir := absPath
+		if !isDir {
+			gitDir = filepath.Dir(absPath)
+		}
+
+		changed, err := list(gitDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changed files for %s: %w", target, err)
+		}
+
+		// git reports paths below the resolved work tree, while the user may
+		// have named the target through a symlink.
+		resolvedPath, err := filepath.EvalSymlinks(absPath)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			// Staged files may be gone from the work tree.
+			resolvedPath = absPath
+		}
+
+		if !isDir {
+			if slices.Contains(changed, resolvedPath) {
+				add(absPath, resolvedPath)
+			}
+			continue
+		}
+
+		dir := newTargetDir(cfg, "", target)
+		for _, file := range changed {
+			rel, err := filepath.Rel(resolvedPath, file)
+			if err != nil || !filepath.IsLocal(rel) {
+				continue
+			}
+			if path := filepath.Join(absPath, rel); dir.Contains(path) {
+				add(path, file)
+			}
+		}
+	}
+
+	slices.SortFunc(files, func(a, b gitFile) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return files, nil
+}
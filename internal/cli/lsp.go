@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/lsp"
+	"github.com/zchee/goimports-rereviser/v4/internal/stale"
+	"github.com/zchee/goimports-rereviser/v4/pkg/std"
+)
+
+const lspCommand = "lsp"
+
+// runLSP serves the Language Server Protocol over stdin and stdout. It
+// accepts the formatting flags of the CLI, and the config file discovered from
+// the working directory, and keeps the package and module caches warm for the
+// lifetime of the process.
+func runLSP(version VersionInfo, args []string) int {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return printUsageAndExit(err)
+	}
+	if flag.NArg() > 0 {
+		return printUsageAndExit(errors.New("lsp does not accept file or directory arguments"))
+	}
+
+	if _, err := loadConfig(flag.CommandLine, cfg.configPath, "."); err != nil {
+		return printUsageAndExit(err)
+	}
+
+	opts, err := sourceFileOptions(&cfg)
+	if err != nil {
+		return printUsageAndExit(err)
+	}
+	std.UseActiveToolchain("")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := lsp.NewServer(fixSourceFunc(&cfg, opts), version.Tag)
+	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		slog.Error("lsp server stopped", "err", err)
+		return exitError
+	}
+	return exitSuccess
+}
+
+// fixSourceFunc revises in-memory content with the given options, resolving
+// the project name for every file unless it is configured. Like the daemon, it
+// forgets the cached package and module data of a file whose package or
+// module changed on disk since the previous fix.
+func fixSourceFunc(cfg *Config, opts engine.SourceFileOptions) lsp.FixFunc {
+	tracker := stale.NewTracker()
+	return func(ctx context.Context, filename string, src []byte) ([]byte, error) {
+		tracker.Refresh(filename)
+
+		projectName, err := determineProjectName(cfg.projectName, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := engine.NewSourceFileFromBytes(projectName, filename, src).FixResultContext(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return result.Content, nil
+	}
+}
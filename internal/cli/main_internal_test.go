@@ -2,17 +2,26 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	gocmp "github.com/google/go-cmp/cmp"
+
 	internalcache "github.com/zchee/goimports-rereviser/v4/internal/cache"
+	"github.com/zchee/goimports-rereviser/v4/internal/daemon"
 	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
 )
 
 func TestProcessPathsProcessesEachFile(t *testing.T) {
@@ -46,7 +55,7 @@ func main() {}
 
 	paths := []string{fileA, fileB}
 
-	hasChange, err := processPaths(t.Context(), &cfg, paths, "", nil)
+	hasChange, err := processPaths(t.Context(), &cfg, paths, "", nil, nil)
 	if err != nil {
 		t.Fatalf("processPaths returned error: %v", err)
 	}
@@ -98,7 +107,7 @@ func main() {
 	}
 	t.Cleanup(func() { cfg = origCfg })
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("first processPaths returned error: %v", err)
 	}
@@ -149,7 +158,7 @@ func main() {
 		t.Fatalf("failed to write stable cache entry: %v", err)
 	}
 
-	hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("second processPaths returned error: %v", err)
 	}
@@ -207,7 +216,7 @@ func main() {
 	}
 	t.Cleanup(func() { writeCacheEntry = origWriteCacheEntry })
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("processPaths should ignore cache write failures, got error: %v", err)
 	}
@@ -257,7 +266,7 @@ func main() {
 	t.Cleanup(func() { cfg = origCfg })
 
 	firstStdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 		if err != nil {
 			t.Fatalf("first processPaths returned error: %v", err)
 		}
@@ -266,7 +275,7 @@ func main() {
 		}
 	})
 	secondStdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 		if err != nil {
 			t.Fatalf("second processPaths returned error: %v", err)
 		}
@@ -321,7 +330,7 @@ func main() {
 		useMetadataCache: true,
 	}
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("mutating processPaths returned error: %v", err)
 	}
@@ -339,7 +348,7 @@ func main() {
 	cfg.listFileName = true
 	cfg.shouldSeparateNamedImports = true
 	stdout := captureStdout(t, func() {
-		hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports})
+		hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports}, nil)
 		if err != nil {
 			t.Fatalf("list-diff processPaths returned error: %v", err)
 		}
@@ -382,7 +391,7 @@ func main() {
 		useMetadataCache: true,
 	}
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("default processPaths returned error: %v", err)
 	}
@@ -391,7 +400,7 @@ func main() {
 	}
 
 	cfg.shouldSeparateNamedImports = true
-	hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports})
+	hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports}, nil)
 	if err != nil {
 		t.Fatalf("separate-named processPaths returned error: %v", err)
 	}
@@ -438,7 +447,7 @@ func main() {
 		useMetadataCache: true,
 	}
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 	if err != nil {
 		t.Fatalf("default processPaths returned error: %v", err)
 	}
@@ -450,7 +459,7 @@ func main() {
 	cfg.listFileName = true
 	cfg.shouldSeparateNamedImports = true
 	stdout := captureStdout(t, func() {
-		hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports})
+		hasChange, err = processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, engine.SourceFileOptions{engine.WithSeparatedNamedImports}, nil)
 		if err != nil {
 			t.Fatalf("list-diff write processPaths returned error: %v", err)
 		}
@@ -503,7 +512,7 @@ func main() {
 
 	expected := filePath + "\n"
 	firstStdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 		if err != nil {
 			t.Fatalf("first processPaths returned error: %v", err)
 		}
@@ -512,7 +521,7 @@ func main() {
 		}
 	})
 	secondStdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{filePath}, cacheDir, nil, nil)
 		if err != nil {
 			t.Fatalf("second processPaths returned error: %v", err)
 		}
@@ -562,7 +571,7 @@ func TestResultPostProcessUsesProvidedConfig(t *testing.T) {
 	t.Cleanup(func() { cfg = origCfg })
 
 	localCfg := Config{output: "file"}
-	if err := resultPostProcess(&localCfg, true, filePath, formatted); err != nil {
+	if err := resultPostProcess(&localCfg, true, filePath, original, formatted); err != nil {
 		t.Fatalf("resultPostProcess returned error: %v", err)
 	}
 
@@ -662,7 +671,7 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	}
 	t.Cleanup(func() { cfg = origCfg })
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil)
 	if err != nil {
 		t.Fatalf("processPaths returned error: %v", err)
 	}
@@ -671,6 +680,109 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	}
 }
 
+func TestProcessPaths_ImportRuleViolations(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := t.TempDir()
+	replacedPath := filepath.Join(tmpDir, "a.go")
+	bannedPath := filepath.Join(tmpDir, "b.go")
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example.com/test\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	fixtures := map[string]string{
+		replacedPath: "package main\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/pkg/errors\"\n)\n\nvar _ = fmt.Sprint(errors.New(\"\"))\n",
+		bannedPath:   "package main\n\nimport \"unsafe\"\n\nvar _ = unsafe.Sizeof(0)\n",
+	}
+	for path, content := range fixtures {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	origCfg := cfg
+	cfg = Config{
+		projectName: "example.com/test",
+		output:      "file",
+		isRecursive: true,
+		isUseCache:  true,
+		importRules: "github.com/pkg/errors => errors;unsafe only pkg/lowlevel",
+	}
+	t.Cleanup(func() { cfg = origCfg })
+
+	opts, err := sourceFileOptions(&cfg)
+	if err != nil {
+		t.Fatalf("sourceFileOptions returned error: %v", err)
+	}
+
+	// The banned import is not replaced and keeps being reported, as files with
+	// violations are not cached.
+	for run := range 2 {
+		_, err := processPaths(t.Context(), &cfg, []string{tmpDir}, cacheDir, opts, nil)
+		if !errors.Is(err, errImportViolations) {
+			t.Fatalf("run %d: processPaths error = %v, want %v", run, err, errImportViolations)
+		}
+	}
+
+	replaced, err := os.ReadFile(replacedPath)
+	if err != nil {
+		t.Fatalf("failed to read fixed file: %v", err)
+	}
+	want := "package main\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n)\n\nvar _ = fmt.Sprint(errors.New(\"\"))\n"
+	if diff := gocmp.Diff(want, string(replaced)); diff != "" {
+		t.Fatalf("fixed file mismatch (-want +got):\n%s", diff)
+	}
+	entry, err := internalcache.ReadCacheEntry(cacheDir, bannedPath)
+	if err != nil {
+		t.Fatalf("failed to read cache entry: %v", err)
+	}
+	if entry != nil {
+		t.Fatalf("expected no cache entry for %s", bannedPath)
+	}
+}
+
+func TestProcessPaths_AppliedImportRuleReplacements(t *testing.T) {
+	const content = "package main\n\nimport \"github.com/pkg/errors\"\n\nvar _ = errors.New(\"\")\n"
+
+	tests := map[string]struct {
+		output       string
+		listFileName bool
+		wantErr      bool
+	}{
+		"written":   {output: "file"},
+		"stdout":    {output: "stdout"},
+		"diff":      {output: "diff", wantErr: true},
+		"list diff": {output: "file", listFileName: true, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.go")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write fixture: %v", err)
+			}
+
+			local := Config{
+				projectName:  "example.com/test",
+				output:       tt.output,
+				listFileName: tt.listFileName,
+				importRules:  "github.com/pkg/errors => errors",
+			}
+			opts, err := sourceFileOptions(&local)
+			if err != nil {
+				t.Fatalf("sourceFileOptions returned error: %v", err)
+			}
+
+			// Replacements that end up in the written content do not fail
+			// the run, unlike the ones that are only shown.
+			captureStdout(t, func() {
+				_, err = processPaths(t.Context(), &local, []string{path}, "", opts, nil)
+			})
+			if got := errors.Is(err, errImportViolations); got != tt.wantErr || (err != nil && !got) {
+				t.Fatalf("processPaths error = %v, want import violations %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestProcessPaths_DirRecursive_NoChange(t *testing.T) {
 	tmpDir := t.TempDir()
 	filePath := filepath.Join(tmpDir, "a.go")
@@ -697,7 +809,7 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	}
 	t.Cleanup(func() { cfg = origCfg })
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil)
 	if err != nil {
 		t.Fatalf("processPaths returned error: %v", err)
 	}
@@ -732,7 +844,7 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	t.Cleanup(func() { cfg = origCfg })
 
 	stdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil)
 		if err != nil {
 			t.Fatalf("processPaths returned error: %v", err)
 		}
@@ -785,7 +897,7 @@ func broken(
 	}
 	t.Cleanup(func() { cfg = origCfg })
 
-	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil)
+	hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil)
 	if err == nil {
 		t.Fatalf("expected processPaths to return an error for invalid Go file")
 	}
@@ -833,7 +945,7 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	t.Cleanup(func() { cfg = origCfg })
 
 	stdout := captureStdout(t, func() {
-		hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil)
+		hasChange, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil)
 		if err != nil {
 			t.Fatalf("processPaths returned error: %v", err)
 		}
@@ -850,28 +962,857 @@ func main() { _ = errors.New(""); _ = fmt.Sprint("") }
 	}
 }
 
+func TestProcessPaths_DiffOutputPrintsUnifiedDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "a.go")
+	unformatted := []byte(`package main
+
+import (
+	"github.com/pkg/errors"
+	"fmt"
+)
+
+func main() { _ = errors.New(""); _ = fmt.Sprint("") }
+`)
+	if err := os.WriteFile(filePath, unformatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	for name, target := range map[string]string{"file": filePath, "dir": tmpDir} {
+		t.Run(name, func(t *testing.T) {
+			origCfg := cfg
+			cfg = Config{
+				projectName:   "example.com/test",
+				output:        "diff",
+				setExitStatus: true,
+			}
+			t.Cleanup(func() { cfg = origCfg })
+
+			stdout := captureStdout(t, func() {
+				hasChange, err := processPaths(t.Context(), &cfg, []string{target}, "", nil, nil)
+				if err != nil {
+					t.Fatalf("processPaths returned error: %v", err)
+				}
+				if !hasChange {
+					t.Fatalf("expected hasChange to be true for diff output over unformatted %s", name)
+				}
+			})
+
+			for _, want := range []string{
+				"--- " + filePath + ".orig\n",
+				"+++ " + filePath + "\n",
+				"-\t\"github.com/pkg/errors\"\n",
+				"+\t\"github.com/pkg/errors\"\n",
+			} {
+				if !strings.Contains(stdout, want) {
+					t.Fatalf("expected diff stdout to contain %q, got:\n%s", want, stdout)
+				}
+			}
+
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatalf("failed to read fixture after diff: %v", err)
+			}
+			if !bytes.Equal(content, unformatted) {
+				t.Fatalf("expected diff mode to leave file unchanged\nwant:\n%s\n got:\n%s", unformatted, content)
+			}
+		})
+	}
+}
+
+func TestProcessPaths_JSONReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	changedFile := filepath.Join(tmpDir, "a.go")
+	formattedFile := filepath.Join(tmpDir, "b.go")
+	unformatted := []byte(`package main
+
+import (
+	"github.com/pkg/errors"
+	"fmt"
+)
+
+func main() { _ = errors.New(""); _ = fmt.Sprint("") }
+`)
+	formatted := []byte(`package main
+
+import "fmt"
+
+func f() { _ = fmt.Sprint("") }
+`)
+	if err := os.WriteFile(changedFile, unformatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(formattedFile, formatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	origCfg := cfg
+	cfg = Config{
+		projectName: "example.com/test",
+		output:      "file",
+		report:      reportFormatJSON,
+		isRecursive: true,
+	}
+	t.Cleanup(func() { cfg = origCfg })
+
+	stdout := captureStdout(t, func() {
+		if _, err := processPaths(t.Context(), &cfg, []string{tmpDir}, "", nil, nil); err != nil {
+			t.Fatalf("processPaths returned error: %v", err)
+		}
+	})
+
+	var got report
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("failed to decode report: %v\n%s", err, stdout)
+	}
+
+	want := report{
+		Files: []engine.FileReport{
+			{
+				Path:    changedFile,
+				Changed: true,
+				ImportChanges: engine.ImportChanges{
+					Moved: []string{"fmt", "github.com/pkg/errors"},
+				},
+			},
+			{Path: formattedFile},
+		},
+		Summary: reportSummary{Files: 2, Changed: 1, Unchanged: 1},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("report mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateReport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cfg     Config
+		wantErr bool
+	}{
+		"no report":         {cfg: Config{output: "stdout", listFileName: true}},
+		"file output":       {cfg: Config{output: "file", report: reportFormatJSON}},
+		"write output":      {cfg: Config{output: "write", report: reportFormatJSON}},
+		"staged":            {cfg: Config{output: "file", staged: true, report: reportFormatJSON}},
+		"unknown format":    {cfg: Config{output: "file", report: "xml"}, wantErr: true},
+		"stdout output":     {cfg: Config{output: "stdout", report: reportFormatJSON}, wantErr: true},
+		"diff output":       {cfg: Config{output: "diff", report: reportFormatJSON}, wantErr: true},
+		"list diff":         {cfg: Config{output: "file", listFileName: true, report: reportFormatJSON}, wantErr: true},
+		"list diff written": {cfg: Config{output: "write", listFileName: true, report: reportFormatJSON}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateReport(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateReport() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestFormatterCacheFingerprintVersion(t *testing.T) {
 	cfg := &Config{
 		importsOrder:                "std,general,company,project,blanked,dotted",
+		importGroups:                "k8s=k8s.io/...",
+		importAliases:               "corev1=k8s.io/api/core/v1",
+		importRewrites:              "github.com/pkg/errors => errors",
+		importRules:                 "unsafe only pkg/lowlevel",
 		companyPkgPrefixes:          "github.com/acme/",
 		shouldRemoveUnusedImports:   true,
+		shouldTypeCheckUnused:       true,
+		shouldAddMissingImports:     true,
 		shouldSetAlias:              true,
 		shouldFormat:                true,
 		shouldSeparateNamedImports:  true,
 		shouldSkipBlanked:           true,
 		shouldApplyToGeneratedFiles: true,
+		shouldGroupWorkspace:        true,
 	}
 
-	got := formatterCacheFingerprint(cfg, "github.com/acme/project")
+	got := formatterCacheFingerprint(t.Context(), cfg, "github.com/acme/project")
 	if !strings.HasPrefix(got, "v3|") {
 		t.Fatalf("formatterCacheFingerprint version = %q, want v3 prefix", got)
 	}
 	if !strings.Contains(got, "skip-blanked=true") {
 		t.Fatalf("formatterCacheFingerprint lost skip-blanked flag: %q", got)
 	}
+	if !strings.Contains(got, "typecheck-unused=true") {
+		t.Fatalf("formatterCacheFingerprint lost typecheck-unused flag: %q", got)
+	}
+	if !strings.Contains(got, "add-missing=true") {
+		t.Fatalf("formatterCacheFingerprint lost add-missing flag: %q", got)
+	}
 	if !strings.Contains(got, "imports-order=std,general,company,project,blanked,dotted") {
 		t.Fatalf("formatterCacheFingerprint lost imports order: %q", got)
 	}
+	if !strings.Contains(got, "import-groups=k8s=k8s.io/...") {
+		t.Fatalf("formatterCacheFingerprint lost import groups: %q", got)
+	}
+	if !strings.Contains(got, "aliases=corev1=k8s.io/api/core/v1") {
+		t.Fatalf("formatterCacheFingerprint lost import aliases: %q", got)
+	}
+	if !strings.Contains(got, "rewrite-imports=github.com/pkg/errors => errors") {
+		t.Fatalf("formatterCacheFingerprint lost import rewrites: %q", got)
+	}
+	if !strings.Contains(got, "import-rules=unsafe only pkg/lowlevel") {
+		t.Fatalf("formatterCacheFingerprint lost import rules: %q", got)
+	}
+	if !strings.Contains(got, "workspace=true") {
+		t.Fatalf("formatterCacheFingerprint lost workspace flag: %q", got)
+	}
+	if !strings.Contains(got, "|go-version=go") {
+		t.Fatalf("formatterCacheFingerprint lost the go version: %q", got)
+	}
+}
+
+func TestFileCacheFingerprintWorkspaceModules(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"alone/go.mod":     "module example.com/alone\n\ngo 1.26\n",
+		"replacing/go.mod": "module example.com/replacing\n\ngo 1.26\n\nreplace example.com/lib => ../lib\n",
+		"lib/go.mod":       "module example.com/lib\n\ngo 1.26\n",
+	})
+	alone := filepath.Join(root, "alone")
+	replacing := filepath.Join(root, "replacing")
+
+	cfg := &Config{shouldGroupWorkspace: true}
+	if got := fileCacheFingerprint(t.Context(), cfg, "example.com/alone", filepath.Join(alone, "a.go")); !strings.HasSuffix(got, "|workspace-modules=") {
+		t.Fatalf("fileCacheFingerprint of a module without workspace = %q", got)
+	}
+	if got := fileCacheFingerprint(t.Context(), cfg, "example.com/replacing", filepath.Join(replacing, "a.go")); !strings.HasSuffix(got, "|workspace-modules=example.com/lib") {
+		t.Fatalf("fileCacheFingerprint lost workspace modules: %q", got)
+	}
+	if got := fileCacheFingerprint(t.Context(), cfg, "example.com/outside", filepath.Join(t.TempDir(), "a.go")); strings.Contains(got, "|workspace-modules=") {
+		t.Fatalf("fileCacheFingerprint of a file outside of modules = %q", got)
+	}
+}
+
+func TestProcessTargetsSharesDirectoryCacheEntries(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"go.mod":        "module example.com/root\n\ngo 1.26\n",
+		"nested/go.mod": "module example.com/nested\n\ngo 1.26\n",
+		"nested/a.go":   stagedFormatted,
+	})
+	file := filepath.Join(root, "nested", "a.go")
+
+	local := Config{output: "file", isRecursive: true, isUseCache: true, useMetadataCache: true}
+	entryOf := func(files []string) *internalcache.CacheEntry {
+		t.Helper()
+
+		cacheDir := t.TempDir()
+		if _, err := processTargets(t.Context(), &local, []string{root}, files, cacheDir, nil, nil); err != nil {
+			t.Fatalf("processTargets returned error: %v", err)
+		}
+		entry, err := internalcache.ReadCacheEntry(cacheDir, file)
+		if err != nil || entry == nil {
+			t.Fatalf("expected a cache entry for %s, got %v, %v", file, entry, err)
+		}
+		return entry
+	}
+
+	// Changed files are fixed through the directory, so a file of a nested
+	// module gets the cache entry a directory run writes for it.
+	if diff := gocmp.Diff(entryOf(nil), entryOf([]string{file})); diff != "" {
+		t.Fatalf("cache entry mismatch (-dir +files):\n%s", diff)
+	}
+}
+
+func TestLoadConfig_DiscoveredFileAppliesUnlessFlagIsExplicit(t *testing.T) {
+	rootDir := t.TempDir()
+	pkgDir := filepath.Join(rootDir, "internal", "pkg")
+	if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+		t.Fatalf("failed to create package dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "go.mod"), []byte("module example.com/test\n"), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	configFile := filepath.Join(rootDir, configFileName)
+	config := `{
+	"company-prefixes": "github.com/acme/",
+	"rm-unused": true,
+	"format": true,
+	"excludes": [".git/", "proto/*.go"]
+}`
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	var local Config
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.StringVar(&local.companyPkgPrefixes, "company-prefixes", "", "")
+	flags.StringVar(&local.excludes, "excludes", "", "")
+	flags.BoolVar(&local.shouldRemoveUnusedImports, "rm-unused", false, "")
+	flags.BoolVar(&local.shouldFormat, "format", false, "")
+	if err := flags.Parse([]string{"-format=false"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	before := formatterCacheFingerprint(t.Context(), &local, "example.com/test")
+
+	got, err := loadConfig(flags, "", filepath.Join(pkgDir, "file.go"))
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if got != configFile {
+		t.Fatalf("loadConfig path mismatch: got %q want %q", got, configFile)
+	}
+
+	if local.companyPkgPrefixes != "github.com/acme/" {
+		t.Fatalf("expected company prefixes from config, got %q", local.companyPkgPrefixes)
+	}
+	if !local.shouldRemoveUnusedImports {
+		t.Fatalf("expected rm-unused from config to be applied")
+	}
+	if local.shouldFormat {
+		t.Fatalf("expected explicit -format=false to override config")
+	}
+	if local.excludes != ".git/,proto/*.go" {
+		t.Fatalf("expected excludes array to be joined, got %q", local.excludes)
+	}
+	if after := formatterCacheFingerprint(t.Context(), &local, "example.com/test"); after == before {
+		t.Fatalf("expected config values to change the cache fingerprint, got %q", after)
+	}
+}
+
+func TestLoadConfig_RejectsUnknownOption(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"version": true}`), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Bool("version", false, "")
+
+	if _, err := loadConfig(flags, configFile, "."); err == nil || !strings.Contains(err.Error(), `unknown option "version"`) {
+		t.Fatalf("expected unknown option error, got %v", err)
+	}
+}
+
+func TestLoadConfig_NoConfigFile(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	got, err := loadConfig(flags, "", filepath.Join(t.TempDir(), "file.go"))
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if got != "" {
+		t.Fatalf("expected no config file to be found, got %q", got)
+	}
+}
+
+func TestFindConfigFile_StopsAtModuleRoot(t *testing.T) {
+	t.Setenv("GOWORK", "")
+
+	rootDir := t.TempDir()
+	testutil.WriteFiles(t, rootDir, map[string]string{
+		configFileName:         "{}",
+		"mod/go.mod":           "module example.com/mod\n",
+		"ws/" + configFileName: "{}",
+		"ws/go.work":           "go 1.26\n\nuse ./a\n",
+		"ws/a/go.mod":          "module example.com/a\n",
+		"plain/file.go":        "package plain\n",
+	})
+	workspaceConfig := filepath.Join(rootDir, "ws", configFileName)
+
+	tests := map[string]struct {
+		path string
+		want string
+	}{
+		"config above the module":        {path: filepath.Join(rootDir, "mod", "pkg", "file.go")},
+		"config at the workspace root":   {path: filepath.Join(rootDir, "ws", "a", "file.go"), want: workspaceConfig},
+		"config above a directory":       {path: filepath.Join(rootDir, "plain")},
+		"config in the target directory": {path: filepath.Join(rootDir, "file.go"), want: filepath.Join(rootDir, configFileName)},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := findConfigFile(tt.path)
+			if err != nil {
+				t.Fatalf("findConfigFile returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("findConfigFile(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitChangedPaths(t *testing.T) {
+	root, runGit, write := newGitTestRepo(t)
+
+	for _, name := range []string{"main.go", "pkg/a.go", "pkg/b.go", "gen/api.go", "pkg/testdata/x.go"} {
+		write(name, "package p\n")
+	}
+	runGit("add", "-A")
+	runGit("commit", "-q", "-m", "initial")
+
+	for _, name := range []string{"main.go", "pkg/a.go", "gen/api.go", "pkg/testdata/x.go", "notes.txt"} {
+		write(name, "package p\n\nfunc f() {}\n")
+	}
+	runGit("add", "main.go")
+
+	tests := map[string]struct {
+		cfg     Config
+		targets []string
+		want    []string
+	}{
+		"recursive directory applies excludes and go tool rules": {
+			cfg:     Config{gitDiff: "HEAD", isRecursive: true, excludes: "gen/"},
+			targets: []string{root},
+			want:    []string{filepath.Join(root, "main.go"), filepath.Join(root, "pkg", "a.go")},
+		},
+		"non-recursive directory": {
+			cfg:     Config{gitDiff: "HEAD"},
+			targets: []string{filepath.Join(root, "pkg")},
+			want:    []string{filepath.Join(root, "pkg", "a.go")},
+		},
+		"unchanged file target is dropped": {
+			cfg:     Config{gitDiff: "HEAD"},
+			targets: []string{filepath.Join(root, "pkg", "a.go"), filepath.Join(root, "pkg", "b.go")},
+			want:    []string{filepath.Join(root, "pkg", "a.go")},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := gitChangedPaths(t.Context(), &tt.cfg, tt.targets)
+			if err != nil {
+				t.Fatalf("gitChangedPaths returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("gitChangedPaths mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := gitChangedPaths(t.Context(), &Config{gitDiff: "HEAD"}, []string{engine.StandardInput}); err == nil {
+		t.Fatalf("expected stdin to be rejected")
+	}
+}
+
+const stagedUnformatted = `package p
+
+import (
+	"strings"
+	"fmt"
+)
+
+var _ = fmt.Sprint
+var _ = strings.ToLower
+`
+
+const stagedFormatted = `package p
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ = fmt.Sprint
+var _ = strings.ToLower
+`
+
+func TestProcessStaged_FixesIndexAndWorkTree(t *testing.T) {
+	root, runGit, write := newGitTestRepo(t)
+	write("go.mod", "module example.com/p\n")
+	write("a.go", stagedUnformatted)
+	write("untouched.go", stagedUnformatted)
+	runGit("add", "go.mod", "a.go")
+
+	local := Config{output: "file", isRecursive: true}
+	hasChange, err := processStaged(t.Context(), &local, []string{root}, nil, nil)
+	if err != nil {
+		t.Fatalf("processStaged returned error: %v", err)
+	}
+	if !hasChange {
+		t.Fatalf("expected processStaged to report a change")
+	}
+
+	if got := gitOutput(t, root, "show", ":a.go"); got != stagedFormatted {
+		t.Fatalf("staged content mismatch (-want +got):\n%s", gocmp.Diff(stagedFormatted, got))
+	}
+	if got := readFile(t, filepath.Join(root, "a.go")); got != stagedFormatted {
+		t.Fatalf("work tree content mismatch (-want +got):\n%s", gocmp.Diff(stagedFormatted, got))
+	}
+	if got := readFile(t, filepath.Join(root, "untouched.go")); got != stagedUnformatted {
+		t.Fatalf("unstaged file must not be touched, got:\n%s", got)
+	}
+}
+
+func TestProcessStaged_ChecksStagedContentNotWorkTree(t *testing.T) {
+	root, runGit, write := newGitTestRepo(t)
+	write("go.mod", "module example.com/p\n")
+	write("a.go", stagedUnformatted)
+	runGit("add", "go.mod", "a.go")
+	// The work tree is already formatted, the staged blob is not.
+	write("a.go", stagedFormatted)
+
+	local := Config{output: "file", listFileName: true, isRecursive: true}
+	var hasChange bool
+	out := captureStdout(t, func() {
+		var err error
+		hasChange, err = processStaged(t.Context(), &local, []string{root}, nil, nil)
+		if err != nil {
+			t.Errorf("processStaged returned error: %v", err)
+		}
+	})
+	if !hasChange {
+		t.Fatalf("expected the staged content to need formatting")
+	}
+	if want := filepath.Join(root, "a.go") + "\n"; out != want {
+		t.Fatalf("listed files = %q, want %q", out, want)
+	}
+	if got := gitOutput(t, root, "show", ":a.go"); got != stagedUnformatted {
+		t.Fatalf("listing must not modify the index, got:\n%s", got)
+	}
+}
+
+func TestProcessStaged_RefusesPartiallyStagedFile(t *testing.T) {
+	root, runGit, write := newGitTestRepo(t)
+	write("go.mod", "module example.com/p\n")
+	write("a.go", stagedUnformatted)
+	runGit("add", "go.mod", "a.go")
+	partial := stagedUnformatted + "\nvar unstaged = 1\n"
+	write("a.go", partial)
+
+	local := Config{output: "file", isRecursive: true}
+	_, err := processStaged(t.Context(), &local, []string{root}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "has unstaged changes") {
+		t.Fatalf("expected partially staged file to be refused, got %v", err)
+	}
+	if got := gitOutput(t, root, "show", ":a.go"); got != stagedUnformatted {
+		t.Fatalf("refused file must keep its staged content, got:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(root, "a.go")); got != partial {
+		t.Fatalf("refused file must keep its work tree content, got:\n%s", got)
+	}
+}
+
+func TestFixSourceFuncRevisesBufferWithoutDisk(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "does-not-exist.go")
+	fix := fixSourceFunc(&Config{projectName: "example.com/p"}, nil)
+
+	got, err := fix(t.Context(), filename, []byte(stagedUnformatted))
+	if err != nil {
+		t.Fatalf("fix returned error: %v", err)
+	}
+	if diff := gocmp.Diff(stagedFormatted, string(got)); diff != "" {
+		t.Fatalf("fixed content mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected fix not to create %s, got %v", filename, err)
+	}
+}
+
+func TestFixSourceFuncReloadsChangedImports(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/p\n\ngo 1.26\n"), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	local := Config{projectName: "example.com/p", shouldRemoveUnusedImports: true}
+	opts, err := sourceFileOptions(&local)
+	if err != nil {
+		t.Fatalf("sourceFileOptions returned error: %v", err)
+	}
+	fix := fixSourceFunc(&local, opts)
+	filename := filepath.Join(dir, "a.go")
+
+	for _, tt := range []struct{ src, want string }{
+		{
+			src:  "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc F() { fmt.Println() }\n",
+			want: "package p\n\nimport (\n\t\"fmt\"\n)\n\nfunc F() { fmt.Println() }\n",
+		},
+		{
+			// The package imports loaded for the first fix do not know
+			// math/rand/v2, whose name differs from the last element of its path.
+			src:  "package p\n\nimport (\n\t\"math/rand/v2\"\n\t\"os\"\n)\n\nfunc F() int { return rand.IntN(1) }\n",
+			want: "package p\n\nimport (\n\t\"math/rand/v2\"\n)\n\nfunc F() int { return rand.IntN(1) }\n",
+		},
+	} {
+		// The document is saved between the fixes.
+		if err := os.WriteFile(filename, []byte(tt.src), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		got, err := fix(t.Context(), filename, []byte(tt.src))
+		if err != nil {
+			t.Fatalf("fix returned error: %v", err)
+		}
+		if diff := gocmp.Diff(tt.want, string(got)); diff != "" {
+			t.Fatalf("fixed content mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestFixerUsesDaemonAndFallsBackInProcess(t *testing.T) {
+	dir := t.TempDir()
+	local := Config{projectName: "example.com/p", daemonSocket: filepath.Join(dir, "daemon.sock")}
+	opts, err := sourceFileOptions(&local)
+	if err != nil {
+		t.Fatalf("sourceFileOptions returned error: %v", err)
+	}
+	if fix := newFixer(&local); fix != nil {
+		t.Fatalf("expected no fixer without a daemon socket")
+	}
+
+	version, err := executableVersion()
+	if err != nil {
+		t.Fatalf("executableVersion returned error: %v", err)
+	}
+	ln, err := daemon.Listen(local.daemonSocket)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+	var requests atomic.Int32
+	server := daemon.NewServer(func(ctx context.Context, req *daemon.Request) (*daemon.Response, error) {
+		requests.Add(1)
+		return handleDaemonRequest(ctx, req)
+	}, version)
+	ctx, stopServer := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, ln) }()
+
+	filename := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(filename, []byte(stagedUnformatted), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	fix := newFixer(&local)
+	if fix == nil {
+		t.Fatalf("expected a fixer while the daemon is listening")
+	}
+	check := func(stage string) {
+		t.Helper()
+		result, err := fix.fixFile(t.Context(), local.projectName, filename, opts...)
+		if err != nil {
+			t.Fatalf("%s: fixFile returned error: %v", stage, err)
+		}
+		if !result.Changed || string(result.Original) != stagedUnformatted {
+			t.Fatalf("%s: unexpected result: %+v", stage, result)
+		}
+		if diff := gocmp.Diff(stagedFormatted, string(result.Content)); diff != "" {
+			t.Fatalf("%s: fixed content mismatch (-want +got):\n%s", stage, diff)
+		}
+	}
+
+	check("daemon")
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected the daemon to serve 1 request, got %d", got)
+	}
+
+	stopServer()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+
+	check("in process")
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected the stopped daemon to serve no request, got %d", got)
+	}
+	if !fix.unavailable.Load() {
+		t.Fatalf("expected the fixer to remember that the daemon is unavailable")
+	}
+}
+
+func TestValidateWatch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(file, []byte("package a\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := map[string]struct {
+		cfg     Config
+		targets []string
+		wantErr bool
+	}{
+		"directory":         {cfg: Config{output: "file"}, targets: []string{dir}},
+		"write output":      {cfg: Config{output: "write"}, targets: []string{dir}},
+		"file target":       {cfg: Config{output: "file"}, targets: []string{dir, file}, wantErr: true},
+		"stdout output":     {cfg: Config{output: "stdout"}, targets: []string{dir}, wantErr: true},
+		"list diff":         {cfg: Config{output: "file", listFileName: true}, targets: []string{dir}, wantErr: true},
+		"staged":            {cfg: Config{output: "file", staged: true}, targets: []string{dir}, wantErr: true},
+		"git diff revision": {cfg: Config{output: "file", gitDiff: "HEAD"}, targets: []string{dir}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateWatch(&tt.cfg, tt.targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateWatch() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWatchPathsFixesWrittenFiles(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watching is only supported on linux")
+	}
+
+	dir := t.TempDir()
+	local := Config{projectName: "example.com/p", output: "file", excludes: "skipped.go"}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- watchPaths(ctx, &local, []string{dir}, "", nil, nil) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("watchPaths returned %v, want context.Canceled", err)
+		}
+	})
+
+	skipped := filepath.Join(dir, "skipped.go")
+	fixed := filepath.Join(dir, "a.go")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// Watches are added asynchronously, so write until the fix shows up.
+		for _, path := range []string{skipped, fixed} {
+			if err := os.WriteFile(path, []byte(stagedUnformatted), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+		if readFile(t, fixed) == stagedFormatted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s to be fixed, got:\n%s", fixed, readFile(t, fixed))
+		}
+	}
+	if got := readFile(t, skipped); got != stagedUnformatted {
+		t.Fatalf("excluded file must not be fixed, got:\n%s", got)
+	}
+}
+
+func TestWatchPathsReloadsChangedImports(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watching is only supported on linux")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/p\n\ngo 1.26\n"), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	local := Config{projectName: "example.com/p", output: "file", shouldRemoveUnusedImports: true}
+	options, err := sourceFileOptions(&local)
+	if err != nil {
+		t.Fatalf("sourceFileOptions returned error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- watchPaths(ctx, &local, []string{dir}, "", options, nil) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("watchPaths returned %v, want context.Canceled", err)
+		}
+	})
+
+	path := filepath.Join(dir, "a.go")
+	// Each write imports os without using it, so its removal shows the fix.
+	writeUntilFixed := func(content, want string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			// Watches are added asynchronously, so write until the fix shows up.
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			got := readFile(t, path)
+			if !strings.Contains(got, `"os"`) {
+				if diff := gocmp.Diff(want, got); diff != "" {
+					t.Fatalf("fixed content mismatch (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s to be fixed, got:\n%s", path, got)
+			}
+		}
+	}
+
+	writeUntilFixed(
+		"package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc F() { fmt.Println() }\n",
+		"package p\n\nimport (\n\t\"fmt\"\n)\n\nfunc F() { fmt.Println() }\n",
+	)
+	// The package imports loaded for the first fix do not know math/rand/v2,
+	// whose name differs from the last element of its path.
+	writeUntilFixed(
+		"package p\n\nimport (\n\t\"math/rand/v2\"\n\t\"os\"\n)\n\nfunc F() int { return rand.IntN(1) }\n",
+		"package p\n\nimport (\n\t\"math/rand/v2\"\n)\n\nfunc F() int { return rand.IntN(1) }\n",
+	)
+}
+
+// newGitTestRepo creates an empty git repository and returns its resolved
+// root with helpers to run git and to write files below it.
+func newGitTestRepo(t *testing.T) (string, func(args ...string), func(name, content string)) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is not available")
+	}
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	runGit := func(args ...string) {
+		t.Helper()
+		gitOutput(t, root, args...)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		testutil.WriteFile(t, filepath.Join(root, filepath.FromSlash(name)), content)
+	}
+
+	runGit("init", "-q")
+	return root, runGit, write
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %v failed: %v", args, err)
+	}
+	return string(out)
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(content)
 }
 
 func captureStdout(t *testing.T, fn func()) string {
@@ -905,3 +1846,39 @@ func captureStdout(t *testing.T, fn func()) string {
 
 	return output
 }
+
+func TestTargetPaths_ConfigSelectsGitMode(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootDir, configFileName), []byte(`{"staged": true}`), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Chdir(rootDir)
+
+	var local Config
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.BoolVar(&local.staged, "staged", false, "")
+	if err := flags.Parse(nil); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	got, err := targetPaths(flags, &local, nil)
+	if err != nil {
+		t.Fatalf("targetPaths returned error: %v", err)
+	}
+	if diff := gocmp.Diff([]string{"./..."}, got); diff != "" {
+		t.Fatalf("targetPaths mismatch (-want +got):\n%s", diff)
+	}
+	if !local.staged {
+		t.Fatalf("expected staged from config to be applied")
+	}
+}
+
+func TestTargetPaths_NoTargets(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	var local Config
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	if _, err := targetPaths(flags, &local, nil); err == nil || !strings.Contains(err.Error(), "no file(s)") {
+		t.Fatalf("expected missing targets error, got %v", err)
+	}
+}
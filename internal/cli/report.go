@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+)
+
+const reportFormatJSON = "json"
+
+// validateReport reports whether the -report of cfg is valid. The report is
+// printed to stdout, so it cannot share stdout with fixed content, diffs or
+// listed files.
+func validateReport(cfg *Config) error {
+	if cfg.report == "" {
+		return nil
+	}
+	if cfg.report != reportFormatJSON {
+		return fmt.Errorf("invalid report %q specified", cfg.report)
+	}
+	if cfg.output == "stdout" || cfg.output == "diff" {
+		return fmt.Errorf("-report cannot be used with -output %s", cfg.output)
+	}
+	if cfg.listFileName {
+		return errors.New("-report cannot be used with -list-diff")
+	}
+	return nil
+}
+
+// reportSummary aggregates the per-file entries of a report. Unchanged counts
+// every file that was neither changed nor failed, including cache hits.
+type reportSummary struct {
+	Files     int `json:"files"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+	Errors    int `json:"errors"`
+	CacheHits int `json:"cache_hits"`
+}
+
+type report struct {
+	Files   []engine.FileReport `json:"files"`
+	Summary reportSummary       `json:"summary"`
+}
+
+// reportCollector gathers FileReports from concurrently processed paths.
+type reportCollector struct {
+	mu    sync.Mutex
+	files []engine.FileReport
+}
+
+func (c *reportCollector) add(fileReport engine.FileReport) {
+	c.mu.Lock()
+	c.files = append(c.files, fileReport)
+	c.mu.Unlock()
+}
+
+// write prints the collected reports ordered by path, followed by the summary.
+func (c *reportCollector) write(w io.Writer) error {
+	c.mu.Lock()
+	files := slices.Clone(c.files)
+	c.mu.Unlock()
+
+	slices.SortFunc(files, func(a, b engine.FileReport) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	result := report{
+		Files: files,
+		Summary: reportSummary{
+			Files: len(files),
+		},
+	}
+	if result.Files == nil {
+		result.Files = []engine.FileReport{}
+	}
+	for _, file := range files {
+		switch {
+		case file.Error != "":
+			result.Summary.Errors++
+		case file.Changed:
+			result.Summary.Changed++
+		default:
+			result.Summary.Unchanged++
+		}
+		if file.CacheHit {
+			result.Summary.CacheHits++
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
+	"github.com/zchee/goimports-rereviser/v4/internal/diff"
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/git"
+)
+
+// stagedFile is a staged Go file selected by the targets, with the result of
+// fixing its staged content.
+type stagedFile struct {
+	gitFile
+	entry  git.IndexEntry
+	result *engine.Result
+	err    error
+}
+
+// processStaged revises the content staged in the git index instead of the
+// work tree, for use in pre-commit hooks. Fixed content is written back to
+// both the index and the work tree, which is refused for files with unstaged
+// changes: those would either be overwritten or make the staged fix disagree
+// with the work tree.
+func processStaged(ctx context.Context, cfg *Config, targets []string, options engine.SourceFileOptions, fix *fixer) (bool, error) {
+	entries := make(map[string]git.IndexEntry)
+	selected, err := selectGitFiles(cfg, targets, func(dir string) ([]string, error) {
+		staged, err := git.StagedEntries(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(staged))
+		for _, entry := range staged {
+			entries[entry.Path] = entry
+			paths = append(paths, entry.Path)
+		}
+		return paths, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	files := make([]stagedFile, len(selected))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for idx, file := range selected {
+		files[idx] = stagedFile{gitFile: file, entry: entries[file.resolved]}
+		g.Go(func() error {
+			current := &files[idx]
+			current.result, current.err = fixStagedFile(gctx, cfg, current, options, fix)
+			return gctx.Err()
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return false, err
+	}
+
+	var reports *reportCollector
+	if cfg.report != "" {
+		reports = &reportCollector{}
+	}
+
+	var (
+		hasChange  bool
+		errs       []error
+		writeBack  = make(map[string][]*stagedFile)
+		roots      []string
+		violations = newViolationPrinter(os.Stderr, cfg)
+	)
+	for idx := range files {
+		file := &files[idx]
+		fileReport := engine.FileReport{Path: file.path}
+		if file.err != nil {
+			errs = append(errs, file.err)
+			fileReport.Error = file.err.Error()
+		} else {
+			fileReport.Changed = file.result.Changed
+			fileReport.Skipped = file.result.SkipReason
+			fileReport.ImportChanges = file.result.ImportChanges
+			fileReport.Violations = file.result.Violations
+			violations.print(file.path, file.result.Violations)
+		}
+		if reports != nil {
+			reports.add(fileReport)
+		}
+		if file.err != nil || !file.result.Changed {
+			continue
+		}
+		hasChange = true
+
+		switch {
+		case cfg.output == "diff":
+			fmt.Print(string(diff.Unified(file.path+".orig", file.path, file.result.Original, file.result.Content)))
+		case cfg.listFileName && cfg.output != "write":
+			fmt.Println(file.path)
+		case cfg.output == "stdout":
+			fmt.Print(string(file.result.Content))
+		case cfg.output == "file" || cfg.output == "write":
+			if _, ok := writeBack[file.entry.Root]; !ok {
+				roots = append(roots, file.entry.Root)
+			}
+			writeBack[file.entry.Root] = append(writeBack[file.entry.Root], file)
+		default:
+			return hasChange, fmt.Errorf("invalid output %q specified", cfg.output)
+		}
+	}
+
+	for _, root := range roots {
+		if err := writeBackStaged(ctx, cfg, root, writeBack[root]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if reports != nil {
+		if writeErr := reports.write(os.Stdout); writeErr != nil {
+			errs = append(errs, fmt.Errorf("failed to write report: %w", writeErr))
+		}
+	}
+
+	if len(errs) > 0 {
+		return hasChange, errors.Join(errs...)
+	}
+	return hasChange, violations.err()
+}
+
+func fixStagedFile(ctx context.Context, cfg *Config, file *stagedFile, options engine.SourceFileOptions, fix *fixer) (*engine.Result, error) {
+	projectName, err := determineProjectName(cfg.projectName, file.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not determine project name for path %s: %w", file.path, err)
+	}
+
+	content, err := git.ReadBlob(ctx, file.entry.Root, file.entry.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged content of %s: %w", file.path, err)
+	}
+
+	result, err := fix.fixSource(ctx, projectName, file.path, content, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fix staged file %s: %w", file.path, err)
+	}
+	return result, nil
+}
+
+// writeBackStaged stores the fixed content of files, which all belong to the
+// work tree root, in the index and then in the work tree.
+func writeBackStaged(ctx context.Context, cfg *Config, root string, files []*stagedFile) error {
+	unstaged, err := git.UnstagedNames(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs    []error
+		updates []git.IndexEntry
+		written []*stagedFile
+	)
+	for _, file := range files {
+		if _, ok := unstaged[file.entry.Name]; ok {
+			errs = append(errs, fmt.Errorf("refusing to fix staged file %s: it has unstaged changes, stage or stash them first", file.path))
+			continue
+		}
+
+		object, err := git.WriteBlob(ctx, root, file.result.Content)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to store fixed content of %s: %w", file.path, err))
+			continue
+		}
+		entry := file.entry
+		entry.Object = object
+		updates = append(updates, entry)
+		written = append(written, file)
+	}
+
+	if err := git.UpdateIndex(ctx, root, updates); err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to update the git index: %w", err))...)
+	}
+
+	for _, file := range written {
+		if err := atomicfile.WriteFile(file.path, file.result.Content, 0o644); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write fixed result to file(%s): %w", file.path, err))
+			continue
+		}
+		if cfg.listFileName {
+			fmt.Println(file.path)
+		}
+	}
+
+	return errors.Join(errs...)
+}
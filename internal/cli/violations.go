@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+)
+
+// errImportViolations is returned after every file was processed when some of
+// them still break the import rules. Like '-set-exit-status', it fails the run.
+var errImportViolations = errors.New("imports break the import rules")
+
+// violationPrinter prints the import rule violations of revised files as
+// "path:line:column: message" lines and remembers whether any of them remain
+// in the output: violations without a replacement, and replaced ones when
+// the revised content is not written, see writesFixes.
+type violationPrinter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	applied bool // whether replacements are written
+	failed  bool
+}
+
+// newViolationPrinter returns a violationPrinter for the output of cfg.
+func newViolationPrinter(w io.Writer, cfg *Config) *violationPrinter {
+	return &violationPrinter{w: w, applied: writesFixes(cfg)}
+}
+
+// writesFixes reports whether cfg writes the revised content of files, to the
+// files or to stdout, so that the replacements of import rules take effect.
+// -list-diff and '-output diff' only show which files differ.
+func writesFixes(cfg *Config) bool {
+	return cfg.output != "diff" && !cfg.listFileName
+}
+
+func (p *violationPrinter) print(path string, violations []engine.ImportViolation) {
+	if len(violations) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, violation := range violations {
+		if violation.Replacement == "" || !p.applied {
+			p.failed = true
+		}
+		fmt.Fprintf(p.w, "%s:%d:%d: %s\n", path, violation.Line, violation.Column, violation)
+	}
+}
+
+// wrap returns a FixFunc that prints the violations of the files revised by fn.
+func (p *violationPrinter) wrap(fn engine.FixFunc) engine.FixFunc {
+	return func(ctx context.Context, projectName, filePath string, options ...engine.SourceFileOption) (*engine.Result, error) {
+		result, err := fn(ctx, projectName, filePath, options...)
+		if err == nil {
+			p.print(filePath, result.Violations)
+		}
+		return result, err
+	}
+}
+
+// err returns errImportViolations if a printed violation remains.
+func (p *violationPrinter) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failed {
+		return errImportViolations
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/stale"
+	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+	"github.com/zchee/goimports-rereviser/v4/internal/watch"
+)
+
+// validateWatch reports whether cfg and targets can be combined with -watch.
+func validateWatch(cfg *Config, targets []string) error {
+	if cfg.output != "file" && cfg.output != "write" {
+		return fmt.Errorf("-watch cannot be used with -output %s", cfg.output)
+	}
+	if cfg.listFileName || cfg.staged || cfg.gitDiff != "" {
+		return errors.New("-watch cannot be used with -list-diff, -git-diff or -staged")
+	}
+	for _, target := range targets {
+		if _, ok := internalwalk.IsDir(target); !ok {
+			return fmt.Errorf("-watch requires directory targets, %s is not a directory", target)
+		}
+	}
+	return nil
+}
+
+// watchPaths fixes the Go files of the directory targets again whenever they
+// are written, until ctx is done. Files are selected and walked like in a
+// directory run, so -recursive, -excludes, -includes, -max-depth and
+// -follow-symlinks apply, and fixed through the directories of the targets,
+// so they share the project names and cache entries of a directory run. The
+// package and module data cached for the written files is forgotten before
+// they are fixed again.
+func watchPaths(ctx context.Context, cfg *Config, targets []string, cacheDir string, options engine.SourceFileOptions, fix *fixer) error {
+	var (
+		dirs  []*engine.SourceDir
+		roots []string
+	)
+	for _, target := range targets {
+		dir := newTargetDir(cfg, "", target)
+		dirs = append(dirs, dir)
+		roots = append(roots, dir.Path())
+	}
+
+	opts := watch.Options{
+		// The targets share the options of cfg.
+		Walk: dirs[0].WalkOptions(),
+		IncludeDir: func(path string) bool {
+			for _, dir := range dirs {
+				if dir.IncludesDir(path) {
+					return true
+				}
+			}
+			return false
+		},
+		IncludeFile: func(path string) bool {
+			for _, dir := range dirs {
+				if dir.Contains(path) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	slog.Info("watching", "paths", roots)
+	return watch.Run(ctx, roots, opts, func(paths []string) {
+		slog.Info("changed files", "paths", paths)
+		// Package and module data loaded for earlier fixes may no longer
+		// match the written files.
+		for _, path := range paths {
+			stale.Forget(path)
+		}
+		// Violations were printed already and must not stop watching.
+		if _, err := processTargets(ctx, cfg, targets, paths, cacheDir, options, fix); err != nil && ctx.Err() == nil && !errors.Is(err, errImportViolations) {
+			slog.Error("failed to fix changed files", "err", err)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrUnavailable is returned by Client.Fix when no daemon of the same build
+// and environment could serve the request, so the caller should fix the file itself.
+var ErrUnavailable = errors.New("daemon is unavailable")
+
+// Client sends requests to the daemon listening on a Unix socket.
+type Client struct {
+	socketPath string
+	dialer     net.Dialer
+}
+
+// NewClient creates a client for the daemon listening on socketPath. It does
+// not connect until the first request.
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// Fix sends req over a new connection and returns the response. Errors that
+// come from revising the file are returned as is, while connection failures
+// and version or environment mismatches wrap ErrUnavailable.
+func (c *Client) Fix(ctx context.Context, req *Request) (*Response, error) {
+	conn, err := c.dialer.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	// Unblock reads and writes once ctx is done.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, c.connError(ctx, err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, c.connError(ctx, err)
+	}
+
+	if resp.VersionMismatch || resp.EnvMismatch {
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, resp.Error)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+func (c *Client) connError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
@@ -0,0 +1,211 @@
+// Package daemon serves import fixing over a Unix socket, so that the package
+// and module caches outlive a single command invocation.
+//
+// The protocol is a stream of JSON values: a client writes a Request and reads
+// the matching Response, any number of times per connection.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+	"github.com/zchee/goimports-rereviser/v4/internal/stale"
+)
+
+// SocketName is the file name of the socket inside the cache directory.
+const SocketName = "daemon.sock"
+
+// Options are the formatting options of a request. They mirror the formatting
+// flags of the command line.
+type Options struct {
+	ImportsOrder          string `json:"imports_order,omitempty"`
+	ImportGroups          string `json:"import_groups,omitempty"`
+	ImportAliases         string `json:"import_aliases,omitempty"`
+	ImportRewrites        string `json:"import_rewrites,omitempty"`
+	ImportRules           string `json:"import_rules,omitempty"`
+	CompanyPrefixes       string `json:"company_prefixes,omitempty"`
+	RemoveUnused          bool   `json:"rm_unused,omitempty"`
+	TypeCheckUnused       bool   `json:"typecheck_unused,omitempty"`
+	AddMissing            bool   `json:"add_missing,omitempty"`
+	SetAlias              bool   `json:"set_alias,omitempty"`
+	RemoveRedundantAlias  bool   `json:"rm_redundant_alias,omitempty"`
+	Format                bool   `json:"format,omitempty"`
+	SeparateNamed         bool   `json:"separate_named,omitempty"`
+	SkipBlanked           bool   `json:"skip_blanked,omitempty"`
+	ApplyToGeneratedFiles bool   `json:"apply_to_generated_files,omitempty"`
+	Workspace             bool   `json:"workspace,omitempty"`
+}
+
+// Request asks the daemon to revise Src as if it were the content of
+// Filename. A relative Filename, including engine.StandardInput, is resolved
+// against Dir.
+type Request struct {
+	// Version identifies the build of the client. The daemon refuses requests
+	// of other builds, which may format differently.
+	Version string `json:"version"`
+	// Env is the environment of the client that go commands depend on, as
+	// returned by Environ. The daemon refuses requests from another
+	// environment, since its package data is loaded with its own.
+	Env         []string `json:"env,omitempty"`
+	Dir         string   `json:"dir"`
+	Filename    string   `json:"filename"`
+	Src         []byte   `json:"src"`
+	ProjectName string   `json:"project_name,omitempty"`
+	Options     Options  `json:"options"`
+}
+
+// Response is the outcome of a Request.
+type Response struct {
+	Content    []byte            `json:"content,omitempty"`
+	Changed    bool              `json:"changed,omitempty"`
+	SkipReason engine.SkipReason `json:"skip_reason,omitempty"`
+	engine.ImportChanges
+	Violations []engine.ImportViolation `json:"violations,omitempty"`
+	// Error is the error of revising the file.
+	Error string `json:"error,omitempty"`
+	// VersionMismatch is set when the request was refused because it came
+	// from another build.
+	VersionMismatch bool `json:"version_mismatch,omitempty"`
+	// EnvMismatch is set when the request was refused because it came from
+	// another environment.
+	EnvMismatch bool `json:"env_mismatch,omitempty"`
+}
+
+// Handler revises the file of a request. Filename is already resolved to an
+// absolute path.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Server serves requests of the same build and environment over a listener.
+type Server struct {
+	handler Handler
+	version string
+	env     []string
+	tracker *stale.Tracker
+}
+
+// NewServer creates a server that handles requests of the given version and
+// of the environment of the process with handler. Before each request it
+// forgets cached package and module data whose files changed on disk.
+func NewServer(handler Handler, version string) *Server {
+	return &Server{
+		handler: handler,
+		version: version,
+		env:     goenv.Environ(),
+		tracker: stale.NewTracker(),
+	}
+}
+
+// Listen listens on the Unix socket at path, creating its directory with
+// private permissions. A socket left behind by a daemon that is gone is
+// replaced, while a live daemon makes Listen fail.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve accepts connections on ln until ctx is done, then closes ln and waits
+// for the requests in flight.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Go(func() {
+			defer conn.Close()
+			closeConn := context.AfterFunc(ctx, func() { _ = conn.Close() })
+			defer closeConn()
+
+			if err := s.serveConn(ctx, conn); err != nil && ctx.Err() == nil {
+				slog.Warn("daemon connection failed", "err", err)
+			}
+		})
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn io.ReadWriter) error {
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if err := enc.Encode(s.handle(ctx, &req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req *Request) *Response {
+	if req.Version != s.version {
+		return &Response{
+			Error:           fmt.Sprintf("daemon version %q does not match client version %q", s.version, req.Version),
+			VersionMismatch: true,
+		}
+	}
+	if !slices.Equal(req.Env, s.env) {
+		return &Response{
+			Error:       fmt.Sprintf("daemon environment %q does not match client environment %q", s.env, req.Env),
+			EnvMismatch: true,
+		}
+	}
+
+	if !filepath.IsAbs(req.Filename) {
+		req.Filename = filepath.Join(req.Dir, req.Filename)
+	}
+	s.tracker.Refresh(req.Filename)
+
+	resp, err := s.handler(ctx, req)
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+	return resp
+}
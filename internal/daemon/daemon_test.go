@@ -0,0 +1,187 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+)
+
+const testVersion = "test"
+
+var testEnv = goenv.Environ()
+
+// startServer serves handler on a socket in a temp dir until the test ends
+// and returns the socket path.
+func startServer(t *testing.T, handler Handler) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), SocketName)
+	ln, err := Listen(socketPath)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewServer(handler, testVersion).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+	return socketPath
+}
+
+func TestClientFix(t *testing.T) {
+	t.Parallel()
+
+	socketPath := startServer(t, func(_ context.Context, req *Request) (*Response, error) {
+		if strings.Contains(string(req.Src), "invalid") {
+			return nil, errors.New("invalid source")
+		}
+		return &Response{
+			Content:       []byte(req.Filename + ":" + req.Options.ImportsOrder),
+			Changed:       true,
+			ImportChanges: engine.ImportChanges{Removed: []string{"fmt"}},
+		}, nil
+	})
+	client := NewClient(socketPath)
+
+	tests := map[string]struct {
+		req     Request
+		want    *Response
+		wantErr string
+	}{
+		"absolute filename": {
+			req: Request{Version: testVersion, Env: testEnv, Dir: "/work", Filename: "/src/a.go", Options: Options{ImportsOrder: "std"}},
+			want: &Response{
+				Content:       []byte("/src/a.go:std"),
+				Changed:       true,
+				ImportChanges: engine.ImportChanges{Removed: []string{"fmt"}},
+			},
+		},
+		"relative filename is resolved against dir": {
+			req: Request{Version: testVersion, Env: testEnv, Dir: "/work", Filename: engine.StandardInput},
+			want: &Response{
+				Content:       []byte(filepath.Join("/work", engine.StandardInput) + ":"),
+				Changed:       true,
+				ImportChanges: engine.ImportChanges{Removed: []string{"fmt"}},
+			},
+		},
+		"handler error": {
+			req:     Request{Version: testVersion, Env: testEnv, Filename: "/src/a.go", Src: []byte("invalid")},
+			wantErr: "invalid source",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := client.Fix(t.Context(), &tt.req)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr || errors.Is(err, ErrUnavailable) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fix returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("Fix mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientFixUnavailable(t *testing.T) {
+	t.Parallel()
+
+	socketPath := startServer(t, func(context.Context, *Request) (*Response, error) {
+		t.Error("handler must not be called for another version or environment")
+		return &Response{}, nil
+	})
+
+	if _, err := NewClient(socketPath).Fix(t.Context(), &Request{Version: "other"}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable for a version mismatch, got %v", err)
+	}
+	otherEnv := append(slices.Clone(testEnv), "GOOS=plan9")
+	if _, err := NewClient(socketPath).Fix(t.Context(), &Request{Version: testVersion, Env: otherEnv}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable for an environment mismatch, got %v", err)
+	}
+
+	missing := filepath.Join(t.TempDir(), SocketName)
+	if _, err := NewClient(missing).Fix(t.Context(), &Request{Version: testVersion}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable without a daemon, got %v", err)
+	}
+}
+
+func TestClientFixCanceled(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	socketPath := startServer(t, func(ctx context.Context, _ *Request) (*Response, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return &Response{}, nil
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := NewClient(socketPath).Fix(ctx, &Request{Version: testVersion, Env: testEnv}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "nested", SocketName)
+
+	// A socket whose listener is gone is replaced.
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := Listen(socketPath)
+	if err != nil {
+		t.Fatalf("Listen returned error for a stale socket: %v", err)
+	}
+	defer ln.Close()
+
+	fi, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("failed to stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected socket permissions 0600, got %o", perm)
+	}
+
+	if _, err := Listen(socketPath); err == nil {
+		t.Fatalf("expected Listen to refuse a socket with a live daemon")
+	}
+}
@@ -0,0 +1,191 @@
+// Package diff computes line-oriented unified diffs between two versions of a
+// source file.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines printed around each change,
+// matching the default of diff -u and gofmt -d.
+const contextLines = 3
+
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+// op is a single line of the edit script. oldLine and newLine are the
+// zero-based positions in the old and new inputs before the op is applied.
+type op struct {
+	kind    opKind
+	line    string
+	oldLine int
+	newLine int
+}
+
+// Unified returns a unified diff turning old into new, in the format printed
+// by gofmt -d. It returns nil when old and new are equal.
+func Unified(oldName, newName string, old, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+
+	ops := edits(splitLines(old), splitLines(new))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "diff %s %s\n", oldName, newName)
+	fmt.Fprintf(&out, "--- %s\n", oldName)
+	fmt.Fprintf(&out, "+++ %s\n", newName)
+
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := max(i-contextLines, 0)
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != opEqual {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == opEqual {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*contextLines {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := min(end+contextLines, len(ops))
+
+		writeHunk(&out, ops[start:stop])
+		i = stop
+	}
+
+	return out.Bytes()
+}
+
+func writeHunk(out *bytes.Buffer, hunk []op) {
+	var oldCount, newCount int
+	for _, o := range hunk {
+		switch o.kind {
+		case opEqual:
+			oldCount++
+			newCount++
+		case opDelete:
+			oldCount++
+		case opInsert:
+			newCount++
+		}
+	}
+
+	oldStart, newStart := hunk[0].oldLine, hunk[0].newLine
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, o := range hunk {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteByte('\n')
+			out.WriteString(noNewlineMarker)
+		}
+	}
+}
+
+// splitLines splits data into lines, keeping the trailing newline of each.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits returns the shortest edit script turning x into y using Myers'
+// O(ND) algorithm.
+func edits(x, y []string) []op {
+	n, m := len(x), len(y)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				break search
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := i - j
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v[offset+prevK]
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			i--
+			j--
+			ops = append(ops, op{kind: opEqual, line: x[i], oldLine: i, newLine: j})
+		}
+		if d == 0 {
+			break
+		}
+		if i == prevI {
+			j--
+			ops = append(ops, op{kind: opInsert, line: y[j], oldLine: i, newLine: j})
+		} else {
+			i--
+			ops = append(ops, op{kind: opDelete, line: x[i], oldLine: i, newLine: j})
+		}
+	}
+	slices.Reverse(ops)
+
+	return ops
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		old  string
+		new  string
+		want string
+	}{
+		"equal inputs produce no diff": {
+			old:  "package main\n",
+			new:  "package main\n",
+			want: "",
+		},
+		"reordered imports": {
+			old: `package main
+
+import (
+	"github.com/pkg/errors"
+	"fmt"
+)
+`,
+			new: `package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+`,
+			want: `diff a.go.orig a.go
+--- a.go.orig
++++ a.go
+@@ -1,6 +1,7 @@
+ package main
+ ` + `
+ import (
+-	"github.com/pkg/errors"
+ 	"fmt"
++
++	"github.com/pkg/errors"
+ )
+`,
+		},
+		"distant changes are split into hunks": {
+			old:  "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			new:  "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			want: "diff a.go.orig a.go\n--- a.go.orig\n+++ a.go\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		"missing trailing newline is marked": {
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "diff a.go.orig a.go\n--- a.go.orig\n+++ a.go\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		"insertion into empty input": {
+			old:  "",
+			new:  "a\n",
+			want: "diff a.go.orig a.go\n--- a.go.orig\n+++ a.go\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := Unified("a.go.orig", "a.go", []byte(tt.old), []byte(tt.new))
+			if diff := gocmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Unified mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,23 +1,29 @@
 package engine
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/alitto/pond"
-	"github.com/charlievieth/fastwalk"
 
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
 	internalcache "github.com/zchee/goimports-rereviser/v4/internal/cache"
+	"github.com/zchee/goimports-rereviser/v4/internal/diff"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+	"github.com/zchee/goimports-rereviser/v4/internal/pkgdeps"
 	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
 )
 
-type walkCallbackFunc = func(hasChanged bool, path string, content []byte) error
+type walkCallbackFunc = func(hasChanged bool, path string, original, content []byte) error
 
 type cachePolicy int
 
@@ -40,6 +46,10 @@ type SourceDir struct {
 	dir                 string
 	isRecursive         bool
 	excludePatterns     []string // see filepath.Match
+	includePatterns     []string // see filepath.Match
+	followSymlinks      bool
+	maxDepth            int
+	files               []string // nil walks the whole directory
 	workerPool          *pond.WorkerPool
 	sequentialThreshold int
 	cacheDir            string
@@ -47,11 +57,24 @@ type SourceDir struct {
 	useMetadataCache    bool
 	cacheFingerprint    string
 	writeFile           func(name string, data []byte, perm fs.FileMode) error
+	reportFunc          func(FileReport)
+	fixFunc             FixFunc
+	preloadPackages     bool
+
+	moduleRoot     string
+	moduleRootOnce sync.Once
 }
 
-func NewSourceDir(projectName, path string, isRecursive bool, excludes string) *SourceDir {
-	patterns := make([]string, 0)
+// FixFunc revises the Go file at filePath. It must behave like
+// NewSourceFile(projectName, filePath).FixResultContext(ctx, options...),
+// which is the default used by SourceDir.
+type FixFunc func(ctx context.Context, projectName, filePath string, options ...SourceFileOption) (*Result, error)
 
+func fixFile(ctx context.Context, projectName, filePath string, options ...SourceFileOption) (*Result, error) {
+	return NewSourceFile(projectName, filePath).FixResultContext(ctx, options...)
+}
+
+func NewSourceDir(projectName, path string, isRecursive bool, excludes string) *SourceDir {
 	// get the absolute path
 	absPath, err := filepath.Abs(path)
 
@@ -61,21 +84,9 @@ func NewSourceDir(projectName, path string, isRecursive bool, excludes string) *
 		absPath = strings.TrimSuffix(absPath, "/...")
 	}
 
+	patterns := make([]string, 0)
 	if err == nil {
-		segs := strings.SplitSeq(excludes, ",")
-		for seg := range segs {
-			p := strings.TrimSpace(seg)
-			if p != "" {
-				if !filepath.IsAbs(p) {
-					// resolve the absolute path
-					p = filepath.Join(absPath, p)
-				}
-				// Check pattern is well-formed.
-				if _, err = filepath.Match(p, ""); err == nil {
-					patterns = append(patterns, p)
-				}
-			}
-		}
+		patterns = pathPatterns(absPath, excludes)
 	}
 	return &SourceDir{
 		projectName:         projectName,
@@ -84,10 +95,69 @@ func NewSourceDir(projectName, path string, isRecursive bool, excludes string) *
 		excludePatterns:     patterns,
 		sequentialThreshold: defaultParallelThreshold,
 		useMetadataCache:    true,
-		writeFile:           os.WriteFile,
+		writeFile:           atomicfile.WriteFile,
+		fixFunc:             fixFile,
 	}
 }
 
+// pathPatterns parses comma-separated filepath.Match patterns, resolving
+// relative ones against dir and dropping malformed ones.
+func pathPatterns(dir, s string) []string {
+	var patterns []string
+	for seg := range strings.SplitSeq(s, ",") {
+		p := strings.TrimSpace(seg)
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			// resolve the absolute path
+			p = filepath.Join(dir, p)
+		}
+		// Check pattern is well-formed.
+		if _, err := filepath.Match(p, ""); err == nil {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// WithIncludes limits Fix, Find and Diff to the Go files that match one of
+// the comma-separated patterns, or lie in a directory matching one. Patterns
+// have the syntax of excludes, which still apply.
+func (d *SourceDir) WithIncludes(includes string) *SourceDir {
+	d.includePatterns = pathPatterns(d.dir, includes)
+	return d
+}
+
+// WithFollowSymlinks makes recursive runs walk symbolic links to directories.
+// A directory reached through several paths is processed only once.
+func (d *SourceDir) WithFollowSymlinks() *SourceDir {
+	d.followSymlinks = true
+	return d
+}
+
+// WithMaxDepth limits recursive runs to the directories at most depth levels
+// below the directory. Zero means no limit.
+func (d *SourceDir) WithMaxDepth(depth int) *SourceDir {
+	d.maxDepth = depth
+	return d
+}
+
+// WithFiles limits Fix, Find and Diff to the given files instead of walking
+// the directory, e.g. to fix the files a watcher reported. Files are visited
+// only when Contains reports them and they still exist. Everything else,
+// like the project name and cache fingerprint of a file, is handled as if
+// the directory was walked.
+func (d *SourceDir) WithFiles(paths []string) *SourceDir {
+	d.files = make([]string, 0, len(paths))
+	for _, path := range paths {
+		if absPath, err := filepath.Abs(path); err == nil {
+			d.files = append(d.files, absPath)
+		}
+	}
+	return d
+}
+
 // WithWorkerPool configures SourceDir to reuse an existing worker pool.
 func (d *SourceDir) WithWorkerPool(pool *pond.WorkerPool) *SourceDir {
 	d.workerPool = pool
@@ -123,6 +193,31 @@ func (d *SourceDir) WithoutMetadataCache() *SourceDir {
 	return d
 }
 
+// WithReport registers fn to receive a FileReport for every Go file visited by
+// Fix, Find or Diff, including files skipped by a cache hit and files that
+// failed. fn may be called concurrently.
+func (d *SourceDir) WithReport(fn func(FileReport)) *SourceDir {
+	d.reportFunc = fn
+	return d
+}
+
+// WithFixFunc replaces how every Go file is revised, e.g. to delegate the work
+// to another process. Caching, reports and writes are still handled by
+// SourceDir.
+func (d *SourceDir) WithFixFunc(fn FixFunc) *SourceDir {
+	d.fixFunc = fn
+	return d
+}
+
+// WithPackagePreload makes recursive runs load the package information of the
+// whole tree with a single go/packages call before walking it, instead of one
+// call per directory. It pays off when the options need package information,
+// like WithRemovingUnusedImports, and most directories are processed.
+func (d *SourceDir) WithPackagePreload() *SourceDir {
+	d.preloadPackages = true
+	return d
+}
+
 // WithSequentialThreshold overrides the minimum number of files before
 // parallel execution is enabled. Primarily used for testing.
 func (d *SourceDir) WithSequentialThreshold(threshold int) *SourceDir {
@@ -131,25 +226,40 @@ func (d *SourceDir) WithSequentialThreshold(threshold int) *SourceDir {
 }
 
 func (d *SourceDir) Fix(options ...SourceFileOption) (bool, error) {
+	return d.FixContext(context.Background(), options...)
+}
+
+// FixContext is like Fix, but stops walking once ctx is done. Files that were
+// not processed yet are left untouched, and ctx.Err() is returned after the
+// in-flight files finished.
+func (d *SourceDir) FixContext(ctx context.Context, options ...SourceFileOption) (bool, error) {
 	var ok bool
 	d.dir, ok = IsDir(d.dir)
 	if !ok {
 		return false, ErrPathIsNotDir
 	}
 
-	submit, wait := d.makeSubmitter()
+	if err := d.preload(ctx); err != nil {
+		return false, err
+	}
+
+	submit, wait := d.makeSubmitter(ctx)
 
 	var collectErr error
 	var processingErr error
 	var errMu sync.Mutex
 	var changed atomic.Bool
 
-	err := fastwalk.Walk(&fastwalk.DefaultConfig, d.dir, d.walk(
+	err := d.walkTree(d.walk(
+		ctx,
 		submit,
-		func(hasChanged bool, path string, content []byte) error {
+		func(hasChanged bool, path string, _, content []byte) error {
 			if !hasChanged {
 				return nil
 			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			changed.Store(true)
 			if err := d.writeFile(path, content, 0o644); err != nil {
 				return fmt.Errorf("failed to write fixed result to file(%s): %w", path, err)
@@ -162,6 +272,9 @@ func (d *SourceDir) Fix(options ...SourceFileOption) (bool, error) {
 		options...,
 	))
 	wait()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return changed.Load(), ctxErr
+	}
 	if err != nil {
 		collectErr = fmt.Errorf("failed to walk dir: %w", err)
 	}
@@ -178,30 +291,68 @@ func (d *SourceDir) Fix(options ...SourceFileOption) (bool, error) {
 
 // Find collection of bad formatted paths
 func (d *SourceDir) Find(options ...SourceFileOption) (*UnformattedCollection, error) {
+	return d.FindContext(context.Background(), options...)
+}
+
+// FindContext is like Find, but stops walking once ctx is done and returns
+// ctx.Err() after the in-flight files finished.
+func (d *SourceDir) FindContext(ctx context.Context, options ...SourceFileOption) (*UnformattedCollection, error) {
+	return d.find(ctx, false, options...)
+}
+
+// Diff is like Find, but additionally records a unified diff of the pending
+// changes for every bad formatted path. Files are never written.
+func (d *SourceDir) Diff(options ...SourceFileOption) (*UnformattedCollection, error) {
+	return d.DiffContext(context.Background(), options...)
+}
+
+// DiffContext is like Diff, but stops walking once ctx is done and returns
+// ctx.Err() after the in-flight files finished.
+func (d *SourceDir) DiffContext(ctx context.Context, options ...SourceFileOption) (*UnformattedCollection, error) {
+	return d.find(ctx, true, options...)
+}
+
+func (d *SourceDir) find(ctx context.Context, withDiff bool, options ...SourceFileOption) (*UnformattedCollection, error) {
 	var (
 		ok                     bool
 		badFormattedCollection []string
+		diffs                  map[string][]byte
 		collectionMu           sync.Mutex
 	)
+	if withDiff {
+		diffs = make(map[string][]byte)
+	}
 	d.dir, ok = IsDir(d.dir)
 	if !ok {
 		return nil, ErrPathIsNotDir
 	}
 
-	submit, wait := d.makeSubmitter()
+	if err := d.preload(ctx); err != nil {
+		return nil, err
+	}
+
+	submit, wait := d.makeSubmitter(ctx)
 
 	var collectErr error
 	var processingErr error
 	var errMu sync.Mutex
 
-	err := filepath.WalkDir(d.dir, d.walk(
+	err := d.walkTree(d.walk(
+		ctx,
 		submit,
-		func(hasChanged bool, path string, content []byte) error {
+		func(hasChanged bool, path string, original, content []byte) error {
 			if !hasChanged {
 				return nil
 			}
+			var unified []byte
+			if withDiff {
+				unified = diff.Unified(path+".orig", path, original, content)
+			}
 			collectionMu.Lock()
 			badFormattedCollection = append(badFormattedCollection, path)
+			if withDiff {
+				diffs[path] = unified
+			}
 			collectionMu.Unlock()
 			return nil
 		},
@@ -211,6 +362,9 @@ func (d *SourceDir) Find(options ...SourceFileOption) (*UnformattedCollection, e
 		options...,
 	))
 	wait()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		collectErr = fmt.Errorf("failed to walk dir: %w", err)
 	}
@@ -227,17 +381,61 @@ func (d *SourceDir) Find(options ...SourceFileOption) (*UnformattedCollection, e
 		return nil, nil
 	}
 
-	return newUnformattedCollection(badFormattedCollection), nil
+	// Files finish in any order; sort them so the list is stable.
+	slices.Sort(badFormattedCollection)
+	collection := newUnformattedCollection(badFormattedCollection)
+	collection.diffs = diffs
+	return collection, nil
+}
+
+// walkTree calls fn for the directory tree like internalwalk.Walk, or only
+// for the files set by WithFiles.
+func (d *SourceDir) walkTree(fn fs.WalkDirFunc) error {
+	if d.files == nil {
+		return internalwalk.Walk(d.dir, d.WalkOptions(), fn)
+	}
+
+	for _, path := range d.files {
+		if !d.Contains(path) {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if err := fn(path, fs.FileInfoToDirEntry(info), nil); err != nil {
+			if errors.Is(err, filepath.SkipAll) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkOptions returns the options that Fix, Find and Diff walk the directory
+// with.
+func (d *SourceDir) WalkOptions() internalwalk.Options {
+	return internalwalk.Options{
+		FollowSymlinks: d.followSymlinks,
+		MaxDepth:       d.maxDepth,
+	}
 }
 
 // walk submits file processing to worker pool for concurrent execution.
-func (d *SourceDir) walk(submit func(func()), callback walkCallbackFunc, errMu *sync.Mutex, processingErr *error, cacheMode cachePolicy, options ...SourceFileOption) fs.WalkDirFunc {
+func (d *SourceDir) walk(ctx context.Context, submit func(func()), callback walkCallbackFunc, errMu *sync.Mutex, processingErr *error, cacheMode cachePolicy, options ...SourceFileOption) fs.WalkDirFunc {
 	return func(path string, dirEntry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			return err
 		}
 
-		if !d.isRecursive && dirEntry.IsDir() && filepath.Base(d.dir) != dirEntry.Name() {
+		if !d.isRecursive && dirEntry.IsDir() && path != d.dir {
 			return filepath.SkipDir
 		}
 		if dirEntry.IsDir() && d.isExcluded(path) {
@@ -245,7 +443,7 @@ func (d *SourceDir) walk(submit func(func()), callback walkCallbackFunc, errMu *
 		}
 
 		// Submit Go file processing to worker pool
-		if isGoFile(path) && !dirEntry.IsDir() && !d.isExcluded(path) {
+		if isGoFile(path) && !dirEntry.IsDir() && !d.isExcluded(path) && d.isIncluded(path) {
 			filePath := path
 
 			submit(func() {
@@ -254,62 +452,74 @@ func (d *SourceDir) walk(submit func(func()), callback walkCallbackFunc, errMu *
 					absPath = filepath.Join(d.dir, filePath)
 				}
 
+				report := FileReport{Path: absPath}
+				defer d.reportFile(&report)
+
+				recordErr := func(err error) {
+					report.Error = err.Error()
+					errMu.Lock()
+					if *processingErr == nil {
+						*processingErr = err
+					}
+					errMu.Unlock()
+				}
+
+				projectName, root, err := d.projectNameOf(absPath)
+				if err != nil {
+					recordErr(fmt.Errorf("failed to determine project name of %s: %w", absPath, err))
+					return
+				}
+				cacheFingerprint := d.cacheFingerprintOf(projectName, root)
+
 				if d.cacheEnabled && cacheMode == cacheReadWrite {
-					skip, cacheErr := internalcache.ShouldSkipWithFingerprint(d.cacheDir, absPath, d.useMetadataCache, d.cacheFingerprint)
+					skip, cacheErr := internalcache.ShouldSkipWithFingerprint(d.cacheDir, absPath, d.useMetadataCache, cacheFingerprint)
 					if cacheErr != nil {
-						errMu.Lock()
-						if *processingErr == nil {
-							*processingErr = cacheErr
-						}
-						errMu.Unlock()
+						recordErr(cacheErr)
 						return
 					}
 					if skip {
+						report.CacheHit = true
+						report.Skipped = SkipReasonCacheHit
 						return
 					}
 				}
 
-				content, _, hasChange, err := NewSourceFile(d.projectName, absPath).Fix(options...)
+				result, err := d.fixFunc(ctx, projectName, absPath, options...)
 				if err != nil {
-					errMu.Lock()
-					if *processingErr == nil {
-						*processingErr = fmt.Errorf("failed to fix %s: %w", absPath, err)
+					if ctx.Err() != nil {
+						report.Error = err.Error()
+						return
 					}
-					errMu.Unlock()
+					recordErr(fmt.Errorf("failed to fix %s: %w", absPath, err))
 					return
 				}
-
-				if err := callback(hasChange, absPath, content); err != nil {
-					errMu.Lock()
-					if *processingErr == nil {
-						*processingErr = err
-					}
-					errMu.Unlock()
+				content := result.Content
+				report.Changed = result.Changed
+				report.Skipped = result.SkipReason
+				report.ImportChanges = result.ImportChanges
+				report.Violations = result.Violations
+
+				if err := callback(result.Changed, absPath, result.Original, content); err != nil {
+					recordErr(err)
 					return
 				}
 
-				if d.cacheEnabled && cacheMode == cacheReadWrite {
+				// Files breaking import rules are not cached, so that every run
+				// reports them.
+				if d.cacheEnabled && cacheMode == cacheReadWrite && len(result.Violations) == 0 {
 					hash := internalcache.ComputeContentHash(content)
 					if hash == "" {
 						return
 					}
 
-					entry, metaErr := internalcache.NewCacheEntryWithFingerprint(absPath, hash, d.useMetadataCache, d.cacheFingerprint)
+					entry, metaErr := internalcache.NewCacheEntryWithFingerprint(absPath, hash, d.useMetadataCache, cacheFingerprint)
 					if metaErr != nil {
-						errMu.Lock()
-						if *processingErr == nil {
-							*processingErr = metaErr
-						}
-						errMu.Unlock()
+						recordErr(metaErr)
 						return
 					}
 
 					if cacheErr := d.writeCache(absPath, entry); cacheErr != nil {
-						errMu.Lock()
-						if *processingErr == nil {
-							*processingErr = cacheErr
-						}
-						errMu.Unlock()
+						recordErr(cacheErr)
 					}
 				}
 			})
@@ -318,8 +528,139 @@ func (d *SourceDir) walk(submit func(func()), callback walkCallbackFunc, errMu *
 	}
 }
 
-func (d *SourceDir) makeSubmitter() (func(func()), func()) {
-	return internalwalk.NewSubmitter(d.workerPool, d.sequentialThreshold)
+// projectNameOf returns the project name of the Go file at path and the root
+// of its module. Files of modules nested below the module of the directory are
+// classified against the path of their own module; all other files use the
+// project name of d, which is required for files outside of any module.
+func (d *SourceDir) projectNameOf(path string) (string, string, error) {
+	d.moduleRootOnce.Do(func() {
+		d.moduleRoot, _ = modulepath.RootOfDir(d.dir)
+	})
+
+	root, err := modulepath.RootOfDir(filepath.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	if root == "" && d.projectName == "" {
+		return "", "", &modulepath.UndefinedModuleError{}
+	}
+	if root == "" || root == d.moduleRoot {
+		return d.projectName, root, nil
+	}
+	name, err := modulepath.Name(root)
+	if err != nil {
+		return "", "", err
+	}
+	return name, root, nil
+}
+
+// cacheFingerprintOf returns the cache fingerprint of the files of the
+// project projectName, whose module is rooted at root. The fingerprint of d
+// describes the options and its own project name, so the name of a nested
+// module is added to it, and it is extended with ModuleCacheFingerprint.
+func (d *SourceDir) cacheFingerprintOf(projectName, root string) string {
+	if d.cacheFingerprint == "" {
+		return ""
+	}
+	fingerprint := d.cacheFingerprint
+	if projectName != d.projectName {
+		fingerprint += "|module=" + projectName
+	}
+	return ModuleCacheFingerprint(fingerprint, root)
+}
+
+// ModuleCacheFingerprint extends the cache fingerprint of a file of the
+// module rooted at root with the modules developed together with it, since
+// editing the go.work or replace directives that select them changes how the
+// file is revised. Empty fingerprints and roots are returned unchanged.
+func ModuleCacheFingerprint(fingerprint, root string) string {
+	if fingerprint == "" || root == "" {
+		return fingerprint
+	}
+	modules, err := modulepath.WorkspaceModules(root)
+	if err != nil {
+		// Revising the file fails the same way, so nothing is cached.
+		return fingerprint
+	}
+	return fingerprint + "|workspace-modules=" + strings.Join(modules, ",")
+}
+
+// preload loads the packages of a recursive walk up front when enabled. Only
+// cancellation is an error: when the batch load fails, e.g. because the tree
+// is not part of a module, every directory is loaded on its own as usual.
+func (d *SourceDir) preload(ctx context.Context) error {
+	// Loading the whole tree does not pay off for a few files.
+	if !d.preloadPackages || !d.isRecursive || d.files != nil {
+		return nil
+	}
+	if err := pkgdeps.Preload(ctx, d.dir); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (d *SourceDir) reportFile(report *FileReport) {
+	if d.reportFunc != nil {
+		d.reportFunc(*report)
+	}
+}
+
+func (d *SourceDir) makeSubmitter(ctx context.Context) (func(func()), func()) {
+	return internalwalk.NewSubmitter(ctx, d.workerPool, d.sequentialThreshold)
+}
+
+// Path returns the absolute path of the directory.
+func (d *SourceDir) Path() string {
+	return d.dir
+}
+
+// Contains reports whether path is a Go file that Fix, Find and Diff would
+// visit: it lies below the directory, directly inside it unless recursive,
+// neither the file nor one of its parent directories is excluded, and it
+// matches the includes, if any.
+func (d *SourceDir) Contains(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil || !isGoFile(absPath) {
+		return false
+	}
+
+	return d.IncludesDir(filepath.Dir(absPath)) && !d.isExcluded(absPath) && d.isIncluded(absPath)
+}
+
+// IncludesDir reports whether Fix, Find and Diff visit the files of the
+// directory path: it is the directory itself, or a directory below it when
+// recursive and within the max depth, and neither it nor one of its parents
+// is excluded. Includes are not considered, since they select files.
+func (d *SourceDir) IncludesDir(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(d.dir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if !d.isRecursive && rel != "." {
+		return false
+	}
+	if d.maxDepth > 0 && rel != "." && strings.Count(rel, string(filepath.Separator)) >= d.maxDepth {
+		return false
+	}
+
+	if d.isExcluded(d.dir) {
+		return false
+	}
+	dir := d.dir
+	if rel != "." {
+		for elem := range strings.SplitSeq(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, elem)
+			if d.isExcluded(dir) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func (d *SourceDir) isExcluded(path string) bool {
@@ -343,6 +684,33 @@ func (d *SourceDir) isExcluded(path string) bool {
 	return false
 }
 
+// isIncluded reports whether the file at path, or one of its parent
+// directories below the directory, matches the includes. Without includes
+// every file is included.
+func (d *SourceDir) isIncluded(path string) bool {
+	if len(d.includePatterns) == 0 {
+		return true
+	}
+
+	absPath := path
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(d.dir, path)
+	}
+	for p := absPath; p != d.dir; {
+		for _, pattern := range d.includePatterns {
+			if matched, err := filepath.Match(pattern, p); err == nil && matched {
+				return true
+			}
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	return false
+}
+
 // isGoToolIgnored implements the go command's implicit exclusion rules:
 // directories named vendor or testdata and any path component beginning with
 // '.' or '_' are skipped when expanding patterns such as ./... .
@@ -351,7 +719,8 @@ func isGoToolIgnored(path string) bool {
 }
 
 type UnformattedCollection struct {
-	list []string
+	list  []string
+	diffs map[string][]byte
 }
 
 func newUnformattedCollection(list []string) *UnformattedCollection {
@@ -381,6 +750,22 @@ func (c *UnformattedCollection) String() string {
 	return builder.String()
 }
 
+// Diff returns the concatenated unified diffs recorded by SourceDir.Diff,
+// ordered by path. It is empty for collections produced by Find.
+func (c *UnformattedCollection) Diff() string {
+	if c == nil || len(c.diffs) == 0 {
+		return ""
+	}
+
+	paths := slices.Sorted(maps.Keys(c.diffs))
+
+	var builder strings.Builder
+	for _, path := range paths {
+		builder.Write(c.diffs[path])
+	}
+	return builder.String()
+}
+
 func IsDir(path string) (string, bool) {
 	return internalwalk.IsDir(path)
 }
@@ -2,15 +2,21 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/alitto/pond"
 	gocmp "github.com/google/go-cmp/cmp"
+
+	internalcache "github.com/zchee/goimports-rereviser/v4/internal/cache"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
 )
 
 const sep = string(os.PathSeparator)
@@ -25,6 +31,79 @@ func main() {
 }
 `
 
+func TestSourceDir_Find_WalkOptions(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires privileges on Windows")
+	}
+
+	rootDir := t.TempDir()
+	outsideDir := t.TempDir()
+	testutil.WriteFiles(t, rootDir, map[string]string{
+		"a.go":     dirFindUnformatted,
+		"b/b.go":   dirFindUnformatted,
+		"b/c/c.go": dirFindUnformatted,
+		"z/z.go":   dirFindUnformatted,
+	})
+	testutil.WriteFile(t, filepath.Join(outsideDir, "e.go"), dirFindUnformatted)
+	links := map[string]string{
+		"ext":    outsideDir,
+		"link":   filepath.Join(rootDir, "b"),
+		"b/loop": rootDir,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(rootDir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+
+	inRoot := func(names ...string) []string {
+		paths := make([]string, 0, len(names))
+		for _, name := range names {
+			paths = append(paths, filepath.Join(rootDir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+
+	tests := map[string]struct {
+		configure func(*SourceDir) *SourceDir
+		want      []string
+	}{
+		"default": {
+			configure: func(d *SourceDir) *SourceDir { return d },
+			want:      inRoot("a.go", "b/b.go", "b/c/c.go", "z/z.go"),
+		},
+		"follow symlinks": {
+			configure: (*SourceDir).WithFollowSymlinks,
+			want:      inRoot("a.go", "b/b.go", "b/c/c.go", "ext/e.go", "z/z.go"),
+		},
+		"max depth": {
+			configure: func(d *SourceDir) *SourceDir { return d.WithMaxDepth(1) },
+			want:      inRoot("a.go", "b/b.go", "z/z.go"),
+		},
+		"includes": {
+			configure: func(d *SourceDir) *SourceDir { return d.WithIncludes("b/c,z/*.go") },
+			want:      inRoot("b/c/c.go", "z/z.go"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := tt.configure(NewSourceDir("testdata", rootDir, true, "").WithSequentialThreshold(1))
+			files, err := dir.Find()
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, files.List()); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 const dirFindUnformatted = `package dir1
 import (
 	"strings"
@@ -215,6 +294,189 @@ func TestSourceDir_IsExcluded(t *testing.T) {
 	}
 }
 
+func TestSourceDir_Contains(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join(t.TempDir(), "project")
+
+	tests := map[string]struct {
+		recursive bool
+		excludes  string
+		includes  string
+		maxDepth  int
+		path      string
+		want      bool
+	}{
+		"direct child": {
+			path: filepath.Join(root, "main.go"),
+			want: true,
+		},
+		"nested without recursion": {
+			path: filepath.Join(root, "pkg", "a.go"),
+			want: false,
+		},
+		"nested with recursion": {
+			recursive: true,
+			path:      filepath.Join(root, "pkg", "a.go"),
+			want:      true,
+		},
+		"not a go file": {
+			path: filepath.Join(root, "README.md"),
+			want: false,
+		},
+		"outside of the directory": {
+			recursive: true,
+			path:      filepath.Join(filepath.Dir(root), "other", "a.go"),
+			want:      false,
+		},
+		"sibling with shared prefix": {
+			recursive: true,
+			path:      filepath.Join(root+"2", "a.go"),
+			want:      false,
+		},
+		"excluded file": {
+			excludes: "main.go",
+			path:     filepath.Join(root, "main.go"),
+			want:     false,
+		},
+		"excluded parent directory": {
+			recursive: true,
+			excludes:  "gen/",
+			path:      filepath.Join(root, "gen", "api", "a.go"),
+			want:      false,
+		},
+		"go tool ignored parent directory": {
+			recursive: true,
+			path:      filepath.Join(root, "pkg", "testdata", "a.go"),
+			want:      false,
+		},
+		"included file": {
+			recursive: true,
+			includes:  "cmd/*/main.go",
+			path:      filepath.Join(root, "cmd", "app", "main.go"),
+			want:      true,
+		},
+		"file in included directory": {
+			recursive: true,
+			includes:  "cmd/*/main.go,internal/",
+			path:      filepath.Join(root, "internal", "db", "db.go"),
+			want:      true,
+		},
+		"file not included": {
+			recursive: true,
+			includes:  "internal/",
+			path:      filepath.Join(root, "pkg", "a.go"),
+			want:      false,
+		},
+		"included but excluded": {
+			recursive: true,
+			includes:  "internal/",
+			excludes:  "internal/db",
+			path:      filepath.Join(root, "internal", "db", "db.go"),
+			want:      false,
+		},
+		"within max depth": {
+			recursive: true,
+			maxDepth:  2,
+			path:      filepath.Join(root, "pkg", "sub", "a.go"),
+			want:      true,
+		},
+		"below max depth": {
+			recursive: true,
+			maxDepth:  1,
+			path:      filepath.Join(root, "pkg", "sub", "a.go"),
+			want:      false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := NewSourceDir("project", root, tt.recursive, tt.excludes).
+				WithIncludes(tt.includes).
+				WithMaxDepth(tt.maxDepth).
+				Contains(tt.path)
+			if got != tt.want {
+				t.Errorf("Contains(%q) = %t, want %t", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceDir_IncludesDir(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join(t.TempDir(), "project")
+
+	tests := map[string]struct {
+		recursive bool
+		excludes  string
+		maxDepth  int
+		path      string
+		want      bool
+	}{
+		"root": {
+			path: root,
+			want: true,
+		},
+		"nested without recursion": {
+			path: filepath.Join(root, "pkg"),
+			want: false,
+		},
+		"nested with recursion": {
+			recursive: true,
+			path:      filepath.Join(root, "pkg", "sub"),
+			want:      true,
+		},
+		"outside": {
+			recursive: true,
+			path:      filepath.Dir(root),
+			want:      false,
+		},
+		"excluded": {
+			recursive: true,
+			excludes:  "gen",
+			path:      filepath.Join(root, "gen", "api"),
+			want:      false,
+		},
+		"go tool ignored": {
+			recursive: true,
+			path:      filepath.Join(root, ".git"),
+			want:      false,
+		},
+		"root with max depth": {
+			recursive: true,
+			maxDepth:  1,
+			path:      root,
+			want:      true,
+		},
+		"at max depth": {
+			recursive: true,
+			maxDepth:  1,
+			path:      filepath.Join(root, "pkg"),
+			want:      true,
+		},
+		"below max depth": {
+			recursive: true,
+			maxDepth:  1,
+			path:      filepath.Join(root, "pkg", "sub"),
+			want:      false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := NewSourceDir("project", root, tt.recursive, tt.excludes).WithMaxDepth(tt.maxDepth).IncludesDir(tt.path)
+			if got != tt.want {
+				t.Errorf("IncludesDir(%q) = %t, want %t", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSourceDir_Find(t *testing.T) {
 	t.Parallel()
 
@@ -306,6 +568,60 @@ func TestSourceDir_FindDoesNotWriteCache(t *testing.T) {
 	}
 }
 
+func TestSourceDir_DiffRecordsUnifiedDiffWithoutWriting(t *testing.T) {
+	t.Parallel()
+
+	project := "github.com/example/project"
+	tmpDir := t.TempDir()
+	unformattedPath := filepath.Join(tmpDir, "b_unformatted.go")
+	formattedPath := filepath.Join(tmpDir, "a_formatted.go")
+	unformatted := []byte("package testdata\n\nimport (\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(errors.New(\"dir diff\"))\n}\n")
+	formatted := []byte("package testdata\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"dir diff\")\n}\n")
+	if err := os.WriteFile(unformattedPath, unformatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(formattedPath, formatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	files, err := NewSourceDir(project, tmpDir, true, "").Diff()
+	if err != nil {
+		t.Fatalf("Diff returned error: %v", err)
+	}
+	if files == nil {
+		t.Fatalf("expected Diff to report the unformatted file")
+	}
+	if diff := gocmp.Diff([]string{unformattedPath}, files.List()); diff != "" {
+		t.Fatalf("unformatted files mismatch (-want +got):\n%s", diff)
+	}
+
+	want := "diff " + unformattedPath + ".orig " + unformattedPath + "\n" +
+		"--- " + unformattedPath + ".orig\n" +
+		"+++ " + unformattedPath + "\n" +
+		"@@ -1,8 +1,9 @@\n" +
+		" package testdata\n" +
+		" \n" +
+		" import (\n" +
+		"-\t\"github.com/pkg/errors\"\n" +
+		" \t\"fmt\"\n" +
+		"+\n" +
+		"+\t\"github.com/pkg/errors\"\n" +
+		" )\n" +
+		" \n" +
+		" func main() {\n"
+	if diff := gocmp.Diff(want, files.Diff()); diff != "" {
+		t.Fatalf("unified diff mismatch (-want +got):\n%s", diff)
+	}
+
+	content, err := os.ReadFile(unformattedPath)
+	if err != nil {
+		t.Fatalf("failed to read fixture after Diff: %v", err)
+	}
+	if diff := gocmp.Diff(string(unformatted), string(content)); diff != "" {
+		t.Fatalf("Diff should not mutate files (-want +got):\n%s", diff)
+	}
+}
+
 func TestSourceDir_Fix_CacheRespectsFingerprint(t *testing.T) {
 	t.Parallel()
 
@@ -362,6 +678,142 @@ func main() {
 	}
 }
 
+func TestSourceDir_Fix_PreservesFileMode(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "mode.go")
+	if err := os.WriteFile(filePath, []byte(dirFixUnformatted), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	changed, err := NewSourceDir("github.com/example/project", tmpDir, true, "").Fix()
+	if err != nil {
+		t.Fatalf("Fix returned error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected Fix to rewrite the unformatted file")
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("failed to stat fixed file: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0o600 {
+		t.Fatalf("file mode = %v, want %v", got, fs.FileMode(0o600))
+	}
+}
+
+func TestSourceDir_Fix_WithFixFunc(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "delegated.go")
+	if err := os.WriteFile(filePath, []byte(dirFixUnformatted), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	const delegated = "package delegated\n"
+	var calls []string
+	fix := func(_ context.Context, projectName, path string, _ ...SourceFileOption) (*Result, error) {
+		calls = append(calls, projectName+" "+path)
+		return &Result{Content: []byte(delegated), Original: []byte(dirFixUnformatted), Changed: true}, nil
+	}
+
+	changed, err := NewSourceDir("github.com/example/project", tmpDir, true, "").
+		WithSequentialThreshold(10).
+		WithFixFunc(fix).
+		Fix()
+	if err != nil {
+		t.Fatalf("Fix returned error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected Fix to report the change of the fix func")
+	}
+	if diff := gocmp.Diff([]string{"github.com/example/project " + filePath}, calls); diff != "" {
+		t.Fatalf("fix func calls mismatch (-want +got):\n%s", diff)
+	}
+	if got, err := os.ReadFile(filePath); err != nil || string(got) != delegated {
+		t.Fatalf("expected the content of the fix func to be written, got %q, %v", got, err)
+	}
+}
+
+func TestSourceDir_Fix_WithFiles(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.go", "b.go", "excluded.go", "notes.txt"} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(dirFixUnformatted), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	var calls []string
+	fix := func(_ context.Context, _, path string, _ ...SourceFileOption) (*Result, error) {
+		calls = append(calls, filepath.Base(path))
+		return &Result{Content: []byte(dirFixUnformatted), Original: []byte(dirFixUnformatted)}, nil
+	}
+
+	files := []string{
+		paths[1],
+		paths[2],
+		paths[3],
+		filepath.Join(tmpDir, "removed.go"),
+		filepath.Join(t.TempDir(), "outside.go"),
+	}
+	_, err := NewSourceDir("github.com/example/project", tmpDir, true, "excluded.go").
+		WithSequentialThreshold(10).
+		WithFixFunc(fix).
+		WithFiles(files).
+		Fix()
+	if err != nil {
+		t.Fatalf("Fix returned error: %v", err)
+	}
+	if diff := gocmp.Diff([]string{"b.go"}, calls); diff != "" {
+		t.Fatalf("fixed files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSourceDir_Fix_WithPackagePreload(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/preload\n\ngo 1.22\n",
+		"a/a.go":     "package a\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc A() { fmt.Println() }\n",
+		"b/b/b.go":   "package b\n\nimport (\n\t\"os\"\n\n\t\"example.com/preload/a\"\n)\n\nfunc B() { a.A() }\n",
+		"c/c_tag.go": "//go:build tagged\n\npackage c\n\nimport \"os\"\n",
+	}
+	want := map[string]string{
+		"a/a.go":     "package a\n\nimport (\n\t\"fmt\"\n)\n\nfunc A() { fmt.Println() }\n",
+		"b/b/b.go":   "package b\n\nimport (\n\t\"example.com/preload/a\"\n)\n\nfunc B() { a.A() }\n",
+		"c/c_tag.go": "//go:build tagged\n\npackage c\n",
+	}
+	testutil.WriteFiles(t, root, files)
+
+	changed, err := NewSourceDir("example.com/preload", root, true, "").
+		WithPackagePreload().
+		Fix(WithRemovingUnusedImports)
+	if err != nil {
+		t.Fatalf("Fix returned error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected Fix to remove unused imports")
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if diff := gocmp.Diff(content, string(got)); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
 func TestSourceDir_Fix_ReturnsWriteErrorWithoutCaching(t *testing.T) {
 	t.Parallel()
 
@@ -413,6 +865,58 @@ func main() {
 	}
 }
 
+func TestSourceDir_FixContextStopsAfterCancel(t *testing.T) {
+	t.Parallel()
+
+	project := "github.com/example/project"
+	tmpDir := t.TempDir()
+	unformatted := []byte("package testdata\n\nimport (\n\t\"strings\"\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(strings.ToLower(\"cancel\"))\n}\n")
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), unformatted, 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var written []string
+	dir := NewSourceDir(project, tmpDir, true, "")
+	dir.writeFile = func(name string, _ []byte, _ fs.FileMode) error {
+		written = append(written, name)
+		cancel()
+		return nil
+	}
+
+	_, err := dir.FixContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(written) != 1 {
+		t.Fatalf("expected no writes after cancellation, got %v", written)
+	}
+}
+
+func TestSourceDir_FindContextReturnsContextError(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.go"), []byte(dirFindUnformatted), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	files, err := NewSourceDir("github.com/example/project", tmpDir, true, "").FindContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if files != nil {
+		t.Fatalf("expected no collection after cancellation, got %v", files.List())
+	}
+}
+
 func TestSourceDirCacheDefaults(t *testing.T) {
 	t.Parallel()
 
@@ -486,3 +990,90 @@ func TestUnformattedCollection_String(t *testing.T) {
 		})
 	}
 }
+
+func TestSourceDir_Fix_NestedModuleProjectName(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	cacheDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module example.com/root\n\ngo 1.22\n",
+		"nested/go.mod": "module example.com/nested\n\ngo 1.22\n\nreplace example.com/other => ../other\n",
+		"nested/app/main.go": `package app
+
+import (
+	"example.com/nested/lib"
+	"example.com/root/util"
+	"fmt"
+)
+
+var _ = fmt.Sprint(lib.Name, util.Name)
+`,
+	}
+	testutil.WriteFiles(t, root, files)
+
+	if _, err := NewSourceDir("example.com/root", root, true, "").
+		WithSequentialThreshold(0).
+		WithCache(cacheDir).
+		WithCacheFingerprint("default").
+		Fix(); err != nil {
+		t.Fatalf("Fix returned error: %v", err)
+	}
+
+	filePath := filepath.Join(root, "nested", "app", "main.go")
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	want := `package app
+
+import (
+	"fmt"
+
+	"example.com/root/util"
+
+	"example.com/nested/lib"
+)
+
+var _ = fmt.Sprint(lib.Name, util.Name)
+`
+	if diff := gocmp.Diff(want, string(content)); diff != "" {
+		t.Fatalf("nested module file mismatch (-want +got):\n%s", diff)
+	}
+
+	entry, err := internalcache.ReadCacheEntry(cacheDir, filePath)
+	if err != nil {
+		t.Fatalf("ReadCacheEntry returned error: %v", err)
+	}
+	if got, want := entry.Fingerprint, "default|module=example.com/nested|workspace-modules=example.com/other"; got != want {
+		t.Fatalf("cache fingerprint = %q, want %q", got, want)
+	}
+}
+
+func TestSourceDir_Fix_FileOutsideModuleRequiresProjectName(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	filePath := filepath.Join(root, "main.go")
+	const content = "package main\n\nimport (\n\t\"fmt\"\n\n\t\"os\"\n)\n\nvar _, _ = fmt.Sprint, os.Args\n"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	_, err := NewSourceDir("", root, true, "").WithSequentialThreshold(0).Fix()
+	var undefinedErr *modulepath.UndefinedModuleError
+	if !errors.As(err, &undefinedErr) {
+		t.Fatalf("expected an undefined module error, got %v", err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("file outside of any module must be left alone, got:\n%s", got)
+	}
+
+	if _, err := NewSourceDir("example.com/p", root, true, "").WithSequentialThreshold(0).Fix(); err != nil {
+		t.Fatalf("Fix with a project name returned error: %v", err)
+	}
+}
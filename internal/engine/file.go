@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go/ast"
 	"go/format"
@@ -29,16 +30,34 @@ var codeGeneratedPattern = regexp.MustCompile(`^// Code generated .* DO NOT EDIT
 // SourceFile main struct for fixing an existing code
 type SourceFile struct {
 	shouldRemoveUnusedImports      bool
+	shouldTypeCheckUnusedImports   bool
+	shouldAddMissingImports        bool
 	shouldUseAliasForVersionSuffix bool
+	shouldRemoveRedundantAliases   bool
 	shouldFormatCode               bool
 	shouldSkipAutoGenerated        bool
 	shouldSeparateNamedImports     bool
 	shouldSkipBlanked              bool
+	shouldGroupWorkspaceModules    bool
 	companyPackagePrefixes         []string
 	importsOrders                  ImportsOrders
+	importGroups                   []importGroupMatcher
+	requiredAliases                map[string]string
+	importRewrites                 []ImportRewrite
+	importRules                    []importRuleMatcher
+
+	// pathRewrites collects the import paths rewritten by importRewrites and
+	// the replacements of importRules.
+	pathRewrites []PathRewrite
+	// violations collects the imports that break importRules.
+	violations []ImportViolation
+	// workspaceModules are the module paths classified like the project.
+	workspaceModules []string
 
 	projectName string
 	filePath    string
+	src         []byte
+	hasSrc      bool
 }
 
 // NewSourceFile constructor
@@ -49,24 +68,60 @@ func NewSourceFile(projectName, filePath string) *SourceFile {
 	}
 }
 
+// NewSourceFileFromBytes creates a SourceFile that fixes src instead of
+// reading filePath from disk or from stdin. filePath is still used for module
+// and build tag resolution and in error messages.
+func NewSourceFileFromBytes(projectName, filePath string, src []byte) *SourceFile {
+	return &SourceFile{
+		projectName: projectName,
+		filePath:    filePath,
+		src:         src,
+		hasSrc:      true,
+	}
+}
+
 // Fix is for revise imports and format the code. Returns formated content, original content, true if formatted content is different from original and error.
 func (f *SourceFile) Fix(options ...SourceFileOption) ([]byte, []byte, bool, error) {
+	result, err := f.FixResult(options...)
+	if err != nil {
+		return nil, result.Original, false, err
+	}
+	return result.Content, result.Original, result.Changed, nil
+}
+
+// FixResult is for revise imports and format the code like Fix, but describes
+// the outcome with a Result. On error the Result is non-nil and carries the
+// original content when it could be read.
+func (f *SourceFile) FixResult(options ...SourceFileOption) (*Result, error) {
+	return f.FixResultContext(context.Background(), options...)
+}
+
+// FixResultContext is like FixResult, but returns ctx.Err() once ctx is done,
+// including while package dependencies are being loaded.
+func (f *SourceFile) FixResultContext(ctx context.Context, options ...SourceFileOption) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return &Result{}, err
+	}
+
 	for _, option := range options {
 		err := option(f)
 		if err != nil {
-			return nil, nil, false, err
+			return &Result{}, err
 		}
 	}
 
 	var originalContent []byte
 	var err error
-	if f.filePath == StandardInput {
+	if f.hasSrc {
+		originalContent = f.src
+	} else if f.filePath == StandardInput {
 		originalContent, err = io.ReadAll(os.Stdin)
 	} else {
 		originalContent, err = os.ReadFile(f.filePath)
 	}
+	unchanged := &Result{Content: originalContent, Original: originalContent}
 	if err != nil {
-		return nil, originalContent, false, err
+		return unchanged, err
 	}
 
 	fset := token.NewFileSet()
@@ -74,22 +129,32 @@ func (f *SourceFile) Fix(options ...SourceFileOption) ([]byte, []byte, bool, err
 	pf, err := parser.ParseFile(fset, f.filePath, originalContent, parser.ParseComments)
 	if err != nil {
 		if len(originalContent) == 0 {
-			return nil, originalContent, false, fmt.Errorf("file is empty and cannot be parsed as Go source, use -excludes flag to skip this file: %w", err)
+			return unchanged, fmt.Errorf("file is empty and cannot be parsed as Go source, use -excludes flag to skip this file: %w", err)
 		}
-		return nil, originalContent, false, fmt.Errorf("file has invalid Go source content, use -excludes flag to skip this file: %w", err)
+		return unchanged, fmt.Errorf("file has invalid Go source content, use -excludes flag to skip this file: %w", err)
 	}
 
 	if len(pf.Imports) == 1 && pf.Imports[0].Path.Value == `"C"` {
-		return originalContent, originalContent, false, nil
+		unchanged.SkipReason = SkipReasonCgoOnly
+		return unchanged, nil
 	}
 
 	if f.shouldSkipAutoGenerated && isFileAutoGenerate(pf) {
-		return originalContent, originalContent, false, nil
+		unchanged.SkipReason = SkipReasonGenerated
+		return unchanged, nil
 	}
 
-	importsWithMetadata, err := f.parseImports(pf)
+	importsWithMetadata, err := f.parseImports(ctx, fset, pf, originalContent)
 	if err != nil {
-		return nil, originalContent, false, err
+		return unchanged, err
+	}
+	unchanged.Violations = f.violations
+
+	if f.shouldGroupWorkspaceModules {
+		f.workspaceModules, err = workspaceModulesOf(f.filePath)
+		if err != nil {
+			return unchanged, err
+		}
 	}
 
 	groups := f.groupImports(
@@ -98,8 +163,10 @@ func (f *SourceFile) Fix(options ...SourceFileOption) ([]byte, []byte, bool, err
 		importsWithMetadata,
 	)
 
-	decls, ok := hasMultipleImportDecls(pf)
-	if ok {
+	decls, merged := hasMultipleImportDecls(pf)
+	var mergedDecls int
+	if merged {
+		mergedDecls = countImportDecls(pf) - 1
 		pf.Decls = decls
 	}
 
@@ -109,20 +176,34 @@ func (f *SourceFile) Fix(options ...SourceFileOption) ([]byte, []byte, bool, err
 
 	fixedImportsContent, err := generateFile(fset, pf)
 	if err != nil {
-		return nil, originalContent, false, err
+		return unchanged, err
 	}
 
 	importsChanged := !bytes.Equal(originalContent, fixedImportsContent)
 	if !importsChanged && !f.shouldFormatCode {
-		return originalContent, originalContent, false, nil
+		return unchanged, nil
 	}
 
 	formattedContent, err := format.Source(fixedImportsContent)
 	if err != nil {
-		return nil, originalContent, false, err
+		return unchanged, err
 	}
 
-	return formattedContent, originalContent, !bytes.Equal(originalContent, formattedContent), nil
+	result := &Result{
+		Content:    formattedContent,
+		Original:   originalContent,
+		Changed:    !bytes.Equal(originalContent, formattedContent),
+		Violations: f.violations,
+	}
+	if result.Changed {
+		result.ImportChanges, err = computeImportChanges(originalContent, formattedContent, f.pathRewrites)
+		if err != nil {
+			return unchanged, err
+		}
+		result.MergedDecls = mergedDecls
+	}
+
+	return result, nil
 }
 
 func isFileAutoGenerate(pf *ast.File) bool {
@@ -169,6 +250,25 @@ func fixCommentGroup(commentGroup *ast.CommentGroup) *ast.CommentGroup {
 	return formattedDoc
 }
 
+// appendCustomImport adds imprt to the custom group name of customImports,
+// which is created when nil.
+func appendCustomImport(customImports map[ImportsOrder]*customGroupImports, name ImportsOrder, imprt string, named bool) map[ImportsOrder]*customGroupImports {
+	if customImports == nil {
+		customImports = make(map[ImportsOrder]*customGroupImports)
+	}
+	group := customImports[name]
+	if group == nil {
+		group = &customGroupImports{}
+		customImports[name] = group
+	}
+	if named {
+		group.named = append(group.named, imprt)
+	} else {
+		group.plain = append(group.plain, imprt)
+	}
+	return customImports
+}
+
 func (f *SourceFile) groupImports(
 	projectName string,
 	localPkgPrefixes []string,
@@ -184,12 +284,25 @@ func (f *SourceFile) groupImports(
 		namedProjectLocalPkgs []string
 		namedGeneralImports   []string
 		dottedImports         []string
+		customImports         map[ImportsOrder]*customGroupImports
 	)
 
 	for imprt := range importsWithMetadata {
 		classified := classifyImport(projectName, localPkgPrefixes, f.importsOrders, f.shouldSeparateNamedImports, imprt)
+		if len(f.workspaceModules) > 0 {
+			classified = classifyWorkspaceImport(classified, f.workspaceModules, f.importsOrders, skipPackageAlias(imprt))
+		}
+
+		if len(f.importGroups) > 0 && classified.bucket != importBucketStd && classified.bucket != importBucketDotted {
+			if name, ok := matchImportGroup(f.importGroups, f.importsOrders, skipPackageAlias(imprt), classified.matchLen); ok {
+				customImports = appendCustomImport(customImports, name, imprt, classified.named)
+				continue
+			}
+		}
 
 		switch classified.bucket {
+		case importBucketWorkspace:
+			customImports = appendCustomImport(customImports, WorkspaceImportsOrder, imprt, classified.named)
 		case importBucketDotted:
 			dottedImports = append(dottedImports, imprt)
 		case importBucketStd:
@@ -244,6 +357,10 @@ func (f *SourceFile) groupImports(
 	slices.SortFunc(namedGeneralImports, compareImports)
 	slices.SortFunc(namedProjectLocalPkgs, compareImports)
 	slices.SortFunc(namedProjectImports, compareImports)
+	for _, group := range customImports {
+		slices.SortFunc(group.plain, compareImports)
+		slices.SortFunc(group.named, compareImports)
+	}
 
 	result := &groupsImports{
 		common: &common{
@@ -257,6 +374,7 @@ func (f *SourceFile) groupImports(
 			namedProject: namedProjectImports,
 		},
 		dotted: dottedImports,
+		custom: customImports,
 	}
 	return result
 }
@@ -323,6 +441,10 @@ func (f *SourceFile) fixImports(
 	groups *groupsImports,
 	commentsMetadata map[string]*commentsMetadata,
 ) {
+	if len(commentsMetadata) > 0 && countImportDecls(file) == 0 {
+		addImportDecl(file)
+	}
+
 	var importsPositions []*importPosition
 	for _, decl := range file.Decls {
 		dd, ok := decl.(*ast.GenDecl)
@@ -356,6 +478,24 @@ func (f *SourceFile) fixImports(
 	removeEmptyImportNode(file)
 }
 
+// addImportDecl inserts an empty import declaration after the package clause
+// and a standalone import "C", for files that only get imports added.
+func addImportDecl(file *ast.File) {
+	pos := file.Name.End()
+	idx := 0
+	for idx < len(file.Decls) {
+		dd, ok := file.Decls[idx].(*ast.GenDecl)
+		if !ok || !isSingleCgoImport(dd) {
+			break
+		}
+		pos = dd.End()
+		idx++
+	}
+
+	decl := &ast.GenDecl{Tok: token.IMPORT, TokPos: pos, Lparen: pos, Rparen: pos}
+	file.Decls = slices.Insert(file.Decls, idx, ast.Decl(decl))
+}
+
 // hasMultipleImportDecls will return combined import declarations to single declaration
 //
 // Ex.:
@@ -411,6 +551,19 @@ func hasMultipleImportDecls(f *ast.File) ([]ast.Decl, bool) {
 	return decls, hasMultipleImportDecls
 }
 
+// countImportDecls returns the number of import declarations of f, ignoring
+// a standalone import "C".
+func countImportDecls(f *ast.File) int {
+	var count int
+	for _, decl := range f.Decls {
+		dd, ok := decl.(*ast.GenDecl)
+		if ok && dd.Tok == token.IMPORT && !isSingleCgoImport(dd) {
+			count++
+		}
+	}
+	return count
+}
+
 func removeEmptyImportNode(f *ast.File) {
 	var (
 		decls      []ast.Decl
@@ -594,34 +747,62 @@ func importWithComment(imprt string, commentsMetadata map[string]*commentsMetada
 	return fmt.Sprintf("%s%s%s", doc.String(), imprt, inline.String())
 }
 
-func (f *SourceFile) parseImports(file *ast.File) (map[string]*commentsMetadata, error) {
+func (f *SourceFile) parseImports(ctx context.Context, fset *token.FileSet, file *ast.File, src []byte) (map[string]*commentsMetadata, error) {
 	importsWithMetadata := map[string]*commentsMetadata{}
+	f.pathRewrites = nil
+	f.violations = nil
 
 	shouldRemoveUnusedImports := f.shouldRemoveUnusedImports
+	shouldAddMissingImports := f.shouldAddMissingImports
 	shouldUseAliasForVersionSuffix := f.shouldUseAliasForVersionSuffix
+	shouldApplyAliasPolicy := f.shouldRemoveRedundantAliases || len(f.requiredAliases) > 0
 
 	var packageImports map[string]string
 
-	if shouldRemoveUnusedImports || shouldUseAliasForVersionSuffix {
+	buildTag := pkgdeps.ParseBuildTag(file)
+	if shouldRemoveUnusedImports || shouldAddMissingImports || shouldUseAliasForVersionSuffix || shouldApplyAliasPolicy {
 		var err error
-		buildTag := pkgdeps.ParseBuildTag(file)
-		packageImports, err = pkgdeps.Load(filepath.Dir(f.filePath), buildTag)
-		if err != nil && buildTag != "" {
+		packageImports, err = pkgdeps.LoadContext(ctx, filepath.Dir(f.filePath), buildTag)
+		if err != nil && buildTag != "" && ctx.Err() == nil {
 			// Retry without build tag — files with custom build constraints
 			// (like tools.go with //+build tools) may cause go list conflicts
 			// when the file imports the project itself.
-			packageImports, err = pkgdeps.Load(filepath.Dir(f.filePath), "")
+			packageImports, err = pkgdeps.LoadContext(ctx, filepath.Dir(f.filePath), "")
 		}
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if shouldApplyAliasPolicy {
+		if err := f.applyAliasPolicy(file, packageImports); err != nil {
+			return nil, err
+		}
+	}
+
 	var usedImports map[string]bool
-	if shouldRemoveUnusedImports {
+	if shouldRemoveUnusedImports && f.shouldTypeCheckUnusedImports && f.filePath != StandardInput {
+		var (
+			typeChecked bool
+			err         error
+		)
+		usedImports, typeChecked, err = pkgdeps.TypedUsedImports(ctx, f.filePath, src, buildTag)
+		if err != nil {
+			return nil, err
+		}
+		if !typeChecked {
+			usedImports = nil
+		}
+	}
+	if shouldRemoveUnusedImports && usedImports == nil {
 		usedImports = pkgdeps.UsedImports(file, packageImports)
 	}
 
+	var importer string
+	if len(f.importRules) > 0 {
+		importer = f.importerPath()
+	}
+
 	for _, decl := range file.Decls {
 		dd, ok := decl.(*ast.GenDecl)
 		if !ok {
@@ -637,6 +818,19 @@ func (f *SourceFile) parseImports(file *ast.File) (map[string]*commentsMetadata,
 			if shouldRemoveUnusedImports && !usedImports[importPath] {
 				continue
 			}
+			newPath := importPath
+			if rewritten, ok := f.rewriteImportSpec(importSpec, importPath); ok {
+				newPath = rewritten
+			}
+			if violation, ok := f.checkImportRules(fset, importSpec, newPath, importer); ok {
+				f.violations = append(f.violations, violation)
+				if violation.Replacement != "" {
+					newPath = violation.Replacement
+				}
+			}
+			if newPath != importPath {
+				f.pathRewrites = append(f.pathRewrites, PathRewrite{From: importPath, To: newPath})
+			}
 
 			var importSpecStr string
 			if importSpec.Name != nil {
@@ -656,6 +850,16 @@ func (f *SourceFile) parseImports(file *ast.File) (map[string]*commentsMetadata,
 		}
 	}
 
+	if shouldAddMissingImports {
+		missing, err := pkgdeps.MissingImports(ctx, f.filePath, file, packageImports)
+		if err != nil {
+			return nil, err
+		}
+		for _, imprt := range missing {
+			importsWithMetadata[imprt.Spec()] = &commentsMetadata{}
+		}
+	}
+
 	return importsWithMetadata, nil
 }
 
@@ -666,7 +870,8 @@ func setAliasForVersionedImportSpec(importSpec *ast.ImportSpec, packageImports m
 	aliasName := packageImports[imprt]
 
 	importSuffix := path.Base(imprt)
-	if importSuffix != aliasName {
+	// Rewritten imports may not be known to the loaded package yet.
+	if aliasName != "" && importSuffix != aliasName {
 		importSpecStr = fmt.Sprintf("%s %s", aliasName, importSpec.Path.Value)
 	} else {
 		importSpecStr = importSpec.Path.Value
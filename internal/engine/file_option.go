@@ -16,12 +16,86 @@ func WithRemovingUnusedImports(f *SourceFile) error {
 	return nil
 }
 
+// WithTypeCheckedUnusedImports makes WithRemovingUnusedImports decide whether
+// an import is used by type-checking the package of the file, instead of
+// relying on the syntax only. It is slower, but not fooled by shadowed package
+// names or dot imports. Files that are not part of the package for the build
+// tag fall back to the syntactic check.
+func WithTypeCheckedUnusedImports(f *SourceFile) error {
+	f.shouldTypeCheckUnusedImports = true
+	return nil
+}
+
+// WithAddingMissingImports is an option to import the packages of unresolved
+// references, like strings.Builder, from std, the module or its dependencies
+func WithAddingMissingImports(f *SourceFile) error {
+	f.shouldAddMissingImports = true
+	return nil
+}
+
 // WithUsingAliasForVersionSuffix is an option to set explicit package name in imports
 func WithUsingAliasForVersionSuffix(f *SourceFile) error {
 	f.shouldUseAliasForVersionSuffix = true
 	return nil
 }
 
+// WithRemovingRedundantAliases is an option to remove aliases that equal the
+// package name, like errors "errors"
+func WithRemovingRedundantAliases(f *SourceFile) error {
+	f.shouldRemoveRedundantAliases = true
+	return nil
+}
+
+// WithRequiredAliases names the imports of the given paths with the given
+// aliases and rewrites their uses. An import that already uses one of the
+// aliases is renamed after its path, e.g. metav1 for
+// "k8s.io/apimachinery/pkg/apis/meta/v1", and a file that declares an alias
+// for something else fails.
+func WithRequiredAliases(aliases []ImportAlias) SourceFileOption {
+	return func(f *SourceFile) error {
+		byPath, err := compileImportAliases(aliases)
+		if err != nil {
+			return err
+		}
+		f.requiredAliases = byPath
+		return nil
+	}
+}
+
+// WithWorkspaceModules classifies the imports of the other modules of the
+// go.work workspace, and of modules replaced with local directories in go.mod
+// or go.work, into the workspace group when the imports order has one, and
+// into the project group otherwise.
+func WithWorkspaceModules(f *SourceFile) error {
+	f.shouldGroupWorkspaceModules = true
+	return nil
+}
+
+// WithImportRewrites replaces import path prefixes before the imports are
+// grouped, keeping their comments. See ImportRewrite.
+func WithImportRewrites(rewrites []ImportRewrite) SourceFileOption {
+	return func(f *SourceFile) error {
+		if err := validateImportRewrites(rewrites); err != nil {
+			return err
+		}
+		f.importRewrites = rewrites
+		return nil
+	}
+}
+
+// WithImportRules reports the imports that break rules, replacing them when
+// a rule has a replacement. See ImportRule.
+func WithImportRules(rules []ImportRule) SourceFileOption {
+	return func(f *SourceFile) error {
+		matchers, err := compileImportRules(rules)
+		if err != nil {
+			return err
+		}
+		f.importRules = matchers
+		return nil
+	}
+}
+
 // WithCodeFormatting use to format the code
 func WithCodeFormatting(f *SourceFile) error {
 	f.shouldFormatCode = true
@@ -47,6 +121,20 @@ func WithImportsOrder(orders []ImportsOrder) SourceFileOption {
 	}
 }
 
+// WithImportGroups adds user-named import groups matched by path patterns.
+// A group is only applied when its name is part of the imports order, see
+// WithImportsOrder and StringToImportsOrdersWithGroups.
+func WithImportGroups(groups []ImportGroup) SourceFileOption {
+	return func(f *SourceFile) error {
+		matchers, err := compileImportGroups(groups)
+		if err != nil {
+			return err
+		}
+		f.importGroups = matchers
+		return nil
+	}
+}
+
 // WithSkipGeneratedFile will skip formatting and imports sorting for auto-generated file which starts with
 // comment on first line: `// Code generated`
 func WithSkipGeneratedFile(f *SourceFile) error {
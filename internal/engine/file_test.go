@@ -9,6 +9,8 @@ import (
 
 	gocmp "github.com/google/go-cmp/cmp"
 	"golang.org/x/tools/txtar"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
 )
 
 // Common fixture identifiers used across SourceFile.Fix table tests.
@@ -1491,6 +1493,520 @@ func main() {
 	}
 }
 
+func TestSourceFile_Fix_WithTypeCheckedUnusedImports(t *testing.T) {
+	tests := map[string]struct {
+		projectName string
+		archive     string
+		wantChange  bool
+		wantErr     bool
+	}{
+		"remove unused dot import and keep used one": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+	. "math"
+	. "strings"
+)
+
+func main() {
+	fmt.Println(ToUpper("pi"))
+}
+-- want.go --
+package testdata
+
+import (
+	"fmt"
+	. "strings"
+)
+
+func main() {
+	fmt.Println(ToUpper("pi"))
+}
+`,
+			wantChange: true,
+		},
+		"remove import shadowed by a local variable": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	strings := struct{ Fields func(string) []string }{}
+	fmt.Println(strings.Fields)
+}
+-- want.go --
+package testdata
+
+import (
+	"fmt"
+)
+
+func main() {
+	strings := struct{ Fields func(string) []string }{}
+	fmt.Println(strings.Fields)
+}
+`,
+			wantChange: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runFixCase(t, tt.projectName, testFilePath, tt.archive, tt.wantChange, tt.wantErr, WithRemovingUnusedImports, WithTypeCheckedUnusedImports)
+		})
+	}
+}
+
+func TestSourceFile_Fix_WithAliasPolicy(t *testing.T) {
+	tests := map[string]struct {
+		archive    string
+		options    SourceFileOptions
+		wantChange bool
+		wantErr    bool
+	}{
+		"remove redundant aliases": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	errors "errors"
+	fmt "fmt"
+	str "strings"
+)
+
+func main() {
+	fmt.Println(errors.New(str.ToUpper("a")))
+}
+-- want.go --
+package testdata
+
+import (
+	"errors"
+	"fmt"
+	str "strings"
+)
+
+func main() {
+	fmt.Println(errors.New(str.ToUpper("a")))
+}
+`,
+			options:    SourceFileOptions{WithRemovingRedundantAliases},
+			wantChange: true,
+		},
+		"enforce required alias and rewrite uses": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, _ := json.Marshal(1)
+	fmt.Println(b, json.Valid(b))
+}
+-- want.go --
+package testdata
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+)
+
+func main() {
+	b, _ := stdjson.Marshal(1)
+	fmt.Println(b, stdjson.Valid(b))
+}
+`,
+			options:    SourceFileOptions{WithRequiredAliases([]ImportAlias{{Name: "stdjson", Path: "encoding/json"}})},
+			wantChange: true,
+		},
+		"rename import occupying a required alias": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	tpl "html/template"
+	"text/template"
+)
+
+var (
+	_ tpl.HTML
+	_ = template.New("t")
+)
+-- want.go --
+package testdata
+
+import (
+	htmltemplate "html/template"
+	tpl "text/template"
+)
+
+var (
+	_ htmltemplate.HTML
+	_ = tpl.New("t")
+)
+`,
+			options:    SourceFileOptions{WithRequiredAliases([]ImportAlias{{Name: "tpl", Path: "text/template"}})},
+			wantChange: true,
+		},
+		"required alias equal to the package name": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	j "encoding/json"
+)
+
+var _ = j.Valid
+-- want.go --
+package testdata
+
+import (
+	"encoding/json"
+)
+
+var _ = json.Valid
+`,
+			options:    SourceFileOptions{WithRequiredAliases([]ImportAlias{{Name: "json", Path: "encoding/json"}})},
+			wantChange: true,
+		},
+		"required alias declared in the file": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"encoding/json"
+)
+
+var stdjson = json.Valid
+`,
+			options: SourceFileOptions{WithRequiredAliases([]ImportAlias{{Name: "stdjson", Path: "encoding/json"}})},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runFixCase(t, testProjectName, testFilePath, tt.archive, tt.wantChange, tt.wantErr, tt.options...)
+		})
+	}
+}
+
+func TestSourceFile_Fix_WithImportRewrites(t *testing.T) {
+	tests := map[string]struct {
+		archive    string
+		rewrites   []ImportRewrite
+		wantChange bool
+	}{
+		"rewrite into another group keeping comments": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+
+	// errors with stack traces
+	"github.com/pkg/errors" // deprecated
+)
+
+func main() {
+	fmt.Println(errors.New("a"))
+}
+-- want.go --
+package testdata
+
+import (
+	// errors with stack traces
+	"errors" // deprecated
+	"fmt"
+)
+
+func main() {
+	fmt.Println(errors.New("a"))
+}
+`,
+			rewrites:   []ImportRewrite{{From: "github.com/pkg/errors", To: "errors"}},
+			wantChange: true,
+		},
+		"rewrite major version with alias and longest prefix": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"example.com/lib/v2"
+	_ "example.com/lib/v2/driver"
+	"example.com/lib/v2/util"
+)
+
+var _ = lib.New(util.X)
+-- want.go --
+package testdata
+
+import (
+	lib "example.com/lib/v3"
+	"example.com/util"
+
+	_ "example.com/lib/v3/driver"
+)
+
+var _ = lib.New(util.X)
+`,
+			rewrites: []ImportRewrite{
+				{From: "example.com/lib/v2", To: "example.com/lib/v3", Alias: "lib"},
+				{From: "example.com/lib/v2/util", To: "example.com/util"},
+			},
+			wantChange: true,
+		},
+		"whole path elements only": {
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"example.com/libx"
+)
+
+var _ = libx.New
+-- want.go --
+package testdata
+
+import (
+	"example.com/libx"
+)
+
+var _ = libx.New
+`,
+			rewrites: []ImportRewrite{{From: "example.com/lib", To: "example.com/other"}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runFixCase(t, testProjectName, testFilePath, tt.archive, tt.wantChange, false, WithImportRewrites(tt.rewrites))
+		})
+	}
+}
+
+func TestSourceFile_Fix_WithWorkspaceModules(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	clearTestCaches()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.work":     "go 1.22\n\nuse (\n\t./app\n\t./lib\n)\n",
+		"app/go.mod":  "module example.com/app\n\ngo 1.22\n\nreplace example.com/local => ../local\n",
+		"lib/go.mod":  "module example.com/lib\n\ngo 1.22\n",
+		"app/main.go": "package main\n\nimport (\n\t\"example.com/app/internal/x\"\n\t\"example.com/lib/y\"\n\t\"example.com/local/z\"\n\t\"fmt\"\n\t\"github.com/pkg/errors\"\n)\n",
+	}
+	testutil.WriteFiles(t, root, files)
+
+	tests := map[string]struct {
+		options SourceFileOptions
+		want    string
+	}{
+		"merged into project": {
+			options: SourceFileOptions{WithWorkspaceModules},
+			want: `package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"example.com/app/internal/x"
+	"example.com/lib/y"
+	"example.com/local/z"
+)
+`,
+		},
+		"workspace group": {
+			options: SourceFileOptions{
+				WithWorkspaceModules,
+				WithImportsOrder([]ImportsOrder{StdImportsOrder, GeneralImportsOrder, WorkspaceImportsOrder, CompanyImportsOrder, ProjectImportsOrder}),
+			},
+			want: `package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"example.com/lib/y"
+	"example.com/local/z"
+
+	"example.com/app/internal/x"
+)
+`,
+		},
+		"disabled": {
+			want: `package main
+
+import (
+	"fmt"
+
+	"example.com/lib/y"
+	"example.com/local/z"
+	"github.com/pkg/errors"
+
+	"example.com/app/internal/x"
+)
+`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, _, err := NewSourceFile("example.com/app", filepath.Join(root, "app", "main.go")).Fix(tt.options...)
+			if err != nil {
+				t.Fatalf("Fix returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSourceFile_Fix_WithAddingMissingImports(t *testing.T) {
+	tests := map[string]struct {
+		projectName string
+		archive     string
+		wantChange  bool
+		wantErr     bool
+	}{
+		"add std, module and dependency imports into their groups": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+)
+
+func main() {
+	var b strings.Builder
+	_ = errors.Is(nil, nil)
+	_ = cmp.Diff(1, 2)
+	_, _ = modulepath.Name(".")
+	fmt.Println(b.String())
+}
+-- want.go --
+package testdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+)
+
+func main() {
+	var b strings.Builder
+	_ = errors.Is(nil, nil)
+	_ = cmp.Diff(1, 2)
+	_, _ = modulepath.Name(".")
+	fmt.Println(b.String())
+}
+`,
+			wantChange: true,
+		},
+		"add import declaration to file without imports": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+// upper is documented.
+func upper(s string) string {
+	return strings.ToUpper(s)
+}
+-- want.go --
+package testdata
+
+import (
+	"strings"
+)
+
+// upper is documented.
+func upper(s string) string {
+	return strings.ToUpper(s)
+}
+`,
+			wantChange: true,
+		},
+		"pick the package exporting every selector": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+func roll() int {
+	return rand.Intn(6) + rand.Int()
+}
+-- want.go --
+package testdata
+
+import (
+	"math/rand"
+)
+
+func roll() int {
+	return rand.Intn(6) + rand.Int()
+}
+`,
+			wantChange: true,
+		},
+		"leave declared and unresolvable identifiers alone": {
+			projectName: testProjectName,
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+)
+
+type point struct{ X int }
+
+func main() {
+	p := point{}
+	fmt.Println(p.X, unknownpkg.Value)
+}
+`,
+			wantChange: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			runFixCase(t, tt.projectName, testFilePath, tt.archive, tt.wantChange, tt.wantErr, WithAddingMissingImports)
+		})
+	}
+}
+
 func TestSourceFile_Fix_WithAliasForVersionSuffix(t *testing.T) {
 	tests := map[string]struct {
 		projectName string
@@ -1835,6 +2351,157 @@ func main() {
 	}
 }
 
+func TestSourceFile_Fix_WithImportGroups(t *testing.T) {
+	tests := map[string]struct {
+		importsOrder     string
+		importGroups     string
+		localPkgPrefixes string
+		archive          string
+		wantChange       bool
+	}{
+		"custom groups are placed by imports order": {
+			importsOrder: "std,k8s,general,gen,company,project",
+			importGroups: "k8s=k8s.io/...,sigs.k8s.io/...;gen=re:/gen/",
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	"github.com/zchee/goimports-rereviser/pkg"
+	"example.com/proto/gen/foo"
+	"sigs.k8s.io/yaml"
+	"k8s.io/client-go"
+)
+
+// nolint:gomnd
+func main(){
+  _ = fmt.Println("test")
+}
+-- want.go --
+package testdata
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pkg/errors"
+
+	"example.com/proto/gen/foo"
+
+	"github.com/zchee/goimports-rereviser/pkg"
+)
+
+// nolint:gomnd
+func main() {
+	_ = fmt.Println("test")
+}
+`,
+			wantChange: true,
+		},
+		"more specific custom pattern wins over company prefix": {
+			importsOrder:     "std,general,company,platform,project",
+			importGroups:     "platform=github.com/acme/platform/...",
+			localPkgPrefixes: "github.com/acme",
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+	"github.com/acme/platform/log"
+	"github.com/acme/tools"
+	"github.com/pkg/errors"
+)
+
+// nolint:gomnd
+func main(){
+  _ = fmt.Println("test")
+}
+-- want.go --
+package testdata
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/acme/tools"
+
+	"github.com/acme/platform/log"
+)
+
+// nolint:gomnd
+func main() {
+	_ = fmt.Println("test")
+}
+`,
+			wantChange: true,
+		},
+		"project name wins over less specific custom pattern": {
+			importsOrder: "std,general,company,project,github",
+			importGroups: "github=github.com/",
+			archive: `
+-- input.go --
+package testdata
+
+import (
+	"fmt"
+	"github.com/zchee/goimports-rereviser/pkg"
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+// nolint:gomnd
+func main(){
+  _ = fmt.Println("test")
+}
+-- want.go --
+package testdata
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zchee/goimports-rereviser/pkg"
+
+	"github.com/pkg/errors"
+)
+
+// nolint:gomnd
+func main() {
+	_ = fmt.Println("test")
+}
+`,
+			wantChange: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			groups, err := StringToImportGroups(tt.importGroups)
+			if err != nil {
+				t.Fatalf("StringToImportGroups returned error: %v", err)
+			}
+			order, err := StringToImportsOrdersWithGroups(tt.importsOrder, groups)
+			if err != nil {
+				t.Fatalf("StringToImportsOrdersWithGroups returned error: %v", err)
+			}
+			opts := []SourceFileOption{WithImportGroups(groups), WithImportsOrder(order)}
+			if tt.localPkgPrefixes != "" {
+				opts = append(opts, WithCompanyPackagePrefixes(tt.localPkgPrefixes))
+			}
+			runFixCase(t, testProjectName, testFilePath, tt.archive, tt.wantChange, false, opts...)
+		})
+	}
+}
+
 func TestSourceFile_Fix_WithFormat(t *testing.T) {
 	tests := map[string]struct {
 		projectName string
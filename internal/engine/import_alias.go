@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const importAliasAssignment = "="
+
+// ImportAlias requires imports of Path to be named Name, e.g. corev1 for
+// "k8s.io/api/core/v1".
+type ImportAlias struct {
+	Name string
+	Path string
+}
+
+// StringToImportAliases parses comma-separated required aliases of the form
+// "name=path", e.g. "corev1=k8s.io/api/core/v1,metav1=k8s.io/apimachinery/pkg/apis/meta/v1".
+func StringToImportAliases(s string) ([]ImportAlias, error) {
+	var aliases []ImportAlias
+	for segment := range strings.SplitSeq(s, stringValueSeparator) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		name, importPath, ok := strings.Cut(segment, importAliasAssignment)
+		if !ok {
+			return nil, fmt.Errorf(`import alias %q must have the form "name=path"`, segment)
+		}
+		aliases = append(aliases, ImportAlias{Name: strings.TrimSpace(name), Path: strings.TrimSpace(importPath)})
+	}
+
+	if _, err := compileImportAliases(aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// compileImportAliases validates aliases and maps their paths to their names.
+func compileImportAliases(aliases []ImportAlias) (map[string]string, error) {
+	byPath := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		if !token.IsIdentifier(alias.Name) || alias.Name == "_" {
+			return nil, fmt.Errorf("import alias %q of %q is not a valid package name", alias.Name, alias.Path)
+		}
+		if alias.Path == "" {
+			return nil, fmt.Errorf("import alias %q has no path", alias.Name)
+		}
+		if name, ok := byPath[alias.Path]; ok && name != alias.Name {
+			return nil, fmt.Errorf("import %q has conflicting aliases %q and %q", alias.Path, name, alias.Name)
+		}
+		byPath[alias.Path] = alias.Name
+	}
+	return byPath, nil
+}
+
+// aliasedImport is an import that is referred to by name in a file.
+type aliasedImport struct {
+	spec     *ast.ImportSpec
+	path     string
+	name     string // the name the file refers to the import by
+	realName string // the package name reported by pkgdeps, if known
+}
+
+// applyAliasPolicy enforces the required aliases and removes redundant ones
+// on the imports of file, renaming the uses of every renamed import. An import
+// that already occupies a required alias is renamed as well, to a name
+// derived from its path. packageImports provides the package names of the
+// imports; redundancy is only decided for imports listed there.
+func (f *SourceFile) applyAliasPolicy(file *ast.File, packageImports map[string]string) error {
+	var imports []*aliasedImport
+	for _, spec := range file.Imports {
+		importPath := strings.Trim(spec.Path.Value, `"`)
+		if importPath == "C" || spec.Name != nil && (spec.Name.Name == "_" || spec.Name.Name == ".") {
+			continue
+		}
+
+		imprt := &aliasedImport{spec: spec, path: importPath, realName: packageImports[importPath]}
+		switch {
+		case spec.Name != nil:
+			imprt.name = spec.Name.Name
+		case imprt.realName != "":
+			imprt.name = imprt.realName
+		default:
+			imprt.name = path.Base(importPath)
+		}
+		imports = append(imports, imprt)
+	}
+	if len(imports) == 0 {
+		return nil
+	}
+
+	uses, declared := collectImportUses(file, imports)
+
+	taken := func(name string) bool {
+		if declared[name] {
+			return true
+		}
+		for _, imprt := range imports {
+			if imprt.name == name {
+				return true
+			}
+		}
+		return false
+	}
+	rename := func(imprt *aliasedImport, name string) {
+		for _, ident := range uses[imprt] {
+			ident.Name = name
+		}
+		imprt.name = name
+		if name == imprt.realName {
+			imprt.spec.Name = nil
+			return
+		}
+		imprt.spec.Name = &ast.Ident{NamePos: imprt.spec.Path.Pos(), Name: name}
+	}
+
+	for _, imprt := range imports {
+		want, ok := f.requiredAliases[imprt.path]
+		if !ok || imprt.name == want {
+			continue
+		}
+		if declared[want] {
+			return fmt.Errorf("cannot name import %q %s: the name is already declared in the file", imprt.path, want)
+		}
+
+		for _, other := range imports {
+			if other == imprt || other.name != want {
+				continue
+			}
+			if otherWant, ok := f.requiredAliases[other.path]; ok && !taken(otherWant) {
+				rename(other, otherWant)
+				continue
+			}
+			rename(other, freeImportName(other.path, taken))
+		}
+		rename(imprt, want)
+	}
+
+	if f.shouldRemoveRedundantAliases {
+		for _, imprt := range imports {
+			if imprt.spec.Name != nil && imprt.spec.Name.Name == imprt.realName {
+				imprt.spec.Name = nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectImportUses returns the identifiers of file that refer to each import
+// and the other names declared or used in file, which renamed imports must not
+// shadow.
+func collectImportUses(file *ast.File, imports []*aliasedImport) (map[*aliasedImport][]*ast.Ident, map[string]bool) {
+	byName := make(map[string]*aliasedImport, len(imports))
+	for _, imprt := range imports {
+		byName[imprt.name] = imprt
+	}
+
+	uses := make(map[*aliasedImport][]*ast.Ident)
+	skip := make(map[*ast.Ident]bool)
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			skip[spec.Name] = true
+		}
+	}
+	skip[file.Name] = true
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		// Field and method names live in their own namespace.
+		skip[sel.Sel] = true
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok || ident.Obj != nil {
+			return true
+		}
+		if imprt, ok := byName[ident.Name]; ok {
+			uses[imprt] = append(uses[imprt], ident)
+			skip[ident] = true
+		}
+		return true
+	})
+
+	declared := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && !skip[ident] {
+			declared[ident.Name] = true
+		}
+		return true
+	})
+	return uses, declared
+}
+
+// freeImportName derives a name for importPath that is not taken, from its
+// last two path elements, e.g. metav1 for "k8s.io/apimachinery/pkg/apis/meta/v1",
+// followed by a number if needed.
+func freeImportName(importPath string, taken func(string) bool) string {
+	elems := strings.Split(importPath, "/")
+	if len(elems) > 2 {
+		elems = elems[len(elems)-2:]
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.Join(elems, "")) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	base := b.String()
+	if base == "" || !unicode.IsLetter([]rune(base)[0]) || token.IsKeyword(base) {
+		base = "pkg" + base
+	}
+
+	name := base
+	for i := 2; taken(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestStringToImportAliases(t *testing.T) {
+	t.Parallel()
+
+	got, err := StringToImportAliases(" corev1 = k8s.io/api/core/v1, metav1=k8s.io/apimachinery/pkg/apis/meta/v1,")
+	if err != nil {
+		t.Fatalf("StringToImportAliases returned error: %v", err)
+	}
+	want := []ImportAlias{
+		{Name: "corev1", Path: "k8s.io/api/core/v1"},
+		{Name: "metav1", Path: "k8s.io/apimachinery/pkg/apis/meta/v1"},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("StringToImportAliases mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStringToImportAliasesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		wantErr string
+	}{
+		"missing assignment": {
+			input:   "corev1",
+			wantErr: `import alias "corev1" must have the form "name=path"`,
+		},
+		"invalid name": {
+			input:   "core-v1=k8s.io/api/core/v1",
+			wantErr: `import alias "core-v1" of "k8s.io/api/core/v1" is not a valid package name`,
+		},
+		"blank name": {
+			input:   "_=k8s.io/api/core/v1",
+			wantErr: `import alias "_" of "k8s.io/api/core/v1" is not a valid package name`,
+		},
+		"missing path": {
+			input:   "corev1=",
+			wantErr: `import alias "corev1" has no path`,
+		},
+		"conflicting aliases": {
+			input:   "corev1=k8s.io/api/core/v1,v1=k8s.io/api/core/v1",
+			wantErr: `import "k8s.io/api/core/v1" has conflicting aliases "corev1" and "v1"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := StringToImportAliases(tt.input)
+			if got != nil {
+				t.Fatalf("StringToImportAliases returned aliases on error: %v", got)
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("StringToImportAliases error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFreeImportName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		importPath string
+		taken      []string
+		want       string
+	}{
+		"last two elements": {importPath: "k8s.io/apimachinery/pkg/apis/meta/v1", want: "metav1"},
+		"short path":        {importPath: "html/template", want: "htmltemplate"},
+		"punctuation":       {importPath: "gopkg.in/yaml.v3", want: "gopkginyamlv3"},
+		"taken names":       {importPath: "html/template", taken: []string{"htmltemplate", "htmltemplate2"}, want: "htmltemplate3"},
+		"leading digit":     {importPath: "example.com/1/x", want: "pkg1x"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			taken := func(name string) bool { return slices.Contains(tt.taken, name) }
+			if got := freeImportName(tt.importPath, taken); got != tt.want {
+				t.Fatalf("freeImportName(%q) = %q, want %q", tt.importPath, got, tt.want)
+			}
+		})
+	}
+}
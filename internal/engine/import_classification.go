@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
 	"github.com/zchee/goimports-rereviser/v4/pkg/std"
 )
 
@@ -14,11 +17,16 @@ const (
 	importBucketCompany
 	importBucketProject
 	importBucketDotted
+	importBucketWorkspace
 )
 
+// classifiedImport is the builtin bucket of an import. matchLen is the length
+// of the company prefix or project name that selected the bucket, and is used
+// to rank it against custom import groups.
 type classifiedImport struct {
-	bucket importBucket
-	named  bool
+	bucket   importBucket
+	named    bool
+	matchLen int
 }
 
 func classifyImport(
@@ -36,19 +44,60 @@ func classifyImport(
 	isBlank := strings.HasPrefix(imprt, "_ ")
 	isNamed := separateNamed && !isBlank && strings.Contains(imprt, " ")
 
-	if _, ok := std.StdPackages[pkgWithoutAlias]; ok {
+	if std.IsStdPackage(pkgWithoutAlias) {
 		return classifiedImport{bucket: importBucketStd, named: isNamed}
 	}
 
 	for _, localPackagePrefix := range localPkgPrefixes {
 		if strings.HasPrefix(pkgWithoutAlias, localPackagePrefix) && pkgWithoutAlias != projectName && !strings.HasPrefix(pkgWithoutAlias, projectName+"/") {
-			return classifiedImport{bucket: importBucketCompany, named: isNamed}
+			return classifiedImport{bucket: importBucketCompany, named: isNamed, matchLen: len(localPackagePrefix)}
 		}
 	}
 
 	if pkgWithoutAlias == projectName || strings.HasPrefix(pkgWithoutAlias, projectName+"/") {
-		return classifiedImport{bucket: importBucketProject, named: isNamed}
+		return classifiedImport{bucket: importBucketProject, named: isNamed, matchLen: len(projectName)}
 	}
 
 	return classifiedImport{bucket: importBucketGeneral, named: isNamed}
 }
+
+// classifyWorkspaceImport moves an import of one of workspaceModules out of
+// the general and company buckets, unless a company prefix matched more of
+// it: into the workspace bucket when the imports order has a workspace group,
+// and into the project bucket otherwise.
+func classifyWorkspaceImport(classified classifiedImport, workspaceModules []string, importsOrders ImportsOrders, pkg string) classifiedImport {
+	if classified.bucket != importBucketGeneral && classified.bucket != importBucketCompany {
+		return classified
+	}
+
+	var matchLen int
+	for _, modulePath := range workspaceModules {
+		if len(modulePath) > matchLen && (pkg == modulePath || strings.HasPrefix(pkg, modulePath+"/")) {
+			matchLen = len(modulePath)
+		}
+	}
+	if matchLen == 0 || matchLen < classified.matchLen {
+		return classified
+	}
+
+	classified.matchLen = matchLen
+	classified.bucket = importBucketProject
+	if slices.Contains(importsOrders, WorkspaceImportsOrder) {
+		classified.bucket = importBucketWorkspace
+	}
+	return classified
+}
+
+// workspaceModulesOf returns the workspace modules of the module containing
+// filePath, or none when it is not part of a module.
+func workspaceModulesOf(filePath string) ([]string, error) {
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	root, err := modulepath.GoModRootPath(dir)
+	if err != nil || root == "" {
+		return nil, err
+	}
+	return modulepath.WorkspaceModules(root)
+}
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	importGroupSeparator   = ";"
+	importGroupAssignment  = "="
+	importPatternRegexp    = "re:"
+	importPatternWildcard  = "..."
+	importPatternGlobChars = "*?["
+)
+
+// ImportGroup is a user-named import group. An import belongs to the group
+// when its path matches one of Patterns, which are either:
+//
+//   - "re:<expr>": a regular expression, e.g. "re:/gen/",
+//   - a pattern with "..." wildcards, as in go list, e.g. "k8s.io/..." or ".../gen/...",
+//   - a glob, as in path.Match, e.g. "google.golang.org/*/v2",
+//   - otherwise a plain path prefix, like -company-prefixes.
+//
+// Only groups that appear in the imports order are applied. Std and dotted
+// imports keep their buckets; for other imports the most specific match wins
+// across custom groups, company prefixes and the project name. Specificity is
+// the number of literal characters of a pattern, or the length of the
+// expression for regular expressions.
+type ImportGroup struct {
+	Name     ImportsOrder
+	Patterns []string
+}
+
+type importGroupMatcher struct {
+	name     ImportsOrder
+	patterns []importPattern
+}
+
+type importPattern struct {
+	match       func(pkg string) bool
+	specificity int
+}
+
+func compileImportGroups(groups []ImportGroup) ([]importGroupMatcher, error) {
+	matchers := make([]importGroupMatcher, 0, len(groups))
+	seen := make(map[ImportsOrder]struct{}, len(groups))
+	for _, group := range groups {
+		if err := validateImportGroupName(group.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[group.Name]; ok {
+			return nil, fmt.Errorf("duplicate import group %q", group.Name)
+		}
+		seen[group.Name] = struct{}{}
+
+		if len(group.Patterns) == 0 {
+			return nil, fmt.Errorf("import group %q has no patterns", group.Name)
+		}
+
+		matcher := importGroupMatcher{name: group.Name}
+		for _, pattern := range group.Patterns {
+			compiled, err := compileImportPattern(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("import group %q: %w", group.Name, err)
+			}
+			matcher.patterns = append(matcher.patterns, compiled)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+func validateImportGroupName(name ImportsOrder) error {
+	switch name {
+	case "":
+		return fmt.Errorf("import group name must not be empty")
+	case StdImportsOrder, CompanyImportsOrder, ProjectImportsOrder,
+		GeneralImportsOrder, BlankedImportsOrder, DottedImportsOrder, WorkspaceImportsOrder:
+		return fmt.Errorf("import group name %q is reserved", name)
+	}
+	if strings.ContainsAny(string(name), stringValueSeparator+importGroupSeparator+importGroupAssignment+" \t") {
+		return fmt.Errorf("invalid import group name %q", name)
+	}
+	return nil
+}
+
+func compileImportPattern(pattern string) (importPattern, error) {
+	switch {
+	case pattern == "":
+		return importPattern{}, fmt.Errorf("empty import pattern")
+
+	case strings.HasPrefix(pattern, importPatternRegexp):
+		expr := strings.TrimPrefix(pattern, importPatternRegexp)
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return importPattern{}, fmt.Errorf("invalid import pattern %q: %w", pattern, err)
+		}
+		return importPattern{match: re.MatchString, specificity: len(expr)}, nil
+
+	case strings.Contains(pattern, importPatternWildcard):
+		// Same translation as the go command uses for package patterns: "..."
+		// matches any string, and a trailing "/..." also matches the bare prefix.
+		expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`)
+		if strings.HasSuffix(expr, `/.*`) {
+			expr = strings.TrimSuffix(expr, `/.*`) + `(/.*)?`
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return importPattern{}, fmt.Errorf("invalid import pattern %q: %w", pattern, err)
+		}
+		literal := strings.ReplaceAll(pattern, importPatternWildcard, "")
+		return importPattern{match: re.MatchString, specificity: len(strings.Trim(literal, "/"))}, nil
+
+	case strings.ContainsAny(pattern, importPatternGlobChars):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return importPattern{}, fmt.Errorf("invalid import pattern %q: %w", pattern, err)
+		}
+		match := func(pkg string) bool {
+			matched, _ := path.Match(pattern, pkg)
+			return matched
+		}
+		literal := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(importPatternGlobChars+"]", r) {
+				return -1
+			}
+			return r
+		}, pattern)
+		return importPattern{match: match, specificity: len(literal)}, nil
+
+	default:
+		match := func(pkg string) bool {
+			return strings.HasPrefix(pkg, pattern)
+		}
+		return importPattern{match: match, specificity: len(pattern)}, nil
+	}
+}
+
+// matchImportGroup returns the custom group whose most specific pattern
+// matches pkg, provided it is more specific than minSpecificity and the group
+// is part of importsOrders.
+func matchImportGroup(groups []importGroupMatcher, importsOrders ImportsOrders, pkg string, minSpecificity int) (ImportsOrder, bool) {
+	var (
+		best      ImportsOrder
+		bestScore = minSpecificity
+		found     bool
+	)
+	for _, group := range groups {
+		if !slices.Contains(importsOrders, group.name) {
+			continue
+		}
+		for _, pattern := range group.patterns {
+			if pattern.specificity > bestScore && pattern.match(pkg) {
+				best, bestScore, found = group.name, pattern.specificity, true
+			}
+		}
+	}
+	return best, found
+}
+
+// StringToImportGroups converts a string, like
+// "k8s=k8s.io/...,sigs.k8s.io/...;gen=re:/gen/" to ImportGroup array type.
+// Groups are separated by ";" and patterns within a group by ",".
+func StringToImportGroups(s string) ([]ImportGroup, error) {
+	var groups []ImportGroup
+	for segment := range strings.SplitSeq(s, importGroupSeparator) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		name, patterns, ok := strings.Cut(segment, importGroupAssignment)
+		if !ok {
+			return nil, fmt.Errorf(`import group %q must have the form "name=pattern[,pattern...]"`, segment)
+		}
+
+		group := ImportGroup{Name: ImportsOrder(strings.TrimSpace(name))}
+		for pattern := range strings.SplitSeq(patterns, stringValueSeparator) {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				group.Patterns = append(group.Patterns, pattern)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	if _, err := compileImportGroups(groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
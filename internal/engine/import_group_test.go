@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestCompileImportPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pattern         string
+		matches         []string
+		misses          []string
+		wantSpecificity int
+	}{
+		"prefix": {
+			pattern:         "github.com/acme",
+			matches:         []string{"github.com/acme", "github.com/acme/tools", "github.com/acmecorp/x"},
+			misses:          []string{"github.com/other"},
+			wantSpecificity: len("github.com/acme"),
+		},
+		"trailing wildcard also matches the parent": {
+			pattern:         "k8s.io/...",
+			matches:         []string{"k8s.io", "k8s.io/api/core/v1"},
+			misses:          []string{"k8s.io.example.com/x", "sigs.k8s.io/yaml"},
+			wantSpecificity: len("k8s.io"),
+		},
+		"inner wildcard": {
+			pattern:         ".../gen/...",
+			matches:         []string{"example.com/gen/foo", "example.com/api/gen"},
+			misses:          []string{"example.com/generated/foo"},
+			wantSpecificity: len("gen"),
+		},
+		"glob": {
+			pattern:         "google.golang.org/*/v2",
+			matches:         []string{"google.golang.org/protobuf/v2"},
+			misses:          []string{"google.golang.org/protobuf/v2/proto", "google.golang.org/a/b/v2"},
+			wantSpecificity: len("google.golang.org//v2"),
+		},
+		"regexp": {
+			pattern:         "re:/gen/",
+			matches:         []string{"example.com/proto/gen/foo"},
+			misses:          []string{"gen/foo"},
+			wantSpecificity: len("/gen/"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := compileImportPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("compileImportPattern(%q) returned error: %v", tt.pattern, err)
+			}
+			if got.specificity != tt.wantSpecificity {
+				t.Errorf("specificity = %d, want %d", got.specificity, tt.wantSpecificity)
+			}
+			for _, pkg := range tt.matches {
+				if !got.match(pkg) {
+					t.Errorf("pattern %q does not match %q", tt.pattern, pkg)
+				}
+			}
+			for _, pkg := range tt.misses {
+				if got.match(pkg) {
+					t.Errorf("pattern %q unexpectedly matches %q", tt.pattern, pkg)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchImportGroup(t *testing.T) {
+	t.Parallel()
+
+	groups, err := compileImportGroups([]ImportGroup{
+		{Name: "k8s", Patterns: []string{"k8s.io/..."}},
+		{Name: "client", Patterns: []string{"k8s.io/client-go/..."}},
+		{Name: "unused", Patterns: []string{"github.com/"}},
+	})
+	if err != nil {
+		t.Fatalf("compileImportGroups returned error: %v", err)
+	}
+	orders := ImportsOrders{StdImportsOrder, "k8s", "client", GeneralImportsOrder, CompanyImportsOrder, ProjectImportsOrder}
+
+	tests := map[string]struct {
+		pkg            string
+		minSpecificity int
+		want           ImportsOrder
+		wantOK         bool
+	}{
+		"matching group": {
+			pkg:    "k8s.io/api/core/v1",
+			want:   "k8s",
+			wantOK: true,
+		},
+		"most specific group wins": {
+			pkg:    "k8s.io/client-go/kubernetes",
+			want:   "client",
+			wantOK: true,
+		},
+		"builtin match is more specific": {
+			pkg:            "k8s.io/api/core/v1",
+			minSpecificity: len("k8s.io/api"),
+		},
+		"group missing from the order is ignored": {
+			pkg: "github.com/pkg/errors",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := matchImportGroup(groups, orders, tt.pkg, tt.minSpecificity)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("matchImportGroup(%q) = %q, %t, want %q, %t", tt.pkg, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStringToImportGroups(t *testing.T) {
+	t.Parallel()
+
+	got, err := StringToImportGroups(" k8s = k8s.io/..., sigs.k8s.io/... ; gen=re:/gen/;")
+	if err != nil {
+		t.Fatalf("StringToImportGroups returned error: %v", err)
+	}
+	want := []ImportGroup{
+		{Name: "k8s", Patterns: []string{"k8s.io/...", "sigs.k8s.io/..."}},
+		{Name: "gen", Patterns: []string{"re:/gen/"}},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("StringToImportGroups mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStringToImportGroupsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		wantErr string
+	}{
+		"missing assignment": {
+			input:   "k8s",
+			wantErr: `import group "k8s" must have the form "name=pattern[,pattern...]"`,
+		},
+		"reserved name": {
+			input:   "std=k8s.io/",
+			wantErr: `import group name "std" is reserved`,
+		},
+		"duplicate name": {
+			input:   "k8s=k8s.io/;k8s=sigs.k8s.io/",
+			wantErr: `duplicate import group "k8s"`,
+		},
+		"no patterns": {
+			input:   "k8s=",
+			wantErr: `import group "k8s" has no patterns`,
+		},
+		"invalid regexp": {
+			input:   "gen=re:(",
+			wantErr: "import group \"gen\": invalid import pattern \"re:(\": error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := StringToImportGroups(tt.input)
+			if got != nil {
+				t.Errorf("expected nil, got: %v", got)
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -23,6 +23,10 @@ const (
 	BlankedImportsOrder ImportsOrder = "blanked"
 	// DottedImportsOrder is separate group for "." imports
 	DottedImportsOrder ImportsOrder = "dotted"
+	// WorkspaceImportsOrder is packages of the other modules of the go.work
+	// workspace and of modules replaced with local directories. Without it,
+	// they are part of the project group. See WithWorkspaceModules.
+	WorkspaceImportsOrder ImportsOrder = "workspace"
 )
 
 const (
@@ -55,6 +59,10 @@ func (o ImportsOrders) sortImportsByOrder(importGroups *groupsImports) [][]strin
 			continue
 		case DottedImportsOrder:
 			imports = importGroups.dotted
+		default:
+			if custom := importGroups.custom[group]; custom != nil {
+				imports = appendGroups(custom.plain, custom.named)
+			}
 		}
 
 		result = append(result, imports)
@@ -92,6 +100,13 @@ func (o ImportsOrders) hasRequiredGroups() bool {
 // StringToImportsOrders will convert string, like "std,general,company,project" to ImportsOrder array type.
 // Default value for empty string is "std,general,company,project"
 func StringToImportsOrders(s string) (ImportsOrders, error) {
+	return StringToImportsOrdersWithGroups(s, nil)
+}
+
+// StringToImportsOrdersWithGroups is like StringToImportsOrders, but also
+// accepts the names of the given custom import groups. Every custom group must
+// be placed in the order.
+func StringToImportsOrdersWithGroups(s string, importGroups []ImportGroup) (ImportsOrders, error) {
 	if strings.TrimSpace(s) == "" {
 		s = defaultImportsOrder
 	}
@@ -103,9 +118,11 @@ func StringToImportsOrders(s string) (ImportsOrders, error) {
 		group := ImportsOrder(strings.TrimSpace(g))
 		switch group {
 		case StdImportsOrder, CompanyImportsOrder, ProjectImportsOrder,
-			GeneralImportsOrder, BlankedImportsOrder, DottedImportsOrder:
+			GeneralImportsOrder, BlankedImportsOrder, DottedImportsOrder, WorkspaceImportsOrder:
 		default:
-			return nil, fmt.Errorf(`unknown order group type: %q`, group)
+			if !slices.ContainsFunc(importGroups, func(g ImportGroup) bool { return g.Name == group }) {
+				return nil, fmt.Errorf(`unknown order group type: %q`, group)
+			}
 		}
 
 		groupOrder = append(groupOrder, group)
@@ -115,6 +132,12 @@ func StringToImportsOrders(s string) (ImportsOrders, error) {
 		return nil, fmt.Errorf(`use default at least 4 parameters to sort groups of your imports: %q`, defaultImportsOrder)
 	}
 
+	for _, importGroup := range importGroups {
+		if !slices.Contains(groupOrder, importGroup.Name) {
+			return nil, fmt.Errorf(`import group %q is missing from the imports order`, importGroup.Name)
+		}
+	}
+
 	return groupOrder, nil
 }
 
@@ -59,6 +59,28 @@ func TestStringToImportsOrdersAcceptsNoopGroups(t *testing.T) {
 	}
 }
 
+func TestStringToImportsOrdersWithGroups(t *testing.T) {
+	t.Parallel()
+
+	groups := []ImportGroup{{Name: "k8s", Patterns: []string{"k8s.io/..."}}}
+
+	got, err := StringToImportsOrdersWithGroups("std,k8s,general,company,project", groups)
+	if err != nil {
+		t.Fatalf("StringToImportsOrdersWithGroups returned error: %v", err)
+	}
+	want := ImportsOrders{StdImportsOrder, "k8s", GeneralImportsOrder, CompanyImportsOrder, ProjectImportsOrder}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("StringToImportsOrdersWithGroups mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := StringToImportsOrdersWithGroups("", groups); err == nil || err.Error() != `import group "k8s" is missing from the imports order` {
+		t.Fatalf("expected missing group error, got %v", err)
+	}
+	if _, err := StringToImportsOrders("std,k8s,general,company,project"); err == nil || err.Error() != `unknown order group type: "k8s"` {
+		t.Fatalf("expected unknown group error without groups, got %v", err)
+	}
+}
+
 func TestUnique_Deduplicates(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+const importRewriteArrow = "=>"
+
+// ImportRewrite replaces the prefix From of import paths with To, e.g. to
+// migrate "github.com/pkg/errors" to "errors" or ".../v2" to ".../v3". A
+// prefix matches whole path elements only. When Alias is set, rewritten
+// imports are named with it; otherwise they keep their explicit name, if any.
+type ImportRewrite struct {
+	From  string
+	To    string
+	Alias string
+}
+
+// PathRewrite records an import path replaced by an ImportRewrite.
+type PathRewrite struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// StringToImportRewrites parses comma-separated rewrite rules of the form
+// "old-prefix => new-prefix [alias]", e.g.
+// "github.com/pkg/errors => errors,example.com/lib/v2 => example.com/lib/v3 lib".
+func StringToImportRewrites(s string) ([]ImportRewrite, error) {
+	var rewrites []ImportRewrite
+	for segment := range strings.SplitSeq(s, stringValueSeparator) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		from, target, ok := strings.Cut(segment, importRewriteArrow)
+		fields := strings.Fields(target)
+		if !ok || len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf(`import rewrite %q must have the form "old-prefix => new-prefix [alias]"`, segment)
+		}
+
+		rewrite := ImportRewrite{From: strings.TrimSpace(from), To: fields[0]}
+		if len(fields) == 2 {
+			rewrite.Alias = fields[1]
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+
+	if err := validateImportRewrites(rewrites); err != nil {
+		return nil, err
+	}
+	return rewrites, nil
+}
+
+func validateImportRewrites(rewrites []ImportRewrite) error {
+	seen := make(map[string]struct{}, len(rewrites))
+	for _, rewrite := range rewrites {
+		if rewrite.From == "" || rewrite.To == "" {
+			return fmt.Errorf("import rewrite %q => %q must have both prefixes", rewrite.From, rewrite.To)
+		}
+		if rewrite.Alias != "" && (!token.IsIdentifier(rewrite.Alias) || rewrite.Alias == "_") {
+			return fmt.Errorf("import rewrite alias %q of %q is not a valid package name", rewrite.Alias, rewrite.From)
+		}
+		if _, ok := seen[rewrite.From]; ok {
+			return fmt.Errorf("duplicate import rewrite of %q", rewrite.From)
+		}
+		seen[rewrite.From] = struct{}{}
+	}
+	return nil
+}
+
+// rewriteImportSpec applies the rule with the longest matching prefix to
+// spec, keeping its comments, and returns the new path.
+func (f *SourceFile) rewriteImportSpec(spec *ast.ImportSpec, importPath string) (string, bool) {
+	var (
+		rule  ImportRewrite
+		found bool
+	)
+	for _, rewrite := range f.importRewrites {
+		if importPath != rewrite.From && !strings.HasPrefix(importPath, rewrite.From+"/") {
+			continue
+		}
+		if !found || len(rewrite.From) > len(rule.From) {
+			rule, found = rewrite, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	newPath := rule.To + strings.TrimPrefix(importPath, rule.From)
+	if newPath == importPath {
+		return "", false
+	}
+	spec.Path.Value = strconv.Quote(newPath)
+	if rule.Alias != "" && (spec.Name == nil || (spec.Name.Name != "_" && spec.Name.Name != ".")) {
+		spec.Name = &ast.Ident{NamePos: spec.Path.Pos(), Name: rule.Alias}
+	}
+	return newPath, true
+}
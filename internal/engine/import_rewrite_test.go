@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestStringToImportRewrites(t *testing.T) {
+	t.Parallel()
+
+	got, err := StringToImportRewrites(" github.com/pkg/errors => errors, example.com/lib/v2=>example.com/lib/v3 lib ,")
+	if err != nil {
+		t.Fatalf("StringToImportRewrites returned error: %v", err)
+	}
+	want := []ImportRewrite{
+		{From: "github.com/pkg/errors", To: "errors"},
+		{From: "example.com/lib/v2", To: "example.com/lib/v3", Alias: "lib"},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("StringToImportRewrites mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStringToImportRewritesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		wantErr string
+	}{
+		"missing arrow": {
+			input:   "github.com/pkg/errors errors",
+			wantErr: `import rewrite "github.com/pkg/errors errors" must have the form "old-prefix => new-prefix [alias]"`,
+		},
+		"missing target": {
+			input:   "github.com/pkg/errors =>",
+			wantErr: `import rewrite "github.com/pkg/errors =>" must have the form "old-prefix => new-prefix [alias]"`,
+		},
+		"too many fields": {
+			input:   "a => b c d",
+			wantErr: `import rewrite "a => b c d" must have the form "old-prefix => new-prefix [alias]"`,
+		},
+		"missing source": {
+			input:   "=> errors",
+			wantErr: `import rewrite "" => "errors" must have both prefixes`,
+		},
+		"invalid alias": {
+			input:   "example.com/lib/v2 => example.com/lib/v3 lib-v3",
+			wantErr: `import rewrite alias "lib-v3" of "example.com/lib/v2" is not a valid package name`,
+		},
+		"blank alias": {
+			input:   "example.com/lib/v2 => example.com/lib/v3 _",
+			wantErr: `import rewrite alias "_" of "example.com/lib/v2" is not a valid package name`,
+		},
+		"duplicate source": {
+			input:   "a => b,a => c",
+			wantErr: `duplicate import rewrite of "a"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := StringToImportRewrites(tt.input)
+			if got != nil {
+				t.Fatalf("StringToImportRewrites returned rewrites on error: %v", got)
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("StringToImportRewrites error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRewriteImportSpec(t *testing.T) {
+	t.Parallel()
+
+	f := &SourceFile{importRewrites: []ImportRewrite{
+		{From: "example.com/lib", To: "example.com/lib/v2", Alias: "lib"},
+		{From: "example.com/lib/internal", To: "example.com/internal"},
+	}}
+
+	tests := map[string]struct {
+		spec     string
+		wantPath string
+		wantName string
+		wantOK   bool
+	}{
+		"exact match":         {spec: `"example.com/lib"`, wantPath: "example.com/lib/v2", wantName: "lib", wantOK: true},
+		"subpackage":          {spec: `"example.com/lib/sub"`, wantPath: "example.com/lib/v2/sub", wantName: "lib", wantOK: true},
+		"longest prefix":      {spec: `"example.com/lib/internal/x"`, wantPath: "example.com/internal/x", wantOK: true},
+		"keeps explicit name": {spec: `l "example.com/lib/internal"`, wantPath: "example.com/internal", wantName: "l", wantOK: true},
+		"keeps blank import":  {spec: `_ "example.com/lib"`, wantPath: "example.com/lib/v2", wantName: "_", wantOK: true},
+		"partial element":     {spec: `"example.com/library"`},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := parser.ParseFile(token.NewFileSet(), "", "package p\nimport "+tt.spec, parser.ImportsOnly)
+			if err != nil {
+				t.Fatalf("failed to parse spec: %v", err)
+			}
+			spec := file.Imports[0]
+			importPath := spec.Path.Value[1 : len(spec.Path.Value)-1]
+
+			gotPath, ok := f.rewriteImportSpec(spec, importPath)
+			if ok != tt.wantOK || gotPath != tt.wantPath {
+				t.Fatalf("rewriteImportSpec = %q, %v, want %q, %v", gotPath, ok, tt.wantPath, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got := spec.Path.Value; got != `"`+tt.wantPath+`"` {
+				t.Fatalf("spec path = %s, want %q", got, tt.wantPath)
+			}
+			if got := nameOf(spec.Name); got != tt.wantName {
+				t.Fatalf("spec name = %q, want %q", got, tt.wantName)
+			}
+		})
+	}
+}
+
+func nameOf(ident *ast.Ident) string {
+	if ident == nil {
+		return ""
+	}
+	return ident.Name
+}
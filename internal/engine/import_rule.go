@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+)
+
+const (
+	importRuleOnly        = " only "
+	importRuleReplacement = "=>"
+)
+
+// ImportRule restricts the imports whose paths match Patterns, which have the
+// syntax of ImportGroup patterns. Without AllowedIn the imports are banned
+// everywhere; otherwise only packages matching one of AllowedIn may import
+// them. When Replacement is set, a violating import is replaced with it.
+//
+// Patterns of both lists are matched against full import paths and, for
+// packages of the project, against their path relative to the project name,
+// so "internal/db" and "cmd/..." refer to packages of the project.
+type ImportRule struct {
+	Patterns    []string
+	AllowedIn   []string
+	Replacement string
+}
+
+// String returns the rule in the syntax accepted by StringToImportRules.
+func (r ImportRule) String() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(r.Patterns, stringValueSeparator))
+	if len(r.AllowedIn) > 0 {
+		b.WriteString(importRuleOnly)
+		b.WriteString(strings.Join(r.AllowedIn, stringValueSeparator))
+	}
+	if r.Replacement != "" {
+		b.WriteString(" " + importRuleReplacement + " ")
+		b.WriteString(r.Replacement)
+	}
+	return b.String()
+}
+
+// ImportViolation is an import that breaks an ImportRule, at Line and Column
+// of the file.
+type ImportViolation struct {
+	Import string `json:"import"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Rule   string `json:"rule"`
+	// Replacement is the path the import was replaced with, if the rule has one.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+func (v ImportViolation) String() string {
+	if v.Replacement != "" {
+		return fmt.Sprintf("import %q breaks rule %q, replaced with %q", v.Import, v.Rule, v.Replacement)
+	}
+	return fmt.Sprintf("import %q breaks rule %q", v.Import, v.Rule)
+}
+
+type importRuleMatcher struct {
+	rule      ImportRule
+	patterns  []importPattern
+	allowedIn []importPattern
+}
+
+func compileImportRules(rules []ImportRule) ([]importRuleMatcher, error) {
+	matchers := make([]importRuleMatcher, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("import rule %q has no patterns", rule)
+		}
+
+		matcher := importRuleMatcher{rule: rule}
+		for _, pattern := range rule.Patterns {
+			compiled, err := compileImportPattern(pattern)
+			if err != nil {
+				return nil, err
+			}
+			matcher.patterns = append(matcher.patterns, compiled)
+		}
+		for _, pattern := range rule.AllowedIn {
+			compiled, err := compileImportPattern(pattern)
+			if err != nil {
+				return nil, err
+			}
+			matcher.allowedIn = append(matcher.allowedIn, compiled)
+		}
+
+		if rule.Replacement != "" {
+			if err := module.CheckImportPath(rule.Replacement); err != nil {
+				return nil, fmt.Errorf("import rule %q has an invalid replacement: %w", rule, err)
+			}
+			if matchAny(matcher.patterns, rule.Replacement) {
+				return nil, fmt.Errorf("import rule %q bans its own replacement", rule)
+			}
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// StringToImportRules converts a string, like
+// "github.com/pkg/errors => errors;unsafe only pkg/lowlevel/...", into
+// ImportRules. Rules are separated by ";" and have the form
+// "pattern[,pattern...] [only pattern[,pattern...]] [=> replacement]".
+func StringToImportRules(s string) ([]ImportRule, error) {
+	var rules []ImportRule
+	for segment := range strings.SplitSeq(s, importGroupSeparator) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		var rule ImportRule
+		patterns, replacement, hasReplacement := strings.Cut(segment, importRuleReplacement)
+		if hasReplacement {
+			rule.Replacement = strings.TrimSpace(replacement)
+			if rule.Replacement == "" || strings.ContainsAny(rule.Replacement, " \t") {
+				return nil, fmt.Errorf(`import rule %q must have the form "patterns [only patterns] [=> replacement]"`, segment)
+			}
+		}
+		patterns, allowedIn, hasAllowedIn := strings.Cut(" "+patterns+" ", importRuleOnly)
+		rule.Patterns = splitImportPatterns(patterns)
+		if hasAllowedIn {
+			rule.AllowedIn = splitImportPatterns(allowedIn)
+			if len(rule.AllowedIn) == 0 {
+				return nil, fmt.Errorf(`import rule %q must have the form "patterns [only patterns] [=> replacement]"`, segment)
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	if _, err := compileImportRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func splitImportPatterns(s string) []string {
+	var patterns []string
+	for pattern := range strings.SplitSeq(s, stringValueSeparator) {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// checkImportRules returns the violation of the first rule spec breaks and
+// applies the replacement of that rule to spec. importer is the import path
+// of the package of the file; rules with AllowedIn are skipped when it is
+// unknown.
+func (f *SourceFile) checkImportRules(fset *token.FileSet, spec *ast.ImportSpec, importPath, importer string) (ImportViolation, bool) {
+	for _, matcher := range f.importRules {
+		if !f.matchProjectPath(matcher.patterns, importPath) {
+			continue
+		}
+		if len(matcher.allowedIn) > 0 && (importer == "" || f.matchProjectPath(matcher.allowedIn, importer)) {
+			continue
+		}
+
+		pos := fset.Position(spec.Pos())
+		violation := ImportViolation{
+			Import:      importPath,
+			Line:        pos.Line,
+			Column:      pos.Column,
+			Rule:        matcher.rule.String(),
+			Replacement: matcher.rule.Replacement,
+		}
+		if violation.Replacement != "" {
+			spec.Path.Value = strconv.Quote(violation.Replacement)
+		}
+		return violation, true
+	}
+	return ImportViolation{}, false
+}
+
+// matchProjectPath reports whether pkg, or its path relative to the project
+// name, matches one of patterns.
+func (f *SourceFile) matchProjectPath(patterns []importPattern, pkg string) bool {
+	if matchAny(patterns, pkg) {
+		return true
+	}
+	if f.projectName == "" {
+		return false
+	}
+	rel, ok := strings.CutPrefix(pkg, f.projectName+"/")
+	return ok && matchAny(patterns, rel)
+}
+
+func matchAny(patterns []importPattern, pkg string) bool {
+	for _, pattern := range patterns {
+		if pattern.match(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// importerPath returns the import path of the package of the file, derived
+// from the project name and the directory of the file within its module. It
+// is empty when the file is not part of a module.
+func (f *SourceFile) importerPath() string {
+	if f.filePath == StandardInput || f.projectName == "" {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(f.filePath))
+	if err != nil {
+		return ""
+	}
+	root, err := modulepath.GoModRootPath(dir)
+	if err != nil || root == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." {
+		return f.projectName
+	}
+	return path.Join(f.projectName, filepath.ToSlash(rel))
+}
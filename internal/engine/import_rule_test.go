@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestStringToImportRules(t *testing.T) {
+	t.Parallel()
+
+	input := " github.com/pkg/errors => errors ; internal/db/... only cmd/...,internal/db/...;unsafe,reflect only pkg/lowlevel;"
+	got, err := StringToImportRules(input)
+	if err != nil {
+		t.Fatalf("StringToImportRules returned error: %v", err)
+	}
+	want := []ImportRule{
+		{Patterns: []string{"github.com/pkg/errors"}, Replacement: "errors"},
+		{Patterns: []string{"internal/db/..."}, AllowedIn: []string{"cmd/...", "internal/db/..."}},
+		{Patterns: []string{"unsafe", "reflect"}, AllowedIn: []string{"pkg/lowlevel"}},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("StringToImportRules mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, rule := range got {
+		roundTrip, err := StringToImportRules(rule.String())
+		if err != nil {
+			t.Fatalf("StringToImportRules(%q) returned error: %v", rule, err)
+		}
+		if diff := gocmp.Diff([]ImportRule{rule}, roundTrip); diff != "" {
+			t.Fatalf("rule %q does not round trip (-want +got):\n%s", rule, diff)
+		}
+	}
+}
+
+func TestStringToImportRulesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		wantErr string
+	}{
+		"missing patterns": {
+			input:   "only cmd/...",
+			wantErr: `import rule " only cmd/..." has no patterns`,
+		},
+		"missing allowed packages": {
+			input:   "unsafe only ",
+			wantErr: `import rule "unsafe only" must have the form "patterns [only patterns] [=> replacement]"`,
+		},
+		"missing replacement": {
+			input:   "github.com/pkg/errors =>",
+			wantErr: `import rule "github.com/pkg/errors =>" must have the form "patterns [only patterns] [=> replacement]"`,
+		},
+		"invalid replacement": {
+			input:   `github.com/pkg/errors => "errors"`,
+			wantErr: `import rule "github.com/pkg/errors => \"errors\"" has an invalid replacement: malformed import path "\"errors\"": invalid char '"'`,
+		},
+		"banned replacement": {
+			input:   "github.com/pkg/... => github.com/pkg/errors2",
+			wantErr: `import rule "github.com/pkg/... => github.com/pkg/errors2" bans its own replacement`,
+		},
+		"invalid pattern": {
+			input:   "re:[",
+			wantErr: "invalid import pattern \"re:[\": error parsing regexp: missing closing ]: `[`",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := StringToImportRules(tt.input)
+			if got != nil {
+				t.Fatalf("StringToImportRules returned rules on error: %v", got)
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("StringToImportRules error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSourceFile_FixResult_WithImportRules(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/rules\n\ngo 1.22\n",
+		"cmd/app/main.go": `package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"example.com/rules/internal/db"
+	"github.com/pkg/errors" // wrapped errors
+)
+
+var _ = fmt.Sprint(unsafe.Sizeof(0), db.Open, errors.New)
+`,
+		"pkg/lowlevel/mem.go": `package lowlevel
+
+import (
+	"unsafe"
+
+	"example.com/rules/internal/db"
+)
+
+var _, _ = unsafe.Sizeof(0), db.Open
+`,
+	}
+	testutil.WriteFiles(t, root, files)
+
+	rules, err := StringToImportRules("github.com/pkg/errors => errors;internal/db only cmd/...;unsafe only pkg/lowlevel")
+	if err != nil {
+		t.Fatalf("StringToImportRules returned error: %v", err)
+	}
+
+	tests := map[string]struct {
+		file           string
+		wantViolations []ImportViolation
+		wantContent    string
+	}{
+		"command": {
+			file: "cmd/app/main.go",
+			wantViolations: []ImportViolation{
+				{Import: "unsafe", Line: 5, Column: 2, Rule: "unsafe only pkg/lowlevel"},
+				{Import: "github.com/pkg/errors", Line: 8, Column: 2, Rule: "github.com/pkg/errors => errors", Replacement: "errors"},
+			},
+			wantContent: `package main
+
+import (
+	"errors" // wrapped errors
+	"fmt"
+	"unsafe"
+
+	"example.com/rules/internal/db"
+)
+
+var _ = fmt.Sprint(unsafe.Sizeof(0), db.Open, errors.New)
+`,
+		},
+		"low level package": {
+			file: "pkg/lowlevel/mem.go",
+			wantViolations: []ImportViolation{
+				{Import: "example.com/rules/internal/db", Line: 6, Column: 2, Rule: "internal/db only cmd/..."},
+			},
+			wantContent: files["pkg/lowlevel/mem.go"],
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := NewSourceFile("example.com/rules", filepath.Join(root, filepath.FromSlash(tt.file))).FixResult(WithImportRules(rules))
+			if err != nil {
+				t.Fatalf("FixResult returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.wantViolations, result.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := gocmp.Diff(tt.wantContent, string(result.Content)); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,13 @@ package engine
 type groupsImports struct {
 	*common
 	dotted []string
+	custom map[ImportsOrder]*customGroupImports
+}
+
+// customGroupImports holds the imports matched by a custom ImportGroup.
+type customGroupImports struct {
+	plain []string
+	named []string
 }
 
 type common struct {
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// FileReport describes the outcome of processing a single file.
+type FileReport struct {
+	Path     string     `json:"path"`
+	Changed  bool       `json:"changed"`
+	Error    string     `json:"error,omitempty"`
+	CacheHit bool       `json:"cache_hit,omitempty"`
+	Skipped  SkipReason `json:"skipped,omitempty"`
+	ImportChanges
+	Violations []ImportViolation `json:"violations,omitempty"`
+}
+
+// ImportChanges lists the import-level edits applied to a file. Added holds
+// imports inserted for unresolved references, Rewritten holds import paths
+// replaced by rewrite rules, Moved holds imports whose position or group
+// changed, and MergedDecls is the number of import declarations merged into
+// the first one.
+type ImportChanges struct {
+	Added       []string      `json:"added,omitempty"`
+	Removed     []string      `json:"removed,omitempty"`
+	Rewritten   []PathRewrite `json:"rewritten,omitempty"`
+	Moved       []string      `json:"moved,omitempty"`
+	Aliased     []AliasChange `json:"aliased,omitempty"`
+	MergedDecls int           `json:"merged_decls,omitempty"`
+}
+
+// AliasChange describes an import whose explicit package name changed. An
+// empty From or To means the import had or has no explicit name.
+type AliasChange struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// importKey identifies an import by its explicit name and its path, so a path
+// imported twice, e.g. blank and named, is told apart.
+type importKey struct {
+	name string
+	path string
+}
+
+// importLayout is an import of a file and the index of its blank-line group
+// within the import block.
+type importLayout struct {
+	importKey
+	group int
+}
+
+// computeImportChanges compares the imports of the original content with the
+// imports of the fixed content. Both are parsed again, since fixing rewrites
+// the import specs of the original syntax tree in place. The paths of
+// rewrites are reported as rewritten rather than as removed and added.
+func computeImportChanges(original, fixed []byte, rewrites []PathRewrite) (ImportChanges, error) {
+	fset := token.NewFileSet()
+	originalFile, err := parser.ParseFile(fset, "", original, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return ImportChanges{}, err
+	}
+	fixedFile, err := parser.ParseFile(fset, "", fixed, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return ImportChanges{}, err
+	}
+
+	before := layoutImports(fset, originalFile.Imports)
+	after := layoutImports(fset, fixedFile.Imports)
+
+	// Pair the imports of both versions: first the imports with the same name
+	// and path, then the remaining imports of a path, whose name changed.
+	pairs := make([]int, len(before))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	paired := make([]bool, len(after))
+	pair := func(same func(prev, next importLayout) bool) {
+		for i, prev := range before {
+			if pairs[i] >= 0 {
+				continue
+			}
+			for j, next := range after {
+				if !paired[j] && same(prev, next) {
+					pairs[i], paired[j] = j, true
+					break
+				}
+			}
+		}
+	}
+	pair(func(prev, next importLayout) bool { return prev.importKey == next.importKey })
+	pair(func(prev, next importLayout) bool { return prev.path == next.path })
+
+	rewritten := make(map[string]PathRewrite, len(rewrites))
+	rewrittenTo := make(map[string]bool, len(rewrites))
+	for _, rewrite := range rewrites {
+		rewritten[rewrite.From] = rewrite
+		rewrittenTo[rewrite.To] = true
+	}
+
+	var (
+		changes   ImportChanges
+		surviving []int
+	)
+	for i, prev := range before {
+		if pairs[i] >= 0 {
+			surviving = append(surviving, i)
+			continue
+		}
+		if rewrite, ok := rewritten[prev.path]; ok {
+			changes.Rewritten = append(changes.Rewritten, rewrite)
+			continue
+		}
+		changes.Removed = append(changes.Removed, prev.path)
+	}
+
+	// Positions are compared among surviving imports only, so removing or
+	// adding an import does not report every following import as moved.
+	survivingIndex := make(map[int]int, len(surviving))
+	for j, next := range after {
+		if !paired[j] {
+			if !rewrittenTo[next.path] {
+				changes.Added = append(changes.Added, next.path)
+			}
+			continue
+		}
+		survivingIndex[j] = len(survivingIndex)
+	}
+
+	groups := correspondingGroups(before, after, pairs, surviving)
+	for idx, i := range surviving {
+		prev, next := before[i], after[pairs[i]]
+		if prev.name != next.name {
+			changes.Aliased = append(changes.Aliased, AliasChange{Path: prev.path, From: prev.name, To: next.name})
+		}
+		if idx != survivingIndex[pairs[i]] || groups[prev.group] != next.group {
+			changes.Moved = append(changes.Moved, prev.path)
+		}
+	}
+
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Removed)
+	slices.SortFunc(changes.Rewritten, func(a, b PathRewrite) int {
+		return strings.Compare(a.From, b.From)
+	})
+	slices.Sort(changes.Moved)
+	slices.SortFunc(changes.Aliased, func(a, b AliasChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return changes, nil
+}
+
+// correspondingGroups maps the groups of the original imports to the groups of
+// the fixed imports, one to one, by their surviving imports: in order, every
+// original group takes the fixed group holding most of its imports that no
+// earlier group took, or -1 if there is none. Group indices themselves are not
+// compared, so a group that disappears entirely does not move the imports of
+// the following groups, while an import leaving the imports it was grouped
+// with is moved.
+func correspondingGroups(before, after []importLayout, pairs, surviving []int) map[int]int {
+	var (
+		order  []int
+		counts = make(map[int]map[int]int)
+	)
+	for _, i := range surviving {
+		group := before[i].group
+		if counts[group] == nil {
+			counts[group] = make(map[int]int)
+			order = append(order, group)
+		}
+		counts[group][after[pairs[i]].group]++
+	}
+
+	groups := make(map[int]int, len(order))
+	taken := make(map[int]bool, len(order))
+	for _, group := range order {
+		best, bestCount := -1, 0
+		for candidate, count := range counts[group] {
+			if taken[candidate] {
+				continue
+			}
+			if count > bestCount || (count == bestCount && candidate < best) {
+				best, bestCount = candidate, count
+			}
+		}
+		if best >= 0 {
+			taken[best] = true
+		}
+		groups[group] = best
+	}
+	return groups
+}
+
+func layoutImports(fset *token.FileSet, specs []*ast.ImportSpec) []importLayout {
+	layout := make([]importLayout, 0, len(specs))
+
+	var (
+		group    int
+		prevLine int
+	)
+	for idx, spec := range specs {
+		line := fset.Position(spec.Pos()).Line
+		if spec.Doc != nil {
+			line = fset.Position(spec.Doc.Pos()).Line
+		}
+		if idx > 0 && line > prevLine+1 {
+			group++
+		}
+		prevLine = fset.Position(spec.End()).Line
+
+		var name string
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		layout = append(layout, importLayout{
+			importKey: importKey{name: name, path: strings.Trim(spec.Path.Value, `"`)},
+			group:     group,
+		})
+	}
+
+	return layout
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestComputeImportChanges(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		original string
+		fixed    string
+		rewrites []PathRewrite
+		want     ImportChanges
+	}{
+		"unchanged imports": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+		},
+		"reordered and regrouped imports": {
+			original: "package p\n\nimport (\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n\t\"strings\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+			want: ImportChanges{
+				Moved: []string{"fmt", "github.com/pkg/errors", "strings"},
+			},
+		},
+		"removed import does not move the rest": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"strings\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n",
+			want: ImportChanges{
+				Removed: []string{"os"},
+			},
+		},
+		"added import does not move the rest": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n\t\"strings\"\n)\n",
+			want: ImportChanges{
+				Added: []string{"errors"},
+			},
+		},
+		"rewritten import": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/pkg/errors\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n)\n",
+			rewrites: []PathRewrite{{From: "github.com/pkg/errors", To: "errors"}},
+			want: ImportChanges{
+				Rewritten: []PathRewrite{{From: "github.com/pkg/errors", To: "errors"}},
+			},
+		},
+		"removed group does not move the following groups": {
+			original: "package p\n\nimport (\n\t\"os\"\n\n\t\"github.com/pkg/errors\"\n\n\t\"example.com/project/pkg\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"github.com/pkg/errors\"\n\n\t\"example.com/project/pkg\"\n)\n",
+			want: ImportChanges{
+				Removed: []string{"os"},
+			},
+		},
+		"import moved into another group": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/pkg/errors\"\n\t\"golang.org/x/sync/errgroup\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"fmt\"\n\t\"github.com/pkg/errors\"\n\n\t\"golang.org/x/sync/errgroup\"\n)\n",
+			want: ImportChanges{
+				Moved: []string{"github.com/pkg/errors"},
+			},
+		},
+		"groups merged": {
+			original: "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"strings\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"strings\"\n)\n",
+			want: ImportChanges{
+				Moved: []string{"strings"},
+			},
+		},
+		"path imported blank and named": {
+			original: "package p\n\nimport (\n\t_ \"github.com/lib/pq\"\n\tpq \"github.com/lib/pq\"\n)\n",
+			fixed:    "package p\n\nimport (\n\tpq \"github.com/lib/pq\"\n\n\t_ \"github.com/lib/pq\"\n)\n",
+			want: ImportChanges{
+				Moved: []string{"github.com/lib/pq", "github.com/lib/pq"},
+			},
+		},
+		"named import of a blank imported path removed": {
+			original: "package p\n\nimport (\n\tpq \"github.com/lib/pq\"\n\n\t_ \"github.com/lib/pq\"\n)\n",
+			fixed:    "package p\n\nimport (\n\t_ \"github.com/lib/pq\"\n)\n",
+			want: ImportChanges{
+				Removed: []string{"github.com/lib/pq"},
+			},
+		},
+		"alias added": {
+			original: "package p\n\nimport (\n\t\"github.com/go-pg/pg/v9\"\n)\n",
+			fixed:    "package p\n\nimport (\n\tpg \"github.com/go-pg/pg/v9\"\n)\n",
+			want: ImportChanges{
+				Aliased: []AliasChange{{Path: "github.com/go-pg/pg/v9", To: "pg"}},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := computeImportChanges([]byte(tt.original), []byte(tt.fixed), tt.rewrites)
+			if err != nil {
+				t.Fatalf("computeImportChanges returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("import changes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSourceFile_FixResult_ReportsAliasPolicy(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "alias.go")
+	files := map[string]string{
+		"go.mod":   "module example.com/alias\n\ngo 1.22\n",
+		"alias.go": "package alias\n\nimport (\n\terrors \"errors\"\n\t\"encoding/json\"\n)\n\nvar _, _ = errors.New, json.Valid\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	result, err := NewSourceFile("example.com/alias", filePath).FixResult(
+		WithRemovingRedundantAliases,
+		WithRequiredAliases([]ImportAlias{{Name: "stdjson", Path: "encoding/json"}}),
+	)
+	if err != nil {
+		t.Fatalf("FixResult returned error: %v", err)
+	}
+	want := ImportChanges{
+		Moved: []string{"encoding/json", "errors"},
+		Aliased: []AliasChange{
+			{Path: "encoding/json", To: "stdjson"},
+			{Path: "errors", From: "errors"},
+		},
+	}
+	if diff := gocmp.Diff(want, result.ImportChanges); diff != "" {
+		t.Errorf("import changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSourceDir_WithReport(t *testing.T) {
+	t.Parallel()
+
+	project := "github.com/example/project"
+	tmpDir := t.TempDir()
+	unformattedPath := filepath.Join(tmpDir, "unformatted.go")
+	invalidPath := filepath.Join(tmpDir, "invalid.go")
+	unformatted := []byte("package testdata\n\nimport (\n\t\"github.com/pkg/errors\"\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(errors.New(\"report\"))\n}\n")
+	if err := os.WriteFile(unformattedPath, unformatted, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(invalidPath, []byte("package testdata\n\nfunc broken(\n"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		reports = map[string]FileReport{}
+	)
+	_, err := NewSourceDir(project, tmpDir, true, "").
+		WithReport(func(report FileReport) {
+			mu.Lock()
+			reports[report.Path] = report
+			mu.Unlock()
+		}).
+		Find()
+	if err == nil {
+		t.Fatalf("expected Find to fail on invalid fixture")
+	}
+
+	if got := reports[invalidPath]; got.Error == "" || got.Changed {
+		t.Fatalf("expected invalid file report to carry an error, got %+v", got)
+	}
+	want := FileReport{
+		Path:    unformattedPath,
+		Changed: true,
+		ImportChanges: ImportChanges{
+			Moved: []string{"fmt", "github.com/pkg/errors"},
+		},
+	}
+	if diff := gocmp.Diff(want, reports[unformattedPath]); diff != "" {
+		t.Fatalf("report mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,30 @@
+package engine
+
+// SkipReason explains why a file was left untouched without being revised.
+type SkipReason string
+
+const (
+	// SkipReasonGenerated is set for generated files when WithSkipGeneratedFile is used.
+	SkipReasonGenerated SkipReason = "generated"
+	// SkipReasonCgoOnly is set for files whose only import is "C".
+	SkipReasonCgoOnly SkipReason = "cgo-only"
+	// SkipReasonCacheHit is set when the cache proved the file is already formatted.
+	SkipReasonCacheHit SkipReason = "cache-hit"
+)
+
+// Result is the outcome of SourceFile.FixResult.
+type Result struct {
+	// Content is the revised content. It equals Original when nothing changed.
+	Content []byte
+	// Original is the content before revising.
+	Original []byte
+	// Changed reports whether Content differs from Original.
+	Changed bool
+	// SkipReason is set when the file was not revised at all.
+	SkipReason SkipReason
+	// ImportChanges lists the import-level edits; it is empty unless Changed.
+	ImportChanges
+	// Violations lists the imports that break the import rules, whether or
+	// not they were replaced.
+	Violations []ImportViolation
+}
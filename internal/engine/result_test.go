@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestSourceFileFixResult(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		source      string
+		options     []SourceFileOption
+		wantChanged bool
+		wantSkip    SkipReason
+		wantChanges ImportChanges
+	}{
+		"merged import declarations": {
+			source:      "package p\n\nimport \"os\"\n\nimport \"fmt\"\n\nvar _ = fmt.Println\nvar _ = os.Exit\n",
+			wantChanged: true,
+			wantChanges: ImportChanges{
+				Moved:       []string{"fmt", "os"},
+				MergedDecls: 1,
+			},
+		},
+		"cgo only file is skipped": {
+			source:   "package p\n\n/*\n#include <stdlib.h>\n*/\nimport \"C\"\n",
+			wantSkip: SkipReasonCgoOnly,
+		},
+		"generated file is skipped": {
+			source:   "// Code generated by test DO NOT EDIT.\npackage p\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\nvar _ = fmt.Println\nvar _ = os.Exit\n",
+			options:  []SourceFileOption{WithSkipGeneratedFile},
+			wantSkip: SkipReasonGenerated,
+		},
+		"formatted file is unchanged without skip reason": {
+			source: "package p\n\nimport \"fmt\"\n\nvar _ = fmt.Println\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filePath := filepath.Join(t.TempDir(), "p.go")
+			if err := os.WriteFile(filePath, []byte(tt.source), 0o644); err != nil {
+				t.Fatalf("failed to write fixture: %v", err)
+			}
+
+			result, err := NewSourceFile("example.com/p", filePath).FixResult(tt.options...)
+			if err != nil {
+				t.Fatalf("FixResult returned error: %v", err)
+			}
+			if result.Changed != tt.wantChanged {
+				t.Errorf("Changed = %t, want %t", result.Changed, tt.wantChanged)
+			}
+			if result.SkipReason != tt.wantSkip {
+				t.Errorf("SkipReason = %q, want %q", result.SkipReason, tt.wantSkip)
+			}
+			if diff := gocmp.Diff(tt.wantChanges, result.ImportChanges); diff != "" {
+				t.Errorf("ImportChanges mismatch (-want +got):\n%s", diff)
+			}
+			if got := string(result.Original); got != tt.source {
+				t.Errorf("Original mismatch\nwant:\n%s\n got:\n%s", tt.source, got)
+			}
+
+			content, original, changed, err := NewSourceFile("example.com/p", filePath).Fix(tt.options...)
+			if err != nil {
+				t.Fatalf("Fix returned error: %v", err)
+			}
+			if string(content) != string(result.Content) || string(original) != string(result.Original) || changed != result.Changed {
+				t.Errorf("Fix disagrees with FixResult")
+			}
+		})
+	}
+}
+
+func TestSourceFileFixResultKeepsOriginalOnError(t *testing.T) {
+	t.Parallel()
+
+	filePath := filepath.Join(t.TempDir(), "p.go")
+	source := []byte("package p\n\nfunc broken(\n")
+	if err := os.WriteFile(filePath, source, 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	result, err := NewSourceFile("example.com/p", filePath).FixResult()
+	if err == nil {
+		t.Fatalf("expected FixResult to fail on invalid source")
+	}
+	if result == nil || string(result.Original) != string(source) {
+		t.Fatalf("expected Result to carry the original content on error, got %+v", result)
+	}
+}
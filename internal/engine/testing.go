@@ -10,4 +10,5 @@ import (
 func clearTestCaches() {
 	pkgdeps.ClearCache()
 	modulepath.ClearNameCache()
+	modulepath.ClearWorkspaceCache()
 }
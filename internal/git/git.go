@@ -0,0 +1,226 @@
+// Package git lists files known to git by running the local git binary.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedFilter selects added, copied, modified, renamed and type-changed
+// entries. Deleted files have nothing left to format, and renamed files are
+// reported under their new name.
+const changedFilter = "--diff-filter=ACMRT"
+
+// ChangedFiles returns the absolute paths of the files in the work tree
+// containing dir that differ from rev, plus untracked files that are not
+// ignored. Files that no longer exist on disk are omitted.
+func ChangedFiles(ctx context.Context, dir, rev string) ([]string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid git revision %q", rev)
+	}
+
+	root, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := run(ctx, root, "diff", "--name-only", "-z", "--find-renames", changedFilter, rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := run(ctx, root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	return existingFiles(root, append(splitNUL(changed), splitNUL(untracked)...)), nil
+}
+
+// IndexEntry is a regular file staged in the git index.
+type IndexEntry struct {
+	// Root is the work tree the entry belongs to.
+	Root string
+	// Name is the slash-separated path relative to Root, as git reports it.
+	Name string
+	// Path is the absolute path of the file in the work tree.
+	Path string
+	// Mode is the octal file mode, "100644" or "100755".
+	Mode string
+	// Object is the object name of the staged blob.
+	Object string
+}
+
+// StagedEntries returns the regular files staged in the index of the
+// repository containing dir, whether or not they still exist in the work
+// tree. Symlinks, submodules and unmerged entries are omitted.
+func StagedEntries(ctx context.Context, dir string) ([]IndexEntry, error) {
+	root, err := TopLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := run(ctx, root, "diff", "--cached", "--name-only", "-z", "--find-renames", changedFilter, "--")
+	if err != nil {
+		return nil, err
+	}
+	names := splitNUL(staged)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+
+	index, err := run(ctx, root, "ls-files", "--stage", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []IndexEntry
+	for _, record := range splitNUL(index) {
+		// <mode> SP <object> SP <stage> TAB <name>
+		info, name, ok := strings.Cut(record, "\t")
+		if !ok {
+			continue
+		}
+		if _, ok := wanted[name]; !ok {
+			continue
+		}
+		fields := strings.Fields(info)
+		if len(fields) != 3 || fields[2] != "0" {
+			continue
+		}
+		if mode := fields[0]; mode != "100644" && mode != "100755" {
+			continue
+		}
+		entries = append(entries, IndexEntry{
+			Root:   root,
+			Name:   name,
+			Path:   filepath.Join(root, filepath.FromSlash(name)),
+			Mode:   fields[0],
+			Object: fields[1],
+		})
+	}
+	return entries, nil
+}
+
+// UnstagedNames returns the names, relative to root, of the files whose work
+// tree content differs from the index.
+func UnstagedNames(ctx context.Context, root string) (map[string]struct{}, error) {
+	out, err := run(ctx, root, "diff", "--name-only", "-z", "--")
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{})
+	for _, name := range splitNUL(out) {
+		names[name] = struct{}{}
+	}
+	return names, nil
+}
+
+// ReadBlob returns the content of the blob object.
+func ReadBlob(ctx context.Context, root, object string) ([]byte, error) {
+	return run(ctx, root, "cat-file", "blob", object)
+}
+
+// WriteBlob stores data as a blob object, as is, and returns its name.
+func WriteBlob(ctx context.Context, root string, data []byte) (string, error) {
+	out, err := runWithInput(ctx, root, data, "hash-object", "-w", "--no-filters", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// UpdateIndex points the index entries of root at their Mode and Object in a
+// single git update-index call.
+func UpdateIndex(ctx context.Context, root string, entries []IndexEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var info bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&info, "%s %s\t%s\x00", entry.Mode, entry.Object, entry.Name)
+	}
+	_, err := runWithInput(ctx, root, info.Bytes(), "update-index", "-z", "--index-info")
+	return err
+}
+
+// TopLevel returns the absolute path of the work tree containing dir.
+func TopLevel(ctx context.Context, dir string) (string, error) {
+	out, err := run(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out))), nil
+}
+
+func run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	return runWithInput(ctx, dir, nil, args...)
+}
+
+func runWithInput(ctx context.Context, dir string, stdin []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+func splitNUL(out []byte) []string {
+	var names []string
+	for name := range strings.SplitSeq(string(out), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func existingFiles(root string, names []string) []string {
+	seen := make(map[string]struct{}, len(names))
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				// Unreadable entries are left to the caller, which reports
+				// the error when processing the file.
+				files = append(files, path)
+			}
+			continue
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
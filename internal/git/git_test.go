@@ -0,0 +1,184 @@
+package git
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+// newTestRepo creates a git repository with an initial commit containing
+// files and returns its resolved root.
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is not available")
+	}
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	gitCmd(t, root, "init", "-q")
+	for name, content := range files {
+		testutil.WriteFile(t, filepath.Join(root, name), content)
+	}
+	gitCmd(t, root, "add", "-A")
+	gitCmd(t, root, "commit", "-q", "-m", "initial")
+	return root
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	root := newTestRepo(t, map[string]string{
+		"kept.go":     "package a\n",
+		"modified.go": "package a\n",
+		"deleted.go":  "package a\n",
+		"old.go":      "package a\n\nfunc renamed() {}\n",
+	})
+
+	testutil.WriteFile(t, filepath.Join(root, "modified.go"), "package a\n\nfunc f() {}\n")
+	testutil.WriteFile(t, filepath.Join(root, "sub", "untracked.go"), "package sub\n")
+	gitCmd(t, root, "rm", "-q", "deleted.go")
+	gitCmd(t, root, "mv", "old.go", "new.go")
+
+	got, err := ChangedFiles(t.Context(), root, "HEAD")
+	if err != nil {
+		t.Fatalf("ChangedFiles returned error: %v", err)
+	}
+	want := []string{
+		filepath.Join(root, "modified.go"),
+		filepath.Join(root, "new.go"),
+		filepath.Join(root, "sub", "untracked.go"),
+	}
+	if diff := gocmp.Diff(want, slices.Sorted(slices.Values(got))); diff != "" {
+		t.Fatalf("ChangedFiles mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStagedEntries(t *testing.T) {
+	root := newTestRepo(t, map[string]string{
+		"staged.go":   "package a\n",
+		"unstaged.go": "package a\n",
+		"removed.go":  "package a\n",
+	})
+
+	testutil.WriteFile(t, filepath.Join(root, "staged.go"), "package a\n\nfunc f() {}\n")
+	testutil.WriteFile(t, filepath.Join(root, "unstaged.go"), "package a\n\nfunc f() {}\n")
+	testutil.WriteFile(t, filepath.Join(root, "sub", "added.go"), "package a\n")
+	gitCmd(t, root, "add", "staged.go", "sub/added.go")
+	gitCmd(t, root, "rm", "-q", "removed.go")
+	// Work tree edits after staging must not leak into the staged entry.
+	testutil.WriteFile(t, filepath.Join(root, "staged.go"), "package a\n\nfunc g() {}\n")
+
+	got, err := StagedEntries(t.Context(), root)
+	if err != nil {
+		t.Fatalf("StagedEntries returned error: %v", err)
+	}
+
+	var names []string
+	for _, entry := range got {
+		names = append(names, entry.Name)
+		if entry.Root != root || entry.Path != filepath.Join(root, filepath.FromSlash(entry.Name)) || entry.Mode != "100644" {
+			t.Fatalf("unexpected entry: %+v", entry)
+		}
+	}
+	if diff := gocmp.Diff([]string{"staged.go", "sub/added.go"}, slices.Sorted(slices.Values(names))); diff != "" {
+		t.Fatalf("StagedEntries mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, entry := range got {
+		if entry.Name != "staged.go" {
+			continue
+		}
+		blob, err := ReadBlob(t.Context(), root, entry.Object)
+		if err != nil {
+			t.Fatalf("ReadBlob returned error: %v", err)
+		}
+		if string(blob) != "package a\n\nfunc f() {}\n" {
+			t.Fatalf("ReadBlob returned work tree content: %q", blob)
+		}
+	}
+
+	unstaged, err := UnstagedNames(t.Context(), root)
+	if err != nil {
+		t.Fatalf("UnstagedNames returned error: %v", err)
+	}
+	if diff := gocmp.Diff([]string{"staged.go", "unstaged.go"}, slices.Sorted(maps.Keys(unstaged))); diff != "" {
+		t.Fatalf("UnstagedNames mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteBlobAndUpdateIndex(t *testing.T) {
+	root := newTestRepo(t, map[string]string{
+		"a.go": "package a\n",
+	})
+
+	object, err := WriteBlob(t.Context(), root, []byte("package a\n\nfunc f() {}\n"))
+	if err != nil {
+		t.Fatalf("WriteBlob returned error: %v", err)
+	}
+	if err := UpdateIndex(t.Context(), root, []IndexEntry{{Name: "a.go", Mode: "100644", Object: object}}); err != nil {
+		t.Fatalf("UpdateIndex returned error: %v", err)
+	}
+
+	entries, err := StagedEntries(t.Context(), root)
+	if err != nil {
+		t.Fatalf("StagedEntries returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Object != object {
+		t.Fatalf("expected a.go to be staged as %s, got %+v", object, entries)
+	}
+	// The work tree is untouched, so it now differs from the index.
+	unstaged, err := UnstagedNames(t.Context(), root)
+	if err != nil {
+		t.Fatalf("UnstagedNames returned error: %v", err)
+	}
+	if _, ok := unstaged["a.go"]; !ok {
+		t.Fatalf("expected a.go to differ from the index, got %v", unstaged)
+	}
+}
+
+func TestChangedFilesRejectsOptionLikeRevision(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ChangedFiles(t.Context(), t.TempDir(), "--output=/tmp/x"); err == nil {
+		t.Fatalf("expected option-like revision to be rejected")
+	}
+}
+
+func TestTopLevelOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is not available")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+
+	dir := filepath.Join(t.TempDir(), "plain")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if _, err := TopLevel(t.Context(), dir); err == nil {
+		t.Fatalf("expected error outside of a git repository")
+	}
+}
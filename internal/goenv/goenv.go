@@ -0,0 +1,86 @@
+// Package goenv queries the Go toolchain that go commands run by the tool use,
+// the one found in PATH.
+package goenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Toolchain describes the active Go toolchain.
+type Toolchain struct {
+	GoRoot  string
+	Version string
+}
+
+var active struct {
+	mu        sync.Mutex
+	toolchain *Toolchain
+}
+
+// Active returns the active Go toolchain as 'go env' reports it. The first
+// successful result is shared by later calls, while failures, e.g. because
+// ctx was canceled, are not remembered.
+func Active(ctx context.Context) (Toolchain, error) {
+	active.mu.Lock()
+	defer active.mu.Unlock()
+
+	if active.toolchain != nil {
+		return *active.toolchain, nil
+	}
+
+	out, err := exec.CommandContext(ctx, "go", "env", "GOROOT", "GOVERSION").Output()
+	if err != nil {
+		return Toolchain{}, fmt.Errorf("failed to query the go toolchain: %w", err)
+	}
+
+	goRoot, version, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	tc := Toolchain{GoRoot: strings.TrimSpace(goRoot), Version: strings.TrimSpace(version)}
+	if tc.GoRoot == "" || tc.Version == "" {
+		return Toolchain{}, fmt.Errorf("unexpected output of go env: %q", out)
+	}
+	active.toolchain = &tc
+	return tc, nil
+}
+
+// envVars are the environment variables that change what go commands report
+// for the same sources.
+var envVars = []string{
+	"CGO_ENABLED",
+	"GO111MODULE",
+	"GOARCH",
+	"GOENV",
+	"GOEXPERIMENT",
+	"GOFLAGS",
+	"GOINSECURE",
+	"GOMODCACHE",
+	"GONOPROXY",
+	"GONOSUMDB",
+	"GOOS",
+	"GOPATH",
+	"GOPRIVATE",
+	"GOPROXY",
+	"GOROOT",
+	"GOSUMDB",
+	"GOTOOLCHAIN",
+	"GOWORK",
+}
+
+// Environ returns the environment of the process that go commands depend on:
+// the go command found in PATH and the Go environment variables that are set.
+func Environ() []string {
+	var env []string
+	if path, err := exec.LookPath("go"); err == nil {
+		env = append(env, "go="+path)
+	}
+	for _, name := range envVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
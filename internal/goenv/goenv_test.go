@@ -0,0 +1,47 @@
+package goenv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestActive(t *testing.T) {
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := Active(canceled); err == nil {
+		t.Fatalf("expected Active to fail with a canceled context")
+	}
+
+	tc, err := Active(t.Context())
+	if err != nil {
+		t.Fatalf("Active returned error: %v", err)
+	}
+	if !strings.HasPrefix(tc.Version, "go") {
+		t.Errorf("unexpected version %q", tc.Version)
+	}
+	if _, err := os.Stat(filepath.Join(tc.GoRoot, "src")); err != nil {
+		t.Errorf("GOROOT %q has no src: %v", tc.GoRoot, err)
+	}
+
+	// The result is shared once it succeeded.
+	if got, err := Active(canceled); err != nil || got != tc {
+		t.Fatalf("Active() = %+v, %v, want %+v", got, err, tc)
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("GOOS", "plan9")
+	t.Setenv("GOFOO", "bar")
+
+	env := Environ()
+	if !slices.Contains(env, "GOOS=plan9") {
+		t.Errorf("Environ() = %q, want GOOS=plan9", env)
+	}
+	if slices.ContainsFunc(env, func(kv string) bool { return strings.HasPrefix(kv, "GOFOO=") }) {
+		t.Errorf("Environ() = %q, must only hold Go environment variables", env)
+	}
+}
@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// computeEdits returns a single edit that turns before into after, spanning
+// only the bytes between their common prefix and suffix. It returns no edits
+// when the contents are equal.
+func computeEdits(before, after []byte) []TextEdit {
+	if bytes.Equal(before, after) {
+		return []TextEdit{}
+	}
+
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	for prefix > 0 && (!isRuneBoundary(before, prefix) || !isRuneBoundary(after, prefix)) {
+		prefix--
+	}
+
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && (!isRuneBoundary(before, len(before)-suffix) || !isRuneBoundary(after, len(after)-suffix)) {
+		suffix--
+	}
+
+	return []TextEdit{{
+		Range: Range{
+			Start: offsetToPosition(before, prefix),
+			End:   offsetToPosition(before, len(before)-suffix),
+		},
+		NewText: string(after[prefix : len(after)-suffix]),
+	}}
+}
+
+func isRuneBoundary(content []byte, offset int) bool {
+	return offset == len(content) || utf8.RuneStart(content[offset])
+}
+
+// offsetToPosition converts a byte offset into an LSP position, counting
+// characters in UTF-16 code units.
+func offsetToPosition(content []byte, offset int) Position {
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+	line := bytes.Count(content[:lineStart], []byte{'\n'})
+
+	var character int
+	for rest := content[lineStart:offset]; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		if r >= 0x10000 {
+			character += 2
+		} else {
+			character++
+		}
+		rest = rest[size:]
+	}
+
+	return Position{Line: uint32(line), Character: uint32(character)}
+}
+
+// positionToOffset converts an LSP position into a byte offset, clamping
+// positions beyond the end of a line or of the content.
+func positionToOffset(content []byte, pos Position) int {
+	offset := 0
+	for range pos.Line {
+		idx := bytes.IndexByte(content[offset:], '\n')
+		if idx < 0 {
+			return len(content)
+		}
+		offset += idx + 1
+	}
+
+	for units := uint32(0); units < pos.Character && offset < len(content) && content[offset] != '\n'; {
+		r, size := utf8.DecodeRune(content[offset:])
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestComputeEdits(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		before string
+		after  string
+		want   []TextEdit
+	}{
+		"equal": {
+			before: "package a\n",
+			after:  "package a\n",
+			want:   []TextEdit{},
+		},
+		"swap lines": {
+			before: "import (\n\t\"strings\"\n\t\"fmt\"\n)\n",
+			after:  "import (\n\t\"fmt\"\n\t\"strings\"\n)\n",
+			want: []TextEdit{{
+				Range:   Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 2, Character: 5}},
+				NewText: "fmt\"\n\t\"strings",
+			}},
+		},
+		"characters are counted in UTF-16 code units": {
+			before: "// 😀é\nb\n",
+			after:  "// 😀é\nc\n",
+			want: []TextEdit{{
+				Range:   Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 1, Character: 1}},
+				NewText: "c",
+			}},
+		},
+		"edits never split a rune": {
+			before: "é",
+			after:  "è",
+			want: []TextEdit{{
+				Range:   Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 0, Character: 1}},
+				NewText: "è",
+			}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := computeEdits([]byte(tt.before), []byte(tt.after))
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("computeEdits mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPositionToOffsetRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("a😀b\n\nxyz")
+	for offset := range len(content) + 1 {
+		if !isRuneBoundary(content, offset) {
+			continue
+		}
+		pos := offsetToPosition(content, offset)
+		if got := positionToOffset(content, pos); got != offset {
+			t.Fatalf("positionToOffset(offsetToPosition(%d)) = %d (position %+v)", offset, got, pos)
+		}
+	}
+
+	if got := positionToOffset(content, Position{Line: 0, Character: 100}); got != len("a😀b") {
+		t.Fatalf("positions beyond the line end must clamp to it, got %d", got)
+	}
+	if got := positionToOffset(content, Position{Line: 10}); got != len(content) {
+		t.Fatalf("positions beyond the content must clamp to its end, got %d", got)
+	}
+}
@@ -0,0 +1,299 @@
+// Package lsp implements a Language Server Protocol server over a stream,
+// offering import formatting as document formatting and as the
+// source.organizeImports code action.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+const serverName = "goimports-rereviser"
+
+// ErrExitWithoutShutdown is returned by Serve when the client sent exit
+// without a preceding shutdown request.
+var ErrExitWithoutShutdown = errors.New("exit notification received before shutdown")
+
+// FixFunc revises the imports of src as if it were the content of filename,
+// without touching the filesystem.
+type FixFunc func(ctx context.Context, filename string, src []byte) ([]byte, error)
+
+// Server serves formatting requests for the open documents of one client.
+type Server struct {
+	fix     FixFunc
+	version string
+
+	mu          sync.Mutex
+	documents   map[string][]byte
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer creates a server that formats documents with fix. version is
+// reported to the client in the initialize response.
+func NewServer(fix FixFunc, version string) *Server {
+	return &Server{
+		fix:       fix,
+		version:   version,
+		documents: make(map[string][]byte),
+	}
+}
+
+// Serve reads requests from r and writes responses to w until the client
+// sends exit, r is exhausted or ctx is done. Requests are handled in order.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := writeMessage(w, response{JSONRPC: "2.0", Error: &responseError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.isShutdown() {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, respErr := s.handle(ctx, &req)
+		if req.isNotification() {
+			continue
+		}
+
+		resp := response{JSONRPC: "2.0", ID: req.ID, Error: respErr}
+		if respErr == nil {
+			resp.Result, err = marshalResult(result)
+			if err != nil {
+				resp.Error = &responseError{Code: codeRequestFailed, Message: err.Error()}
+			}
+		}
+		if err := writeMessage(w, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req *request) (any, *responseError) {
+	s.mu.Lock()
+	initialized, shutdown := s.initialized, s.shutdown
+	s.mu.Unlock()
+
+	// After shutdown only exit, which Serve handles, is accepted.
+	if shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.mu.Lock()
+		s.initialized = true
+		s.mu.Unlock()
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:           textDocumentSyncKindFull,
+				DocumentFormattingProvider: true,
+				CodeActionProvider: codeActionOptions{
+					CodeActionKinds: []string{codeActionKindOrganizeImports},
+				},
+			},
+			ServerInfo: serverInfo{Name: serverName, Version: s.version},
+		}, nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+	}
+
+	if !initialized {
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server is not initialized"}
+	}
+
+	switch req.Method {
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.setDocument(params.TextDocument.URI, []byte(params.TextDocument.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.changeDocument(params.TextDocument.URI, params.ContentChanges)
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.mu.Lock()
+		delete(s.documents, params.TextDocument.URI)
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		edits, err := s.format(ctx, params.TextDocument.URI)
+		if err != nil {
+			return nil, &responseError{Code: codeRequestFailed, Message: err.Error()}
+		}
+		return edits, nil
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		actions, err := s.codeActions(ctx, params)
+		if err != nil {
+			return nil, &responseError{Code: codeRequestFailed, Message: err.Error()}
+		}
+		return actions, nil
+	}
+
+	if req.isNotification() {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+func (s *Server) setDocument(uri string, content []byte) {
+	s.mu.Lock()
+	s.documents[uri] = content
+	s.mu.Unlock()
+}
+
+func (s *Server) changeDocument(uri string, changes []textDocumentContentChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content := s.documents[uri]
+	for _, change := range changes {
+		if change.Range == nil {
+			content = []byte(change.Text)
+			continue
+		}
+		start := positionToOffset(content, change.Range.Start)
+		end := max(start, positionToOffset(content, change.Range.End))
+		updated := make([]byte, 0, len(content)-(end-start)+len(change.Text))
+		updated = append(updated, content[:start]...)
+		updated = append(updated, change.Text...)
+		updated = append(updated, content[end:]...)
+		content = updated
+	}
+	s.documents[uri] = content
+}
+
+// format returns the edits that revise the imports of the open document.
+func (s *Server) format(ctx context.Context, uri string) ([]TextEdit, error) {
+	s.mu.Lock()
+	content, ok := s.documents[uri]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("document is not open: %s", uri)
+	}
+
+	filename, err := uriToPath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	fixed, err := s.fix(ctx, filename, content)
+	if err != nil {
+		return nil, err
+	}
+	return computeEdits(content, fixed), nil
+}
+
+func (s *Server) codeActions(ctx context.Context, params codeActionParams) ([]codeAction, error) {
+	if !wantsOrganizeImports(params.Context.Only) {
+		return []codeAction{}, nil
+	}
+
+	edits, err := s.format(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return []codeAction{}, nil
+	}
+
+	return []codeAction{{
+		Title: "Organize Imports",
+		Kind:  codeActionKindOrganizeImports,
+		Edit: workspaceEdit{
+			Changes: map[string][]TextEdit{params.TextDocument.URI: edits},
+		},
+	}}, nil
+}
+
+// wantsOrganizeImports reports whether a code action request filtered by only
+// accepts source.organizeImports actions.
+func wantsOrganizeImports(only []string) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, kind := range only {
+		if kind == codeActionKindSource || kind == codeActionKindOrganizeImports {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// uriToPath converts a file URI into a local path.
+func uriToPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI scheme %q", parsed.Scheme)
+	}
+
+	path := parsed.Path
+	// file:///C:/dir/file.go carries a leading slash before the volume.
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
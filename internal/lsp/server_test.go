@@ -0,0 +1,245 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+// testClient drives a Server over in-memory pipes.
+type testClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+func newTestClient(t *testing.T, fix FixFunc) *testClient {
+	t.Helper()
+
+	clientToServer, serverIn := io.Pipe()
+	serverOut, serverToClient := io.Pipe()
+	c := &testClient{
+		t:    t,
+		in:   serverIn,
+		out:  bufio.NewReader(serverOut),
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := NewServer(fix, "test").Serve(context.Background(), clientToServer, serverToClient)
+		_ = serverToClient.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() { _ = serverIn.Close() })
+	return c
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+
+	if err := writeMessage(c.in, map[string]any{"jsonrpc": "2.0", "method": method, "params": params}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+}
+
+func (c *testClient) call(method string, params, result any) *responseError {
+	c.t.Helper()
+
+	c.nextID++
+	if err := writeMessage(c.in, map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params}); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+
+	body, err := readMessage(c.out)
+	if err != nil {
+		c.t.Fatalf("failed to read %s response: %v", method, err)
+	}
+	var resp struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		c.t.Fatalf("failed to decode %s response %s: %v", method, body, err)
+	}
+	if resp.ID != c.nextID {
+		c.t.Fatalf("response id = %d, want %d", resp.ID, c.nextID)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("failed to decode %s result %s: %v", method, resp.Result, err)
+		}
+	}
+	return nil
+}
+
+// sortLines is a FixFunc stand-in that sorts the lines of src.
+func sortLines(_ context.Context, filename string, src []byte) ([]byte, error) {
+	if !filepath.IsAbs(filename) {
+		return nil, errors.New("filename is not absolute: " + filename)
+	}
+	if bytes.Contains(src, []byte("invalid")) {
+		return nil, errors.New("invalid source")
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	for i := range lines {
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j] != "" && lines[j] < lines[i] {
+				lines[i], lines[j] = lines[j], lines[i]
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func TestServerFormattingAndCodeAction(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t, sortLines)
+	uri := "file:///tmp/project/main.go"
+
+	if respErr := c.call("textDocument/formatting", map[string]any{"textDocument": map[string]string{"uri": uri}}, nil); respErr == nil || respErr.Code != codeServerNotInitialized {
+		t.Fatalf("expected requests before initialize to fail, got %v", respErr)
+	}
+
+	var init initializeResult
+	if respErr := c.call("initialize", map[string]any{}, &init); respErr != nil {
+		t.Fatalf("initialize failed: %v", respErr)
+	}
+	if !init.Capabilities.DocumentFormattingProvider || init.Capabilities.TextDocumentSync != textDocumentSyncKindFull {
+		t.Fatalf("unexpected capabilities: %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": "b\na\n"},
+	})
+	// Unsaved edits are what gets formatted.
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{
+			"range": Range{Start: Position{Line: 0}, End: Position{Line: 0, Character: 1}},
+			"text":  "c",
+		}},
+	})
+
+	var edits []TextEdit
+	if respErr := c.call("textDocument/formatting", documentFormattingParams{TextDocument: textDocumentIdentifier{URI: uri}}, &edits); respErr != nil {
+		t.Fatalf("formatting failed: %v", respErr)
+	}
+	wantEdits := []TextEdit{{
+		Range:   Range{Start: Position{Line: 0}, End: Position{Line: 1, Character: 1}},
+		NewText: "a\nc",
+	}}
+	if diff := gocmp.Diff(wantEdits, edits); diff != "" {
+		t.Fatalf("formatting edits mismatch (-want +got):\n%s", diff)
+	}
+
+	var actions []codeAction
+	params := codeActionParams{TextDocument: textDocumentIdentifier{URI: uri}, Context: codeActionContext{Only: []string{codeActionKindOrganizeImports}}}
+	if respErr := c.call("textDocument/codeAction", params, &actions); respErr != nil {
+		t.Fatalf("codeAction failed: %v", respErr)
+	}
+	wantActions := []codeAction{{
+		Title: "Organize Imports",
+		Kind:  codeActionKindOrganizeImports,
+		Edit:  workspaceEdit{Changes: map[string][]TextEdit{uri: wantEdits}},
+	}}
+	if diff := gocmp.Diff(wantActions, actions); diff != "" {
+		t.Fatalf("code actions mismatch (-want +got):\n%s", diff)
+	}
+
+	params.Context.Only = []string{"quickfix"}
+	if respErr := c.call("textDocument/codeAction", params, &actions); respErr != nil {
+		t.Fatalf("codeAction failed: %v", respErr)
+	}
+	if len(actions) != 0 {
+		t.Fatalf("expected no actions for other kinds, got %+v", actions)
+	}
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 3},
+		"contentChanges": []map[string]any{{"text": "invalid\n"}},
+	})
+	if respErr := c.call("textDocument/formatting", documentFormattingParams{TextDocument: textDocumentIdentifier{URI: uri}}, nil); respErr == nil || respErr.Code != codeRequestFailed {
+		t.Fatalf("expected formatting errors to be reported, got %v", respErr)
+	}
+
+	if respErr := c.call("textDocument/hover", map[string]any{}, nil); respErr == nil || respErr.Code != codeMethodNotFound {
+		t.Fatalf("expected unknown methods to fail, got %v", respErr)
+	}
+
+	if respErr := c.call("shutdown", nil, nil); respErr != nil {
+		t.Fatalf("shutdown failed: %v", respErr)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+}
+
+func TestServerRejectsRequestsAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t, sortLines)
+	if respErr := c.call("initialize", map[string]any{}, nil); respErr != nil {
+		t.Fatalf("initialize failed: %v", respErr)
+	}
+	if respErr := c.call("shutdown", nil, nil); respErr != nil {
+		t.Fatalf("shutdown failed: %v", respErr)
+	}
+
+	// Notifications are dropped without a response, so the following call
+	// reads its own response.
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": "file:///tmp/project/main.go", "version": 1, "text": "b\na\n"},
+	})
+	for _, method := range []string{"textDocument/formatting", "initialize", "shutdown"} {
+		if respErr := c.call(method, map[string]any{}, nil); respErr == nil || respErr.Code != codeInvalidRequest {
+			t.Fatalf("expected %s after shutdown to be an invalid request, got %v", method, respErr)
+		}
+	}
+
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t, sortLines)
+	c.notify("exit", nil)
+	if err := <-c.done; !errors.Is(err, ErrExitWithoutShutdown) {
+		t.Fatalf("expected ErrExitWithoutShutdown, got %v", err)
+	}
+}
+
+func TestUriToPath(t *testing.T) {
+	t.Parallel()
+
+	got, err := uriToPath("file:///tmp/my%20project/main.go")
+	if err != nil {
+		t.Fatalf("uriToPath returned error: %v", err)
+	}
+	if want := filepath.FromSlash("/tmp/my project/main.go"); got != want {
+		t.Fatalf("uriToPath = %q, want %q", got, want)
+	}
+
+	if _, err := uriToPath("untitled:Untitled-1"); err == nil {
+		t.Fatalf("expected non-file URIs to be rejected")
+	}
+}
@@ -15,10 +15,21 @@ type nameCacheEntry struct {
 	err  error
 }
 
-var nameCache sync.Map // map[string]nameCacheEntry
+var (
+	nameCache sync.Map // map[string]nameCacheEntry
+	rootCache sync.Map // map[string]string, see RootOfDir
+)
 
+// ClearNameCache drops the cached module names and the cached module roots of
+// directories.
 func ClearNameCache() {
 	nameCache = sync.Map{}
+	rootCache = sync.Map{}
+}
+
+// ForgetName drops the cached module name of goModRootPath.
+func ForgetName(goModRootPath string) {
+	nameCache.Delete(goModRootPath)
 }
 
 func Name(goModRootPath string) (string, error) {
@@ -75,6 +86,22 @@ func GoModRootPath(path string) (string, error) {
 	return "", nil
 }
 
+// RootOfDir is like GoModRootPath for the directory dir, but caches the
+// result, so walking the files of a tree looks for go.mod files only once per
+// directory. dir must be absolute.
+func RootOfDir(dir string) (string, error) {
+	if cached, ok := rootCache.Load(dir); ok {
+		return cached.(string), nil
+	}
+
+	root, err := GoModRootPath(dir)
+	if err != nil {
+		return "", err
+	}
+	rootCache.Store(dir, root)
+	return root, nil
+}
+
 func DetermineProjectName(projectName, filePath string) (string, error) {
 	if projectName != "" {
 		return projectName, nil
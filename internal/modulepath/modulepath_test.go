@@ -2,6 +2,7 @@ package modulepath
 
 import (
 	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -23,3 +24,37 @@ func TestDetermineProjectNameReturnsUndefinedModuleWhenNoGoModRootExists(t *test
 		t.Fatalf("expected UndefinedModuleError, got %T: %v", err, err)
 	}
 }
+
+func TestRootOfDirResolvesNestedModulesAndCaches(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	nested := filepath.Join(root, "nested")
+	pkgDir := filepath.Join(nested, "pkg")
+	if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	for dir, name := range map[string]string{root: "example.com/root", nested: "example.com/nested"} {
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+name+"\n"), 0o644); err != nil {
+			t.Fatalf("failed to write go.mod: %v", err)
+		}
+	}
+
+	got, err := RootOfDir(pkgDir)
+	if err != nil {
+		t.Fatalf("RootOfDir returned error: %v", err)
+	}
+	if got != nested {
+		t.Fatalf("RootOfDir(%q) = %q, want %q", pkgDir, got, nested)
+	}
+
+	if err := os.Remove(filepath.Join(nested, "go.mod")); err != nil {
+		t.Fatalf("failed to remove go.mod: %v", err)
+	}
+	if got, err := RootOfDir(pkgDir); err != nil || got != nested {
+		t.Fatalf("RootOfDir(%q) = %q, %v, want cached %q", pkgDir, got, err, nested)
+	}
+	if got, err := RootOfDir(root); err != nil || got != root {
+		t.Fatalf("RootOfDir(%q) = %q, %v, want %q", root, got, err, root)
+	}
+}
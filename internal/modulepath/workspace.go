@@ -0,0 +1,194 @@
+package modulepath
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+)
+
+const goWorkFilename = "go.work"
+
+type workspaceCacheEntry struct {
+	modules []string
+	err     error
+}
+
+var workspaceCache sync.Map // map[string]workspaceCacheEntry
+
+// ClearWorkspaceCache drops every cached result of WorkspaceModules.
+func ClearWorkspaceCache() {
+	workspaceCache = sync.Map{}
+}
+
+// ForgetWorkspace drops the cached workspace modules of goModRootPath.
+func ForgetWorkspace(goModRootPath string) {
+	workspaceCache.Delete(goModRootPath)
+}
+
+// WorkFilePath returns the go.work file governing the module rooted at
+// goModRootPath, like the go command finds it: GOWORK names it, disables
+// workspaces when set to "off", or else it is searched from goModRootPath
+// upwards. It returns "" when there is none.
+func WorkFilePath(goModRootPath string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+
+	dir := filepath.Clean(goModRootPath)
+	for {
+		candidate := filepath.Join(dir, goWorkFilename)
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// WorkspaceModules returns the paths of the modules developed together with
+// the module rooted at goModRootPath: the modules used by its go.work file and
+// the modules that its go.mod or go.work replace with local directories. The
+// module itself is not included. The result is sorted and cached like Name.
+func WorkspaceModules(goModRootPath string) ([]string, error) {
+	if cached, ok := workspaceCache.Load(goModRootPath); ok {
+		entry := cached.(workspaceCacheEntry)
+		return entry.modules, entry.err
+	}
+
+	modules, err := workspaceModulesUncached(goModRootPath)
+	if err == nil {
+		workspaceCache.Store(goModRootPath, workspaceCacheEntry{modules: modules})
+	}
+	return modules, err
+}
+
+// LocalModuleDirs returns the directories of the modules that WorkspaceModules
+// lists, the module itself excluded. The result is sorted and not cached.
+func LocalModuleDirs(goModRootPath string) ([]string, error) {
+	modules, err := localModules(goModRootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, module := range modules {
+		if !slices.Contains(dirs, module.dir) {
+			dirs = append(dirs, module.dir)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs, nil
+}
+
+func workspaceModulesUncached(goModRootPath string) ([]string, error) {
+	modules, err := localModules(goModRootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, module := range modules {
+		if !slices.Contains(paths, module.path) {
+			paths = append(paths, module.path)
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// localModule is a module developed together with another one, found in dir.
+type localModule struct {
+	path string
+	dir  string
+}
+
+// localModules returns the modules used by the go.work file governing the
+// module rooted at goModRootPath and the modules that its go.mod or go.work
+// replace with local directories, in the order they are declared.
+func localModules(goModRootPath string) ([]localModule, error) {
+	own, err := Name(goModRootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []localModule
+	add := func(modulePath, dir string) {
+		if modulePath != "" && modulePath != own {
+			modules = append(modules, localModule{path: modulePath, dir: filepath.Clean(dir)})
+		}
+	}
+	addLocalReplaces := func(replaces []*modfile.Replace, baseDir string) {
+		for _, replace := range replaces {
+			if replace.New.Version == "" && modfile.IsDirectoryPath(replace.New.Path) {
+				dir := replace.New.Path
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(baseDir, dir)
+				}
+				add(replace.Old.Path, dir)
+			}
+		}
+	}
+
+	goModFile := filepath.Join(goModRootPath, goModFilename)
+	data, err := os.ReadFile(goModFile)
+	if err != nil {
+		return nil, err
+	}
+	modFile, err := modfile.Parse(goModFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	addLocalReplaces(modFile.Replace, goModRootPath)
+
+	if goWorkFile := WorkFilePath(goModRootPath); goWorkFile != "" {
+		data, err := os.ReadFile(goWorkFile)
+		if err != nil {
+			return nil, err
+		}
+		workFile, err := modfile.ParseWork(goWorkFile, data, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only a workspace that uses the module governs it.
+		workDir := filepath.Dir(goWorkFile)
+		var used []localModule
+		usesModule := false
+		for _, use := range workFile.Use {
+			dir := use.Path
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(workDir, dir)
+			}
+			if filepath.Clean(dir) == filepath.Clean(goModRootPath) {
+				usesModule = true
+				continue
+			}
+			name, err := Name(dir)
+			if err != nil {
+				// A used directory without a readable go.mod is skipped rather
+				// than failing every file of the workspace.
+				continue
+			}
+			used = append(used, localModule{path: name, dir: dir})
+		}
+		if usesModule {
+			for _, module := range used {
+				add(module.path, module.dir)
+			}
+			addLocalReplaces(workFile.Replace, workDir)
+		}
+	}
+
+	return modules, nil
+}
@@ -0,0 +1,75 @@
+package modulepath
+
+import (
+	"path/filepath"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestWorkspaceModules(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	ClearNameCache()
+	ClearWorkspaceCache()
+
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"go.work": "go 1.22\n\nuse (\n\t./app\n\t./lib\n\t./nomod\n)\n\nreplace example.com/patched => ./patched\n",
+		"app/go.mod": "module example.com/app\n\ngo 1.22\n\n" +
+			"replace example.com/local => ../local\n\n" +
+			"replace example.com/remote => example.com/fork v1.0.0\n",
+		"lib/go.mod":     "module example.com/lib\n\ngo 1.22\n",
+		"nomod/doc.go":   "package nomod\n",
+		"outside/go.mod": "module example.com/outside\n\ngo 1.22\n",
+	})
+
+	tests := map[string]struct {
+		goModRoot string
+		want      []string
+		wantDirs  []string
+	}{
+		"used module": {
+			goModRoot: filepath.Join(root, "app"),
+			want:      []string{"example.com/lib", "example.com/local", "example.com/patched"},
+			wantDirs:  []string{filepath.Join(root, "lib"), filepath.Join(root, "local"), filepath.Join(root, "patched")},
+		},
+		"module not used by the workspace": {
+			goModRoot: filepath.Join(root, "outside"),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := WorkspaceModules(tt.goModRoot)
+			if err != nil {
+				t.Fatalf("WorkspaceModules returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("WorkspaceModules mismatch (-want +got):\n%s", diff)
+			}
+
+			dirs, err := LocalModuleDirs(tt.goModRoot)
+			if err != nil {
+				t.Fatalf("LocalModuleDirs returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.wantDirs, dirs); diff != "" {
+				t.Fatalf("LocalModuleDirs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("GOWORK=off", func(t *testing.T) {
+		t.Setenv("GOWORK", "off")
+		ClearWorkspaceCache()
+
+		got, err := WorkspaceModules(filepath.Join(root, "app"))
+		if err != nil {
+			t.Fatalf("WorkspaceModules returned error: %v", err)
+		}
+		if diff := gocmp.Diff([]string{"example.com/local"}, got); diff != "" {
+			t.Fatalf("WorkspaceModules mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
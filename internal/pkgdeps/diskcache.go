@@ -0,0 +1,245 @@
+package pkgdeps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+)
+
+const (
+	diskCacheVersion  = "v1"
+	diskCacheFilePerm = 0o600
+	diskCacheDirPerm  = 0o700
+)
+
+var diskCacheDir atomic.Pointer[string]
+
+var localModulesStamps sync.Map // map[string]string, module root -> stamp of its local modules
+
+// diskCacheEntry is the persisted result of Load for a directory and build
+// tag. Key identifies the inputs it was computed from.
+type diskCacheEntry struct {
+	Key     string         `json:"key"`
+	Imports PackageImports `json:"imports"`
+}
+
+// SetCacheDir persists the results of Load in dir, so later processes can
+// skip the go/packages driver for directories whose inputs did not change.
+// An entry is keyed by the go.mod and go.sum of the enclosing module, the
+// go.work governing it, the files of the modules it is developed with, the
+// names and content of the Go files of the directory, the build tag and the
+// environment of the go command; a change to any of them replaces the entry
+// on the next Load. An empty dir disables persistence.
+func SetCacheDir(dir string) {
+	diskCacheDir.Store(&dir)
+}
+
+func cacheDirForDisk() string {
+	if dir := diskCacheDir.Load(); dir != nil {
+		return *dir
+	}
+	return ""
+}
+
+// loadPersisted serves Load from the disk cache when it is enabled and holds
+// an entry for the current inputs, and records the result of load otherwise.
+// Failures of the disk cache only cost a reload.
+func loadPersisted(dir, buildTag string, load func() (PackageImports, error)) (PackageImports, error) {
+	cacheDir := cacheDirForDisk()
+	if cacheDir == "" {
+		return load()
+	}
+
+	key, err := diskCacheKey(dir, buildTag)
+	if err != nil {
+		return load()
+	}
+	path := diskCacheFilePath(cacheDir, dir, buildTag)
+	if imports, ok := readDiskCacheEntry(path, key); ok {
+		return imports, nil
+	}
+
+	imports, err := load()
+	if err != nil {
+		return imports, err
+	}
+	_ = writeDiskCacheEntry(cacheDir, path, diskCacheEntry{Key: key, Imports: imports})
+	return imports, nil
+}
+
+func diskCacheFilePath(cacheDir, dir, buildTag string) string {
+	return filepath.Join(cacheDir, fmt.Sprintf("%016x", xxh3.HashString(dir+"\x00"+buildTag)))
+}
+
+// diskCacheKey digests the inputs of a Load of dir with buildTag.
+func diskCacheKey(dir, buildTag string) (string, error) {
+	h := xxh3.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			_, _ = h.WriteString(part)
+			_, _ = h.WriteString("\x00")
+		}
+	}
+	writeFile := func(path string) error {
+		content, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			write(path, "missing")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		write(path, fmt.Sprintf("%016x", xxh3.Hash(content)))
+		return nil
+	}
+
+	write(diskCacheVersion, dir, buildTag)
+	write(goenv.Environ()...)
+
+	root, err := modulepath.GoModRootPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if root != "" {
+		for _, name := range []string{"go.mod", "go.sum"} {
+			if err := writeFile(filepath.Join(root, name)); err != nil {
+				return "", err
+			}
+		}
+		if workFile := modulepath.WorkFilePath(root); workFile != "" {
+			for _, path := range []string{workFile, workFile + ".sum"} {
+				if err := writeFile(path); err != nil {
+					return "", err
+				}
+			}
+		}
+		stamp, err := localModulesStamp(root)
+		if err != nil {
+			return "", err
+		}
+		write(stamp)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := writeFile(filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64()), nil
+}
+
+// ForgetLocalModules drops the state of the modules developed together with
+// every module that keys the disk cache. Long-running processes call it before
+// each run, since they are not told when the files of those modules change.
+func ForgetLocalModules() {
+	localModulesStamps.Clear()
+}
+
+// localModulesStamp digests the paths, sizes and modification times of the
+// go.mod and Go files of the modules developed together with the module at
+// root, which go list reads from their directories instead of the module
+// cache. The stamp is computed once per root until ForgetModule,
+// ForgetLocalModules or ClearCache.
+func localModulesStamp(root string) (string, error) {
+	if cached, ok := localModulesStamps.Load(root); ok {
+		return cached.(string), nil
+	}
+
+	dirs, err := modulepath.LocalModuleDirs(root)
+	if err != nil {
+		return "", err
+	}
+
+	h := xxh3.New()
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir && errors.Is(err, fs.ErrNotExist) {
+					_, _ = fmt.Fprintf(h, "%s:-;", path)
+					return nil
+				}
+				return err
+			}
+
+			name := d.Name()
+			if d.IsDir() {
+				if path == dir {
+					return nil
+				}
+				if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				// Nested modules are not part of the module of dir.
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if name != "go.mod" && !strings.HasSuffix(name, ".go") {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(h, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	stamp := fmt.Sprintf("%016x", h.Sum64())
+	localModulesStamps.Store(root, stamp)
+	return stamp, nil
+}
+
+func readDiskCacheEntry(path, key string) (PackageImports, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry diskCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	return entry.Imports, true
+}
+
+func writeDiskCacheEntry(cacheDir, path string, entry diskCacheEntry) error {
+	if err := os.MkdirAll(cacheDir, diskCacheDirPerm); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, payload, diskCacheFilePerm)
+}
@@ -0,0 +1,123 @@
+package pkgdeps
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestLoadPersistsResultsOnDisk(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+	SetCacheDir(t.TempDir())
+	t.Cleanup(func() { SetCacheDir("") })
+
+	var callCount atomic.Int32
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
+		callCount.Add(1)
+		return PackageImports{"example.com/pkg": "pkg"}, nil
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "pkg")
+	testutil.WriteFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	testutil.WriteFile(t, filepath.Join(dir, "a.go"), "package pkg\n")
+
+	// load simulates a new process, which only has the disk cache.
+	load := func(buildTag string) {
+		t.Helper()
+
+		ClearCache()
+		imports, err := Load(dir, buildTag)
+		if err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+		if diff := gocmp.Diff(PackageImports{"example.com/pkg": "pkg"}, imports); diff != "" {
+			t.Fatalf("imports mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	steps := []struct {
+		name      string
+		change    func()
+		buildTag  string
+		wantCalls int32
+	}{
+		{name: "first load", change: func() {}, wantCalls: 1},
+		{name: "unchanged inputs", change: func() {}, wantCalls: 1},
+		{name: "other build tag", change: func() {}, buildTag: "integration", wantCalls: 2},
+		{name: "edited file", change: func() { testutil.WriteFile(t, filepath.Join(dir, "a.go"), "package pkg\n\nimport _ \"embed\"\n") }, wantCalls: 3},
+		{name: "new file", change: func() { testutil.WriteFile(t, filepath.Join(dir, "b.go"), "package pkg\n") }, wantCalls: 4},
+		{name: "edited go.mod", change: func() { testutil.WriteFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n\ngo 1.23\n") }, wantCalls: 5},
+		{name: "go.sum added", change: func() { testutil.WriteFile(t, filepath.Join(root, "go.sum"), "") }, wantCalls: 6},
+		{name: "unchanged again", change: func() {}, wantCalls: 6},
+		{name: "unrelated nested module", change: func() {
+			testutil.WriteFile(t, filepath.Join(root, "lib", "go.mod"), "module example.com/lib\n\ngo 1.22\n")
+		}, wantCalls: 6},
+		{name: "go.work added", change: func() { testutil.WriteFile(t, filepath.Join(root, "go.work"), "go 1.22\n\nuse (\n\t.\n\t./lib\n)\n") }, wantCalls: 7},
+		{name: "file of a used module added", change: func() { testutil.WriteFile(t, filepath.Join(root, "lib", "lib.go"), "package lib\n") }, wantCalls: 8},
+		{name: "file of a used module edited", change: func() { testutil.WriteFile(t, filepath.Join(root, "lib", "lib.go"), "package lib\n\nconst C = 1\n") }, wantCalls: 9},
+		{name: "GOWORK changed", change: func() { t.Setenv("GOWORK", "off") }, wantCalls: 10},
+		{name: "unchanged at last", change: func() {}, wantCalls: 10},
+	}
+	for _, step := range steps {
+		step.change()
+		load(step.buildTag)
+		if got := callCount.Load(); got != step.wantCalls {
+			t.Fatalf("%s: loader called %d times, want %d", step.name, got, step.wantCalls)
+		}
+	}
+}
+
+func TestForgetLocalModulesRefreshesDiskCacheKey(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	ClearCache()
+	t.Cleanup(ClearCache)
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+	SetCacheDir(t.TempDir())
+	t.Cleanup(func() { SetCacheDir("") })
+
+	var callCount atomic.Int32
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
+		callCount.Add(1)
+		return PackageImports{}, nil
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "app")
+	lib := filepath.Join(root, "lib")
+	testutil.WriteFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\ngo 1.22\n\nreplace example.com/lib => ../lib\n")
+	testutil.WriteFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	testutil.WriteFile(t, filepath.Join(lib, "go.mod"), "module example.com/lib\n\ngo 1.22\n")
+	testutil.WriteFile(t, filepath.Join(lib, "lib.go"), "package lib\n")
+
+	// A long-running process forgets the package when its files change, but
+	// is not told about the files of the replacing module.
+	reload := func(wantCalls int32) {
+		t.Helper()
+
+		Forget(dir)
+		if _, err := Load(dir, ""); err != nil {
+			t.Fatalf("Load returned error: %v", err)
+		}
+		if got := callCount.Load(); got != wantCalls {
+			t.Fatalf("loader called %d times, want %d", got, wantCalls)
+		}
+	}
+	reload(1)
+	reload(1)
+
+	testutil.WriteFile(t, filepath.Join(lib, "lib.go"), "package lib\n\nconst C = 1\n")
+	ForgetLocalModules()
+	reload(2)
+}
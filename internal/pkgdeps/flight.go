@@ -0,0 +1,54 @@
+package pkgdeps
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is a load shared by the concurrent callers asking for the same key.
+type flight[T any] struct {
+	ready chan struct{}
+	value T
+	err   error
+	// canceled reports that the load failed after the ctx of the caller
+	// running it was done.
+	canceled bool
+}
+
+// shareLoad runs load once for the concurrent callers of key, tracked in
+// flights. load must use the ctx of the caller running it. A caller stops
+// waiting when its own ctx is done. Failed loads are forgotten before their
+// waiters are released, and when a load failed because its caller was
+// canceled, waiters whose ctx is still live load again instead of returning
+// the cancellation of another caller.
+func shareLoad[T any](ctx context.Context, flights *sync.Map, key any, load func(ctx context.Context) (T, error)) (T, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		current, loaded := flights.LoadOrStore(key, &flight[T]{ready: make(chan struct{})})
+		f := current.(*flight[T])
+		if !loaded {
+			f.value, f.err = load(ctx)
+			if f.err != nil {
+				f.canceled = ctx.Err() != nil
+				flights.CompareAndDelete(key, f)
+			}
+			close(f.ready)
+			return f.value, f.err
+		}
+
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		if f.canceled && ctx.Err() == nil {
+			continue
+		}
+		return f.value, f.err
+	}
+}
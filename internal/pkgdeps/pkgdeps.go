@@ -1,6 +1,7 @@
 package pkgdeps
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -20,13 +21,7 @@ type cacheKey struct {
 
 var cache sync.Map // map[cacheKey]cacheEntry
 
-var calls sync.Map // map[cacheKey]*call
-
-type call struct {
-	ready   chan struct{}
-	imports PackageImports
-	err     error
-}
+var calls sync.Map // map[cacheKey]*flight[PackageImports]
 
 var loadFunc = loadUncached
 
@@ -36,9 +31,45 @@ type PackageImports map[string]string
 func ClearCache() {
 	cache = sync.Map{}
 	calls = sync.Map{}
+	stdCache = sync.Map{}
+	moduleCache = sync.Map{}
+	typedCalls = sync.Map{}
+	exportsCache = sync.Map{}
+	localModulesStamps = sync.Map{}
+}
+
+// Forget drops the cached imports and import usage of dir for every build
+// tag, so the next Load or TypedUsedImports of dir asks the go/packages driver
+// again, and the exported names of dir that MissingImports resolves
+// references against.
+func Forget(dir string) {
+	exportsCache.Delete(dir)
+
+	forget := func(key, _ any) bool {
+		if key.(cacheKey).dir == dir {
+			cache.Delete(key)
+			calls.Delete(key)
+			typedCalls.Delete(key)
+		}
+		return true
+	}
+	cache.Range(forget)
+	calls.Range(forget)
+	typedCalls.Range(forget)
 }
 
 func Load(dir, buildTag string) (PackageImports, error) {
+	return LoadContext(context.Background(), dir, buildTag)
+}
+
+// LoadContext is like Load, but stops waiting for the go/packages driver once
+// ctx is done. Results of canceled loads are not cached, and the cancellation
+// of one caller does not fail the others waiting for the same load.
+func LoadContext(ctx context.Context, dir, buildTag string) (PackageImports, error) {
+	if err := ctx.Err(); err != nil {
+		return PackageImports{}, err
+	}
+
 	key := cacheKey{dir: dir, buildTag: buildTag}
 
 	if cached, ok := cache.Load(key); ok {
@@ -46,34 +77,27 @@ func Load(dir, buildTag string) (PackageImports, error) {
 		return entry.imports, entry.err
 	}
 
-	callIface, loaded := calls.LoadOrStore(key, &call{ready: make(chan struct{})})
-	currentCall := callIface.(*call)
-	if !loaded {
-		imports, err := loadFunc(dir, buildTag)
+	imports, err := shareLoad(ctx, &calls, key, func(ctx context.Context) (PackageImports, error) {
+		imports, err := loadPersisted(dir, buildTag, func() (PackageImports, error) {
+			return loadFunc(ctx, dir, buildTag)
+		})
 		if err == nil {
 			cache.Store(key, cacheEntry{imports: imports})
 		}
-		currentCall.imports = imports
-		currentCall.err = err
-		close(currentCall.ready)
-		if err != nil {
-			calls.Delete(key)
-		}
-	} else {
-		<-currentCall.ready
-	}
-
-	if currentCall.err != nil {
-		return PackageImports{}, currentCall.err
+		return imports, err
+	})
+	if err != nil {
+		return PackageImports{}, err
 	}
-	return currentCall.imports, nil
+	return imports, nil
 }
 
-func loadUncached(dir, buildTag string) (PackageImports, error) {
+func loadUncached(ctx context.Context, dir, buildTag string) (PackageImports, error) {
 	cfg := &packages.Config{
-		Dir:   dir,
-		Tests: true,
-		Mode:  packages.NeedName | packages.NeedImports,
+		Context: ctx,
+		Dir:     dir,
+		Tests:   true,
+		Mode:    packages.NeedName | packages.NeedImports,
 	}
 
 	if buildTag != "" {
@@ -1,12 +1,14 @@
 package pkgdeps
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/go/packages"
 )
@@ -21,7 +23,7 @@ func TestLoadSingleflight(t *testing.T) {
 	ready := make(chan struct{})
 	proceed := make(chan struct{})
 
-	loadFunc = func(dir, buildTag string) (PackageImports, error) {
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
 		if callCount.Add(1) == 1 {
 			close(ready)
 		}
@@ -62,7 +64,7 @@ func TestLoadSingleflight(t *testing.T) {
 		t.Fatalf("expected single loader invocation, got %d", got)
 	}
 
-	loadFunc = func(dir, buildTag string) (PackageImports, error) {
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
 		callCount.Add(1)
 		return nil, fmt.Errorf("unexpected loader invocation")
 	}
@@ -79,6 +81,55 @@ func TestLoadSingleflight(t *testing.T) {
 	}
 }
 
+func TestLoadContextCancellationDoesNotFailOtherCallers(t *testing.T) {
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+
+	var callCount atomic.Int32
+	started := make(chan struct{})
+	loadFunc = func(ctx context.Context, dir, buildTag string) (PackageImports, error) {
+		if callCount.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return PackageImports{"example.com/pkg": "pkg"}, nil
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := LoadContext(firstCtx, "/tmp/test", "")
+		firstErr <- err
+	}()
+	<-started
+
+	secondResult := make(chan error, 1)
+	go func() {
+		imports, err := LoadContext(context.Background(), "/tmp/test", "")
+		if err == nil && imports["example.com/pkg"] != "pkg" {
+			err = fmt.Errorf("unexpected imports map: %v", imports)
+		}
+		secondResult <- err
+	}()
+
+	// Give the second caller a chance to wait for the first load.
+	time.Sleep(10 * time.Millisecond)
+	cancelFirst()
+
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled caller to fail with context.Canceled, got %v", err)
+	}
+	if err := <-secondResult; err != nil {
+		t.Fatalf("expected the live caller to load again, got %v", err)
+	}
+	if got := callCount.Load(); got != 2 {
+		t.Fatalf("expected the live caller to run its own load, got %d loader calls", got)
+	}
+}
+
 func TestLoadDoesNotCacheErrors(t *testing.T) {
 	ClearCache()
 
@@ -86,7 +137,7 @@ func TestLoadDoesNotCacheErrors(t *testing.T) {
 	t.Cleanup(func() { loadFunc = originalLoader })
 
 	var callCount atomic.Int32
-	loadFunc = func(dir, buildTag string) (PackageImports, error) {
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
 		if callCount.Add(1) == 1 {
 			return nil, fmt.Errorf("transient failure")
 		}
@@ -127,7 +178,7 @@ func TestLoadSeparatesBuildTags(t *testing.T) {
 	t.Cleanup(func() { loadFunc = originalLoader })
 
 	var callCount atomic.Int32
-	loadFunc = func(dir, buildTag string) (PackageImports, error) {
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
 		callCount.Add(1)
 		return PackageImports{buildTag: buildTag}, nil
 	}
@@ -153,6 +204,98 @@ func TestLoadSeparatesBuildTags(t *testing.T) {
 	}
 }
 
+func TestForgetReloadsOnlyThatDir(t *testing.T) {
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+
+	var callCount atomic.Int32
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
+		callCount.Add(1)
+		return PackageImports{dir: buildTag}, nil
+	}
+
+	for _, key := range []cacheKey{{"/tmp/a", ""}, {"/tmp/a", "custom"}, {"/tmp/b", ""}} {
+		if _, err := Load(key.dir, key.buildTag); err != nil {
+			t.Fatalf("unexpected error loading %v: %v", key, err)
+		}
+	}
+
+	Forget("/tmp/a")
+
+	for _, key := range []cacheKey{{"/tmp/a", ""}, {"/tmp/a", "custom"}, {"/tmp/b", ""}} {
+		if _, err := Load(key.dir, key.buildTag); err != nil {
+			t.Fatalf("unexpected error reloading %v: %v", key, err)
+		}
+	}
+
+	if got := callCount.Load(); got != 5 {
+		t.Fatalf("expected both build tags of the forgotten dir to reload, got %d loader calls", got)
+	}
+}
+
+func TestLoadContextCanceledWaiterReturnsPromptly(t *testing.T) {
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
+		close(started)
+		<-release
+		return PackageImports{"example.com/pkg": "pkg"}, nil
+	}
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		_, _ = Load("/tmp/test", "")
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadContext(ctx, "/tmp/test", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled waiter to return context.Canceled, got %v", err)
+	}
+
+	close(release)
+	<-leaderDone
+}
+
+func TestLoadContextDoesNotCacheCanceledLoads(t *testing.T) {
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+
+	var callCount atomic.Int32
+	loadFunc = func(ctx context.Context, dir, buildTag string) (PackageImports, error) {
+		callCount.Add(1)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return PackageImports{"example.com/pkg": "pkg"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadContext(ctx, "/tmp/test", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	imports, err := Load("/tmp/test", "")
+	if err != nil {
+		t.Fatalf("expected load after cancellation to succeed, got %v", err)
+	}
+	if imports["example.com/pkg"] != "pkg" {
+		t.Fatalf("unexpected imports map: %v", imports)
+	}
+}
+
 func TestCollectPackageErrorsReturnsJoinedPackageErrors(t *testing.T) {
 	t.Parallel()
 
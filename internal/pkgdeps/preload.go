@@ -0,0 +1,77 @@
+package pkgdeps
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Preload loads the packages of every directory below root with a single
+// call of the go/packages driver, instead of one per directory, and serves
+// later Loads of these directories without a build tag from the result.
+// Directories whose packages have errors, directories of nested modules and
+// Loads with a build tag are left to Load, which reports errors as usual. An
+// error is only returned when the driver itself failed.
+func Preload(ctx context.Context, root string) error {
+	byDir, err := preloadUncached(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	for dir, imports := range byDir {
+		key := cacheKey{dir: dir}
+		if _, loaded := cache.LoadOrStore(key, cacheEntry{imports: imports}); loaded {
+			continue
+		}
+		if cacheDir := cacheDirForDisk(); cacheDir != "" {
+			if diskKey, err := diskCacheKey(dir, ""); err == nil {
+				_ = writeDiskCacheEntry(cacheDir, diskCacheFilePath(cacheDir, dir, ""), diskCacheEntry{Key: diskKey, Imports: imports})
+			}
+		}
+	}
+	return nil
+}
+
+// preloadUncached returns the imports of the packages below root by
+// directory, like loadUncached returns them for a single directory.
+func preloadUncached(ctx context.Context, root string) (map[string]PackageImports, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     root,
+		Tests:   true,
+		Mode:    packages.NeedName | packages.NeedImports | packages.NeedFiles,
+	}
+
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	byDir := make(map[string]PackageImports)
+	failed := make(map[string]bool)
+	for _, pkg := range pkgs {
+		// The generated test main packages live in the build cache.
+		if strings.HasSuffix(pkg.ID, ".test") || len(pkg.GoFiles) == 0 {
+			continue
+		}
+
+		dir := filepath.Dir(pkg.GoFiles[0])
+		if len(pkg.Errors) > 0 {
+			failed[dir] = true
+			continue
+		}
+		if byDir[dir] == nil {
+			byDir[dir] = PackageImports{}
+		}
+		for imprt, dep := range pkg.Imports {
+			byDir[dir][imprt] = dep.Name
+		}
+	}
+
+	for dir := range failed {
+		delete(byDir, dir)
+	}
+	return byDir, nil
+}
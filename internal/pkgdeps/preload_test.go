@@ -0,0 +1,79 @@
+package pkgdeps
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestPreloadServesLoadsOfTheTree(t *testing.T) {
+	ClearCache()
+
+	originalLoader := loadFunc
+	t.Cleanup(func() { loadFunc = originalLoader })
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/m\n\ngo 1.22\n",
+		"a/a.go":      "package a\n\nimport \"strings\"\n\nvar A = strings.ToUpper\n",
+		"a/a_test.go": "package a_test\n\nimport \"testing\"\n\nfunc TestA(*testing.T) {}\n",
+		"b/b.go":      "package b\n\nimport alias \"example.com/m/a\"\n\nvar B = alias.A\n",
+		"broken/x.go": "package x\n\nimport \"fmt\"\n\nvar X = fmt.Sprint\n",
+		"broken/y.go": "package y\n",
+	}
+	testutil.WriteFiles(t, root, files)
+
+	if err := Preload(t.Context(), root); err != nil {
+		t.Fatalf("Preload returned error: %v", err)
+	}
+
+	errNotPreloaded := errors.New("not preloaded")
+	loadFunc = func(_ context.Context, dir, buildTag string) (PackageImports, error) {
+		return nil, errNotPreloaded
+	}
+
+	tests := map[string]struct {
+		dir      string
+		buildTag string
+		want     PackageImports
+		wantErr  error
+	}{
+		"package with external test": {
+			dir:  "a",
+			want: PackageImports{"strings": "strings", "testing": "testing"},
+		},
+		"package importing the module": {
+			dir:  "b",
+			want: PackageImports{"example.com/m/a": "a"},
+		},
+		"package with errors": {
+			dir:     "broken",
+			wantErr: errNotPreloaded,
+		},
+		"build tag": {
+			dir:      "a",
+			buildTag: "integration",
+			wantErr:  errNotPreloaded,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(filepath.Join(root, tt.dir), tt.buildTag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load returned error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("imports mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package pkgdeps
+
+import (
+	"cmp"
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+	"github.com/zchee/goimports-rereviser/v4/pkg/std"
+)
+
+// MissingImport is an import of a package that a file references without
+// importing it.
+type MissingImport struct {
+	Path string
+	Name string
+}
+
+// Spec returns the import spec of the missing import, naming the package
+// explicitly when its name differs from the last element of its path.
+func (m MissingImport) Spec() string {
+	if m.Name != importPathBase(m.Path) {
+		return m.Name + ` "` + m.Path + `"`
+	}
+	return `"` + m.Path + `"`
+}
+
+// Lower relevance wins when several packages could provide a reference.
+const (
+	relevanceStd = iota
+	relevanceModule
+	relevanceDependency
+)
+
+type candidate struct {
+	path      string
+	name      string
+	dir       string
+	relevance int
+}
+
+type modulePackages struct {
+	// importPaths maps package directories of the main module to their
+	// import path.
+	importPaths map[string]string
+	candidates  map[string][]candidate // by package name
+}
+
+// candidateIndex holds the std and module candidates of a directory by
+// package name. Both maps are shared between calls and must not be modified.
+type candidateIndex struct {
+	std    map[string][]candidate
+	module map[string][]candidate
+}
+
+// lookup returns a new slice of the candidates named name, which the caller
+// may reorder.
+func (idx candidateIndex) lookup(name string) []candidate {
+	return slices.Concat(idx.std[name], idx.module[name])
+}
+
+var (
+	stdCache     sync.Map // map[string]*flight[map[string][]candidate], keyed by GOROOT
+	moduleCache  sync.Map // map[string]*flight[*modulePackages], keyed by module root
+	exportsCache sync.Map // map[string]map[string]map[string]bool, dir -> package name -> exported names
+)
+
+// ForgetModule drops the cached packages of the module rooted at root, which
+// MissingImports resolves references against, and the state of the modules it
+// is developed with that keys the disk cache.
+func ForgetModule(root string) {
+	moduleCache.Delete(root)
+	localModulesStamps.Delete(root)
+}
+
+// MissingImports resolves the identifiers that f, the content of filename,
+// uses as package names in selector expressions without declaring or
+// importing them. Candidates are std packages, the packages of the enclosing
+// module and the packages of its dependencies that the module builds with.
+// A candidate must export every selector used with the identifier; ties are
+// broken in favor of std, then the module, then the shortest import path.
+// Unresolvable identifiers are left alone. Files with dot imports are not
+// resolved, since their unqualified names could come from anywhere.
+func MissingImports(ctx context.Context, filename string, f *ast.File, packageImports PackageImports) ([]MissingImport, error) {
+	refs := unresolvedReferences(f, packageImports)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Dir(filename)
+	for name := range packageLevelNames(dir, filepath.Base(filename), f.Name.Name) {
+		delete(refs, name)
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	candidates, importer, err := loadCandidates(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []MissingImport
+	for _, name := range slices.Sorted(maps.Keys(refs)) {
+		if c, ok := resolveReference(candidates.lookup(name), refs[name], importer); ok {
+			missing = append(missing, MissingImport{Path: c.path, Name: c.name})
+		}
+	}
+	return missing, nil
+}
+
+// unresolvedReferences maps the undeclared identifiers used as X in X.Sel to
+// the selectors used with them.
+//
+// Identifiers are resolved with the object resolution of go/parser rather
+// than go/types: a file that misses imports does not type-check, and the
+// types of its package and dependencies are not needed to tell local names
+// from package names. The parser only knows the scopes of f, so names declared
+// by other files of the package are left to packageLevelNames, and f must be
+// parsed without parser.SkipObjectResolution.
+func unresolvedReferences(f *ast.File, packageImports PackageImports) map[string]map[string]bool {
+	imported := make(map[string]bool, len(f.Imports))
+	for _, spec := range f.Imports {
+		importPath := strings.Trim(spec.Path.Value, `"`)
+		if spec.Name != nil {
+			if spec.Name.Name == "." {
+				return nil
+			}
+			imported[spec.Name.Name] = true
+			continue
+		}
+		name := packageImports[importPath]
+		if name == "" {
+			name = importPathBase(importPath)
+		}
+		imported[name] = true
+	}
+
+	refs := make(map[string]map[string]bool)
+	ast.Inspect(f, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok || ident.Obj != nil || imported[ident.Name] || ident.Name == "_" {
+			return true
+		}
+
+		if refs[ident.Name] == nil {
+			refs[ident.Name] = make(map[string]bool)
+		}
+		refs[ident.Name][sel.Sel.Name] = true
+		return true
+	})
+	return refs
+}
+
+// packageLevelNames returns the top-level names declared by the other files
+// of package pkgName in dir. Test files are only considered for test files.
+func packageLevelNames(dir, base, pkgName string) map[string]bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	isTest := strings.HasSuffix(base, "_test.go")
+	names := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == base || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if !isTest && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil || file.Name.Name != pkgName {
+			continue
+		}
+		for name := range declaredNames(file, false) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// declaredNames returns the names of the top-level functions, types,
+// variables and constants of file, only the exported ones when exportedOnly.
+func declaredNames(file *ast.File, exportedOnly bool) map[string]bool {
+	names := make(map[string]bool)
+	add := func(ident *ast.Ident) {
+		if !exportedOnly || ident.IsExported() {
+			names[ident.Name] = true
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				add(decl.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					add(spec.Name)
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						add(name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// loadCandidates returns the importable packages and the import path of the
+// package in dir.
+func loadCandidates(ctx context.Context, dir string) (candidateIndex, string, error) {
+	var candidates candidateIndex
+
+	tc, err := goenv.Active(ctx)
+	if err != nil {
+		return candidates, "", err
+	}
+	candidates.std, err = loadStdCandidates(ctx, tc.GoRoot)
+	if err != nil {
+		return candidates, "", err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return candidates, "", err
+	}
+	moduleRoot, err := modulepath.GoModRootPath(absDir)
+	if err != nil || moduleRoot == "" {
+		return candidates, "", nil
+	}
+
+	pkgs, err := loadModulePackages(ctx, moduleRoot)
+	if err != nil {
+		return candidates, "", err
+	}
+	candidates.module = pkgs.candidates
+
+	importer, ok := pkgs.importPaths[absDir]
+	if !ok {
+		// A directory without packages yet, e.g. the first file of a package.
+		if moduleName, err := modulepath.Name(moduleRoot); err == nil {
+			rel, _ := filepath.Rel(moduleRoot, absDir)
+			importer = path.Join(moduleName, filepath.ToSlash(rel))
+		}
+	}
+	return candidates, importer, nil
+}
+
+// loadStdCandidates returns the std packages by name, once per toolchain.
+func loadStdCandidates(ctx context.Context, goRoot string) (map[string][]candidate, error) {
+	return shareLoad(ctx, &stdCache, goRoot, func(context.Context) (map[string][]candidate, error) {
+		candidates := make(map[string][]candidate)
+		for importPath := range std.Packages() {
+			if isInternal(importPath) || strings.HasPrefix(importPath, "vendor/") {
+				continue
+			}
+			name := importPathBase(importPath)
+			candidates[name] = append(candidates[name], candidate{
+				path:      importPath,
+				name:      name,
+				dir:       filepath.Join(goRoot, "src", filepath.FromSlash(importPath)),
+				relevance: relevanceStd,
+			})
+		}
+		return candidates, nil
+	})
+}
+
+// loadModulePackages lists the packages of the module at root and of the
+// dependencies it builds with, once per module.
+func loadModulePackages(ctx context.Context, root string) (*modulePackages, error) {
+	return shareLoad(ctx, &moduleCache, root, func(ctx context.Context) (*modulePackages, error) {
+		return loadModulePackagesUncached(ctx, root)
+	})
+}
+
+func loadModulePackagesUncached(ctx context.Context, root string) (*modulePackages, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     root,
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedModule,
+	}
+	pkgs, err := packages.Load(cfg, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &modulePackages{
+		importPaths: make(map[string]string),
+		candidates:  make(map[string][]candidate),
+	}
+	for _, pkg := range pkgs {
+		// Std packages have no module and are covered by the std list.
+		if pkg.Module == nil || len(pkg.GoFiles) == 0 {
+			continue
+		}
+		dir := filepath.Dir(pkg.GoFiles[0])
+		relevance := relevanceDependency
+		if pkg.Module.Main {
+			relevance = relevanceModule
+			result.importPaths[dir] = pkg.PkgPath
+		}
+		if pkg.Name == "main" || strings.HasSuffix(pkg.PkgPath, ".test") || strings.HasSuffix(pkg.Name, "_test") {
+			continue
+		}
+		result.candidates[pkg.Name] = append(result.candidates[pkg.Name], candidate{
+			path:      pkg.PkgPath,
+			name:      pkg.Name,
+			dir:       dir,
+			relevance: relevance,
+		})
+	}
+	return result, nil
+}
+
+// resolveReference picks the most relevant candidate that the importer may
+// import and that exports every selector.
+func resolveReference(candidates []candidate, selectors map[string]bool, importer string) (candidate, bool) {
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(
+			cmp.Compare(a.relevance, b.relevance),
+			cmp.Compare(len(a.path), len(b.path)),
+			strings.Compare(a.path, b.path),
+		)
+	})
+
+	for _, c := range candidates {
+		if c.path == importer || !canImport(importer, c.path) {
+			continue
+		}
+		exported := packageExports(c.dir)[c.name]
+		if exported == nil {
+			continue
+		}
+		if !allExported(exported, selectors) {
+			continue
+		}
+		return c, true
+	}
+	return candidate{}, false
+}
+
+func allExported(exported, selectors map[string]bool) bool {
+	for sel := range selectors {
+		if !exported[sel] {
+			return false
+		}
+	}
+	return true
+}
+
+// packageExports returns the exported top-level names of the non-test files
+// in dir by package name. Build constraints are ignored, so names declared for
+// any platform count.
+func packageExports(dir string) map[string]map[string]bool {
+	if cached, ok := exportsCache.Load(dir); ok {
+		return cached.(map[string]map[string]bool)
+	}
+
+	exports := make(map[string]map[string]bool)
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		fset := token.NewFileSet()
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			if exports[file.Name.Name] == nil {
+				exports[file.Name.Name] = make(map[string]bool)
+			}
+			maps.Copy(exports[file.Name.Name], declaredNames(file, true))
+		}
+	}
+
+	exportsCache.Store(dir, exports)
+	return exports
+}
+
+// canImport applies the internal package rule of the go command.
+func canImport(importer, importPath string) bool {
+	var parent string
+	switch {
+	case importPath == "internal" || strings.HasPrefix(importPath, "internal/"):
+		return false
+	case strings.HasSuffix(importPath, "/internal"):
+		parent = strings.TrimSuffix(importPath, "/internal")
+	case strings.Contains(importPath, "/internal/"):
+		parent = importPath[:strings.LastIndex(importPath, "/internal/")]
+	default:
+		return true
+	}
+	return importer == parent || strings.HasPrefix(importer, parent+"/")
+}
+
+func isInternal(importPath string) bool {
+	return importPath == "internal" || strings.HasPrefix(importPath, "internal/") ||
+		strings.HasSuffix(importPath, "/internal") || strings.Contains(importPath, "/internal/")
+}
+
+// importPathBase returns the last element of importPath, skipping a major
+// version suffix like "/v2".
+func importPathBase(importPath string) string {
+	base := path.Base(importPath)
+	if isMajorVersion(base) {
+		if parent := path.Dir(importPath); parent != "." {
+			return path.Base(parent)
+		}
+	}
+	return base
+}
+
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
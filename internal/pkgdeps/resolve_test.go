@@ -0,0 +1,128 @@
+package pkgdeps
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestMissingImports(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	testutil.WriteFile(t, filepath.Join(dir, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	testutil.WriteFile(t, filepath.Join(dir, "sibling.go"), "package p\n\nvar local struct{ Value int }\n")
+	filename := filepath.Join(dir, "p.go")
+	src := `package p
+
+import (
+	"fmt"
+	str "strings"
+)
+
+func f(errors struct{ New int }) {
+	_ = errors.New
+	_ = local.Value
+	_ = filepath.Join(str.ToUpper("a"), fmt.Sprint(sort.Ints))
+	_ = unknown.Value
+}
+`
+	testutil.WriteFile(t, filename, src)
+
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	got, err := MissingImports(t.Context(), filename, f, nil)
+	if err != nil {
+		t.Fatalf("MissingImports returned error: %v", err)
+	}
+	want := []MissingImport{
+		{Path: "path/filepath", Name: "filepath"},
+		{Path: "sort", Name: "sort"},
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Errorf("missing imports mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMissingImportSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		imprt MissingImport
+		want  string
+	}{
+		"name matches path":       {imprt: MissingImport{Path: "path/filepath", Name: "filepath"}, want: `"path/filepath"`},
+		"name matches major path": {imprt: MissingImport{Path: "github.com/go-pg/pg/v9", Name: "pg"}, want: `"github.com/go-pg/pg/v9"`},
+		"name differs from path":  {imprt: MissingImport{Path: "gopkg.in/yaml.v3", Name: "yaml"}, want: `yaml "gopkg.in/yaml.v3"`},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.imprt.Spec(); got != tt.want {
+				t.Errorf("Spec() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanImport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		importer   string
+		importPath string
+		want       bool
+	}{
+		"public package":       {importer: "example.com/a", importPath: "example.com/b", want: true},
+		"internal of parent":   {importer: "example.com/m/cmd", importPath: "example.com/m/internal/x", want: true},
+		"internal of sibling":  {importer: "example.com/n", importPath: "example.com/m/internal/x", want: false},
+		"internal leaf":        {importer: "example.com/m", importPath: "example.com/m/internal", want: true},
+		"std internal package": {importer: "example.com/m", importPath: "internal/poll", want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := canImport(tt.importer, tt.importPath); got != tt.want {
+				t.Errorf("canImport(%q, %q) = %v, want %v", tt.importer, tt.importPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStdCandidatesIsShared(t *testing.T) {
+	t.Parallel()
+
+	goRoot := t.TempDir()
+	first, err := loadStdCandidates(t.Context(), goRoot)
+	if err != nil {
+		t.Fatalf("loadStdCandidates returned error: %v", err)
+	}
+	c := first["filepath"]
+	if len(c) != 1 || c[0].path != "path/filepath" || c[0].dir != filepath.Join(goRoot, "src", "path", "filepath") {
+		t.Fatalf("unexpected filepath candidates %+v", c)
+	}
+	if _, ok := first["poll"]; ok {
+		t.Fatalf("internal packages must not be candidates")
+	}
+
+	second, err := loadStdCandidates(t.Context(), goRoot)
+	if err != nil {
+		t.Fatalf("loadStdCandidates returned error: %v", err)
+	}
+	if reflect.ValueOf(first).UnsafePointer() != reflect.ValueOf(second).UnsafePointer() {
+		t.Fatalf("std candidates were built again for the same toolchain")
+	}
+}
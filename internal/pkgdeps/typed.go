@@ -0,0 +1,165 @@
+package pkgdeps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fileUsage maps the files of a package directory to the import paths each of
+// them uses.
+type fileUsage map[string]map[string]bool
+
+var typedCalls sync.Map // map[cacheKey]*flight[fileUsage]
+
+// TypedUsedImports reports which imports of filename, whose content is src,
+// are used, by type-checking its package with the build tag. Unlike
+// UsedImports it follows the scopes of the type checker, so package names
+// shadowed by local declarations and names brought in by dot imports are
+// told apart correctly. Blank imports and imports of packages that failed to
+// load are always used. ok is false when filename is not part of the loaded
+// package, e.g. because build constraints exclude it, in which case callers
+// should fall back to UsedImports.
+//
+// As long as src equals the file on disk, the usage of every file of the
+// package is computed once and cached like Load.
+func TypedUsedImports(ctx context.Context, filename string, src []byte, buildTag string) (used map[string]bool, ok bool, err error) {
+	filename, err = filepath.Abs(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	dir := filepath.Dir(filename)
+
+	var usage fileUsage
+	if onDisk, err := os.ReadFile(filename); err == nil && bytes.Equal(onDisk, src) {
+		usage, err = loadTypedUsage(ctx, dir, buildTag)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		usage, err = typeCheckUsage(ctx, dir, buildTag, map[string][]byte{filename: src})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	used, ok = usage[filename]
+	return used, ok, nil
+}
+
+func loadTypedUsage(ctx context.Context, dir, buildTag string) (fileUsage, error) {
+	key := cacheKey{dir: dir, buildTag: buildTag}
+	return shareLoad(ctx, &typedCalls, key, func(ctx context.Context) (fileUsage, error) {
+		return typeCheckUsage(ctx, dir, buildTag, nil)
+	})
+}
+
+// typeCheckUsage type-checks the packages of dir, including tests, with
+// overlay replacing the content of files.
+func typeCheckUsage(ctx context.Context, dir, buildTag string, overlay map[string][]byte) (fileUsage, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Tests:   true,
+		Overlay: overlay,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports |
+			packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+	}
+	if buildTag != "" {
+		cfg.BuildFlags = []string{fmt.Sprintf(`-tags=%s`, buildTag)}
+	}
+
+	pkgs, err := packages.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(fileUsage)
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			filename := pkg.Fset.Position(file.Package).Filename
+			if _, ok := usage[filename]; ok || !strings.HasSuffix(filename, ".go") {
+				continue
+			}
+			usage[filename] = usedImportsOf(file, pkg)
+		}
+	}
+	return usage, nil
+}
+
+// usedImportsOf decides the usage of the imports of file from the objects its
+// identifiers resolved to.
+func usedImportsOf(file *ast.File, pkg *packages.Package) map[string]bool {
+	used := make(map[string]bool, len(file.Imports))
+	pkgNames := make(map[*types.PkgName]string, len(file.Imports))
+	dotImports := make(map[*types.Package]string)
+	for _, spec := range file.Imports {
+		importPath := strings.Trim(spec.Path.Value, `"`)
+		if spec.Name != nil && spec.Name.Name == "_" {
+			used[importPath] = true
+			continue
+		}
+
+		var obj types.Object
+		if spec.Name != nil {
+			obj = pkg.TypesInfo.Defs[spec.Name]
+		} else {
+			obj = pkg.TypesInfo.Implicits[spec]
+		}
+		pkgName, ok := obj.(*types.PkgName)
+		if !ok || !importLoaded(pkg, importPath) {
+			used[importPath] = true
+			continue
+		}
+		if spec.Name != nil && spec.Name.Name == "." {
+			dotImports[pkgName.Imported()] = importPath
+			continue
+		}
+		pkgNames[pkgName] = importPath
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		switch obj := pkg.TypesInfo.Uses[ident].(type) {
+		case nil:
+		case *types.PkgName:
+			if importPath, ok := pkgNames[obj]; ok {
+				used[importPath] = true
+			}
+		default:
+			if importPath, ok := dotImports[obj.Pkg()]; ok {
+				used[importPath] = true
+			}
+		}
+		return true
+	})
+
+	return used
+}
+
+// importLoaded reports whether the package imported as importPath was loaded
+// without errors. The usage of imports of broken packages is not reliable.
+func importLoaded(pkg *packages.Package, importPath string) bool {
+	switch importPath {
+	case "C":
+		return false
+	case "unsafe":
+		return true
+	}
+	dep, ok := pkg.Imports[importPath]
+	return ok && len(dep.Errors) == 0
+}
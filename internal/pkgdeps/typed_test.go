@@ -0,0 +1,94 @@
+package pkgdeps
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestTypedUsedImports(t *testing.T) {
+	ClearCache()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.22\n",
+		"lib/lib.go":   "package library\n\nconst Name = \"lib\"\n",
+		"p/sibling.go": "package p\n\nvar printer = struct{ Println func(...any) }{}\n",
+	}
+	testutil.WriteFiles(t, root, files)
+
+	const onDisk = `package p
+
+import (
+	"fmt"
+	. "math"
+	. "strings"
+	_ "embed"
+
+	"example.com/m/lib"
+)
+
+func f() {
+	fmt := printer
+	fmt.Println(Pi, library.Name)
+}
+`
+	filename := filepath.Join(root, "p", "p.go")
+	testutil.WriteFile(t, filename, onDisk)
+
+	tests := map[string]struct {
+		src  string
+		want map[string]bool
+	}{
+		"file on disk": {
+			src:  onDisk,
+			want: map[string]bool{"math": true, "embed": true, "example.com/m/lib": true},
+		},
+		"edited content": {
+			src: `package p
+
+import (
+	"fmt"
+	"strings"
+)
+
+func f() {
+	fmt.Println(strings.ToUpper(""))
+}
+`,
+			want: map[string]bool{"fmt": true, "strings": true},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok, err := TypedUsedImports(t.Context(), filename, []byte(tt.src), "")
+			if err != nil {
+				t.Fatalf("TypedUsedImports returned error: %v", err)
+			}
+			if !ok {
+				t.Fatalf("TypedUsedImports did not type-check %s", filename)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("used imports mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("file excluded by build constraints", func(t *testing.T) {
+		excluded := filepath.Join(root, "p", "p_windows.go")
+		src := "package p\n\nimport \"fmt\"\n"
+		if runtime.GOOS == "windows" {
+			t.Skip("the file is part of the package on windows")
+		}
+		testutil.WriteFile(t, excluded, src)
+
+		if _, ok, err := TypedUsedImports(t.Context(), excluded, []byte(src), ""); err != nil || ok {
+			t.Fatalf("TypedUsedImports = ok %v, err %v, want no type-checked file", ok, err)
+		}
+	})
+}
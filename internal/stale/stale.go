@@ -0,0 +1,124 @@
+// Package stale forgets the package and module data cached by pkgdeps and
+// modulepath once the files it was derived from change, for processes that
+// outlive edits to go.mod and packages, like the daemon, the language server
+// and watch mode.
+package stale
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
+	"github.com/zchee/goimports-rereviser/v4/internal/pkgdeps"
+)
+
+// Tracker remembers the state of the files that cached package and module
+// data were derived from, so data whose files changed is forgotten.
+type Tracker struct {
+	mu      sync.Mutex
+	dirs    map[string]string // package dir -> stamp of its Go files
+	modules map[string]string // module root -> stamp of go.mod and go.sum
+}
+
+// NewTracker returns a Tracker that tracks no files yet.
+func NewTracker() *Tracker {
+	return &Tracker{
+		dirs:    make(map[string]string),
+		modules: make(map[string]string),
+	}
+}
+
+// Refresh forgets the cached data of the package of filename when its Go
+// files changed, and of every package of its module when go.mod, go.sum or
+// the go.work governing it changed, since the previous Refresh of a file of
+// the package or module. The state of the modules developed together with
+// the module, which are not tracked, is always forgotten. It must be called
+// before the data is used.
+func (t *Tracker) Refresh(filename string) {
+	pkgdeps.ForgetLocalModules()
+
+	dir := filepath.Dir(filename)
+	dirStamp := stampFiles(dir, goFiles(dir)...)
+
+	root, _ := modulepath.GoModRootPath(dir)
+	var moduleStamp string
+	if root != "" {
+		moduleStamp = stampFiles(root, "go.mod", "go.sum")
+		if workFile := modulepath.WorkFilePath(root); workFile != "" {
+			moduleStamp += stampFiles(filepath.Dir(workFile), filepath.Base(workFile))
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if root != "" {
+		if old, ok := t.modules[root]; ok && old != moduleStamp {
+			modulepath.ForgetName(root)
+			modulepath.ForgetWorkspace(root)
+			pkgdeps.ForgetModule(root)
+			for tracked := range t.dirs {
+				if tracked == root || strings.HasPrefix(tracked, root+string(filepath.Separator)) {
+					pkgdeps.Forget(tracked)
+					delete(t.dirs, tracked)
+				}
+			}
+		}
+		t.modules[root] = moduleStamp
+	}
+
+	if old, ok := t.dirs[dir]; ok && old != dirStamp {
+		pkgdeps.Forget(dir)
+	}
+	t.dirs[dir] = dirStamp
+}
+
+// Forget unconditionally forgets the cached data of the package of filename
+// and of its module, e.g. after filename was written, and the state of the
+// modules developed together with any module.
+func Forget(filename string) {
+	dir := filepath.Dir(filename)
+	pkgdeps.Forget(dir)
+	pkgdeps.ForgetLocalModules()
+
+	root, _ := modulepath.GoModRootPath(dir)
+	if root == "" {
+		return
+	}
+	modulepath.ForgetName(root)
+	modulepath.ForgetWorkspace(root)
+	pkgdeps.ForgetModule(root)
+}
+
+func goFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// stampFiles describes the names, sizes and modification times of the given
+// files of dir. Missing files are part of the stamp as well.
+func stampFiles(dir string, names ...string) string {
+	var b strings.Builder
+	for _, name := range names {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			fmt.Fprintf(&b, "%s:-;", name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", name, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String()
+}
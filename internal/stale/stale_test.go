@@ -0,0 +1,44 @@
+package stale
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/testutil"
+)
+
+func TestTrackerForgetsChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	pkg := filepath.Join(root, "pkg")
+	testutil.WriteFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n")
+	testutil.WriteFile(t, filepath.Join(root, "main.go"), "package main\n")
+	testutil.WriteFile(t, filepath.Join(pkg, "a.go"), "package pkg\n")
+
+	tracker := NewTracker()
+	tracker.Refresh(filepath.Join(root, "main.go"))
+	tracker.Refresh(filepath.Join(pkg, "a.go"))
+	pkgStamp := tracker.dirs[pkg]
+
+	// Adding a file to the package changes its stamp only.
+	testutil.WriteFile(t, filepath.Join(pkg, "b.go"), "package pkg\n")
+	tracker.Refresh(filepath.Join(pkg, "a.go"))
+	if tracker.dirs[pkg] == pkgStamp {
+		t.Fatalf("expected the stamp of %s to change", pkg)
+	}
+	if _, ok := tracker.dirs[root]; !ok {
+		t.Fatalf("expected %s to stay tracked", root)
+	}
+
+	// Changing go.mod forgets every package of the module.
+	testutil.WriteFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n\ngo 1.26\n")
+	tracker.Refresh(filepath.Join(pkg, "a.go"))
+	if diff := gocmp.Diff([]string{pkg}, slices.Collect(maps.Keys(tracker.dirs))); diff != "" {
+		t.Fatalf("tracked dirs mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,32 @@
+// Package testutil provides the fixture helpers shared by the tests of the
+// module.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// WriteFile writes content to path like os.WriteFile, creating the missing
+// parent directories, and fails t on errors.
+func WriteFile(t testing.TB, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// WriteFiles writes the contents of files below root, keyed by paths relative
+// to root in slash-separated form, see WriteFile.
+func WriteFiles(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		WriteFile(t, filepath.Join(root, filepath.FromSlash(name)), content)
+	}
+}
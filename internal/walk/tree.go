@@ -0,0 +1,164 @@
+package walk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// Options configures Walk.
+type Options struct {
+	// FollowSymlinks walks symbolic links to directories like the directories
+	// they point to. A directory that is reached again through another path is
+	// skipped, which also stops symlink loops.
+	FollowSymlinks bool
+	// MaxDepth limits how many levels of directories below the root are
+	// walked: 1 walks the root and its subdirectories, but not theirs. Zero
+	// means no limit.
+	MaxDepth int
+}
+
+// Walk walks the file tree rooted at root like filepath.WalkDir: fn is called
+// for root and every file and directory below it, may return filepath.SkipDir
+// or filepath.SkipAll, and is called a second time with the error when a
+// directory cannot be read. Entries are visited one at a time in lexical
+// order, so the order of the calls is deterministic. root is followed when it
+// is a symbolic link.
+//
+// Directories are read ahead concurrently: when a directory is visited, its
+// subdirectories are read in the background, so fn rarely waits for the file
+// system. A subdirectory that fn skips was read in vain, but its own
+// subdirectories are never read.
+func Walk(root string, opts Options, fn fs.WalkDirFunc) error {
+	w := &walker{
+		opts: opts,
+		fn:   fn,
+		sem:  make(chan struct{}, readAheadLimit()),
+	}
+	if opts.FollowSymlinks {
+		w.visited = make(map[string]struct{})
+	}
+	defer w.reads.Wait()
+
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, fs.FileInfoToDirEntry(info), 0, nil)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// readAheadLimit bounds the directories that are read concurrently.
+func readAheadLimit() int {
+	return max(4, runtime.GOMAXPROCS(0))
+}
+
+type walker struct {
+	opts Options
+	fn   fs.WalkDirFunc
+	// visited holds the resolved paths of the walked directories when
+	// symbolic links are followed.
+	visited map[string]struct{}
+	// sem limits the reads ahead, and reads tracks them, so that Walk never
+	// returns before they finished.
+	sem   chan struct{}
+	reads sync.WaitGroup
+}
+
+// dirRead is the result of reading a directory ahead of visiting it.
+type dirRead struct {
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+// readAhead starts reading the directory at path, or returns nil when too many
+// reads are in flight already, leaving the read to the visit.
+func (w *walker) readAhead(path string) *dirRead {
+	select {
+	case w.sem <- struct{}{}:
+	default:
+		return nil
+	}
+
+	r := &dirRead{done: make(chan struct{})}
+	w.reads.Go(func() {
+		defer func() { <-w.sem }()
+		r.entries, r.err = os.ReadDir(path)
+		close(r.done)
+	})
+	return r
+}
+
+func (w *walker) walk(path string, entry fs.DirEntry, depth int, read *dirRead) error {
+	if entry.IsDir() && w.visited != nil {
+		if realPath, err := filepath.EvalSymlinks(path); err == nil {
+			if _, ok := w.visited[realPath]; ok {
+				return nil
+			}
+			w.visited[realPath] = struct{}{}
+		}
+	}
+
+	if err := w.fn(path, entry, nil); err != nil || !entry.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && entry.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	var (
+		entries []fs.DirEntry
+		err     error
+	)
+	if read != nil {
+		<-read.done
+		entries, err = read.entries, read.err
+	} else {
+		entries, err = os.ReadDir(path)
+	}
+	if err != nil {
+		if err := w.fn(path, entry, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	reads := make([]*dirRead, len(entries))
+	for i, child := range entries {
+		childPath := filepath.Join(path, child.Name())
+		if w.opts.FollowSymlinks && child.Type()&fs.ModeSymlink != 0 {
+			// Broken links and links to files are visited as links.
+			if info, err := os.Stat(childPath); err == nil && info.IsDir() {
+				child = fs.FileInfoToDirEntry(info)
+				entries[i] = child
+			}
+		}
+		if child.IsDir() && (w.opts.MaxDepth == 0 || depth < w.opts.MaxDepth) {
+			reads[i] = w.readAhead(childPath)
+		}
+	}
+
+	for i, child := range entries {
+		if child.IsDir() && w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+			continue
+		}
+
+		if err := w.walk(filepath.Join(path, child.Name()), child, depth+1, reads[i]); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package walk
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -65,7 +66,12 @@ func IsGoToolIgnored(path string) bool {
 	return ok
 }
 
-func NewSubmitter(providedPool *pond.WorkerPool, threshold int) (func(func()), func()) {
+// NewSubmitter returns a submit function that runs tasks inline until
+// threshold tasks were seen and on a worker pool afterwards, and a wait
+// function that blocks until every submitted task finished. Tasks that have
+// not started when ctx is done are dropped, so wait drains queued work quickly
+// after cancellation.
+func NewSubmitter(ctx context.Context, providedPool *pond.WorkerPool, threshold int) (func(func()), func()) {
 	var (
 		pool        = providedPool
 		poolMu      sync.Mutex
@@ -80,7 +86,14 @@ func NewSubmitter(providedPool *pond.WorkerPool, threshold int) (func(func()), f
 
 	canCreatePool := providedPool == nil
 
-	submit := func(task func()) {
+	submit := func(fn func()) {
+		task := func() {
+			if ctx.Err() != nil {
+				return
+			}
+			fn()
+		}
+
 		poolMu.Lock()
 		currentPool := pool
 		poolMu.Unlock()
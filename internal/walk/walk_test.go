@@ -1,11 +1,16 @@
 package walk
 
 import (
+	"context"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync/atomic"
 	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
 )
 
 func TestIsDirUsesStatForUnreadableDirectory(t *testing.T) {
@@ -40,7 +45,7 @@ func TestIsDirUsesStatForUnreadableDirectory(t *testing.T) {
 func TestNewSubmitterWaitsForCreatedPoolTasks(t *testing.T) {
 	t.Parallel()
 
-	submit, wait := NewSubmitter(nil, 1)
+	submit, wait := NewSubmitter(context.Background(), nil, 1)
 
 	var completed atomic.Int32
 	const taskCount = 32
@@ -55,3 +60,140 @@ func TestNewSubmitterWaitsForCreatedPoolTasks(t *testing.T) {
 		t.Fatalf("wait returned before all tasks completed: got %d want %d", got, taskCount)
 	}
 }
+
+func TestNewSubmitterDropsTasksAfterCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	submit, wait := NewSubmitter(ctx, nil, 1)
+
+	var completed atomic.Int32
+	submit(func() {
+		completed.Add(1)
+	})
+	cancel()
+	for range 32 {
+		submit(func() {
+			completed.Add(1)
+		})
+	}
+	wait()
+
+	if got := completed.Load(); got != 1 {
+		t.Fatalf("tasks ran after cancellation: got %d want 1", got)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires privileges on Windows")
+	}
+
+	root := t.TempDir()
+	for _, name := range []string{"b.go", "a/a.go", "a/deep/d.go", "c/c.go", "skip/s.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+	// A link back to the root forms a loop, and a link to c reaches it twice.
+	if err := os.Symlink(root, filepath.Join(root, "a", "loop")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "c"), filepath.Join(root, "b-link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := map[string]struct {
+		opts Options
+		want []string
+	}{
+		"default": {
+			want: []string{".", "a", "a/a.go", "a/deep", "a/deep/d.go", "a/loop", "b-link", "b.go", "c", "c/c.go"},
+		},
+		"follow symlinks": {
+			opts: Options{FollowSymlinks: true},
+			want: []string{".", "a", "a/a.go", "a/deep", "a/deep/d.go", "b-link", "b-link/c.go", "b.go"},
+		},
+		"max depth": {
+			opts: Options{MaxDepth: 1},
+			want: []string{".", "a", "a/a.go", "a/loop", "b-link", "b.go", "c", "c/c.go"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			err := Walk(root, tt.opts, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() && entry.Name() == "skip" {
+					return filepath.SkipDir
+				}
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				got = append(got, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk returned error: %v", err)
+			}
+			if diff := gocmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("visited paths mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWalkKeepsLexicalOrderWhileReadingAhead(t *testing.T) {
+	t.Parallel()
+
+	// More directories than are read ahead at once, so that some of them are
+	// read when they are visited.
+	root := t.TempDir()
+	var want []string
+	for i := range 3 * readAheadLimit() {
+		dir := fmt.Sprintf("d%03d", i)
+		for _, name := range []string{"a.go", "sub/b.go"} {
+			path := filepath.Join(root, dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatalf("failed to write fixture: %v", err)
+			}
+		}
+		want = append(want, dir, dir+"/a.go", dir+"/sub", dir+"/sub/b.go")
+	}
+
+	var got []string
+	err := Walk(root, Options{}, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned error: %v", err)
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Errorf("visited paths mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,199 @@
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+)
+
+const (
+	// watchMask selects the events of a watched directory: files that were
+	// written or renamed into it, and directories that appeared in it.
+	// Watches of removed directories are dropped with IN_IGNORED.
+	watchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_ONLYDIR
+
+	readBufferSize = 64 * (unix.SizeofInotifyEvent + unix.NAME_MAX + 1)
+)
+
+type inotify struct {
+	fd         int
+	file       *os.File
+	includeDir func(string) bool
+	walk       internalwalk.Options
+	dirs       map[int32]watchedDir // watch descriptor -> directory
+	eventCh    chan string
+	errCh      chan error
+	done       chan struct{}
+}
+
+// watchedDir is a watched directory and its depth below the root it was
+// found in.
+type watchedDir struct {
+	path  string
+	depth int
+}
+
+func newNotifier(roots []string, opts Options) (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	n := &inotify{
+		fd: fd,
+		// A non-blocking descriptor is registered with the runtime poller, so
+		// closing the file interrupts a pending read.
+		file:       os.NewFile(uintptr(fd), "inotify"),
+		includeDir: opts.IncludeDir,
+		walk:       opts.Walk,
+		dirs:       make(map[int32]watchedDir),
+		eventCh:    make(chan string, 256),
+		errCh:      make(chan error, 1),
+		done:       make(chan struct{}),
+	}
+	for _, root := range roots {
+		if err := n.addTree(root, 0, false); err != nil {
+			_ = n.file.Close()
+			return nil, err
+		}
+	}
+
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) events() <-chan string { return n.eventCh }
+
+func (n *inotify) errors() <-chan error { return n.errCh }
+
+func (n *inotify) close() error {
+	close(n.done)
+	return n.file.Close()
+}
+
+// send reports path unless the notifier was closed.
+func (n *inotify) send(path string) {
+	select {
+	case n.eventCh <- path:
+	case <-n.done:
+	}
+}
+
+// addTree watches root, which lies depth levels below a watched root, and the
+// included directories below it. With emitFiles the Go files found in them
+// are reported, since they may have been written before the watch was added.
+func (n *inotify) addTree(root string, depth int, emitFiles bool) error {
+	opts := n.walk
+	if opts.MaxDepth > 0 && depth > 0 {
+		// Walk counts the depth from root, which the callback corrects.
+		opts.MaxDepth = max(opts.MaxDepth-depth, 1)
+	}
+
+	return internalwalk.Walk(root, opts, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories may vanish while they are walked.
+			if errors.Is(err, fs.ErrNotExist) && path != root {
+				return nil
+			}
+			return err
+		}
+
+		if !entry.IsDir() {
+			if emitFiles && strings.HasSuffix(path, ".go") {
+				n.send(path)
+			}
+			return nil
+		}
+		dirDepth := depth
+		if path != root {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			dirDepth += strings.Count(rel, string(filepath.Separator)) + 1
+		}
+		if n.walk.MaxDepth > 0 && dirDepth > n.walk.MaxDepth || !n.includeDir(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(n.fd, path, watchMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOSPC) {
+				return fmt.Errorf("failed to watch %s: inotify watch limit reached, raise fs.inotify.max_user_watches: %w", path, err)
+			}
+			if errors.Is(err, unix.ENOENT) && path != root {
+				return filepath.SkipDir
+			}
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		n.dirs[int32(wd)] = watchedDir{path: path, depth: dirDepth}
+		return nil
+	})
+}
+
+func (n *inotify) read() {
+	defer close(n.eventCh)
+
+	buf := make([]byte, readBufferSize)
+	for {
+		k, err := n.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				n.errCh <- fmt.Errorf("failed to read inotify events: %w", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= k; {
+			wd := int32(binary.NativeEndian.Uint32(buf[offset:]))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			offset += unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[offset:offset+nameLen]), "\x00")
+			offset += nameLen
+
+			if err := n.handle(wd, mask, name); err != nil {
+				n.errCh <- err
+				return
+			}
+		}
+	}
+}
+
+func (n *inotify) handle(wd int32, mask uint32, name string) error {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		slog.Warn("too many file events, some changes were missed")
+		return nil
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(n.dirs, wd)
+		return nil
+	}
+
+	dir, ok := n.dirs[wd]
+	if !ok || name == "" {
+		return nil
+	}
+	path := filepath.Join(dir.path, name)
+
+	switch {
+	case mask&unix.IN_ISDIR != 0:
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			return n.addTree(path, dir.depth+1, true)
+		}
+	case mask&(unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO) != 0:
+		if strings.HasSuffix(name, ".go") {
+			n.send(path)
+		}
+	}
+	return nil
+}
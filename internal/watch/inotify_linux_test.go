@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+)
+
+func TestRunReportsWrittenGoFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	excluded := filepath.Join(root, "excluded")
+	if err := os.Mkdir(excluded, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	batches := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, []string{root}, Options{
+			IncludeDir: func(dir string) bool { return dir != excluded },
+			Delay:      20 * time.Millisecond,
+		}, func(paths []string) { batches <- paths })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want context.Canceled", err)
+		}
+	})
+
+	// Run adds its watches asynchronously, so keep writing until the first
+	// event arrives.
+	main := filepath.Join(root, "main.go")
+	var first []string
+	for first == nil {
+		appendFile(t, main, "// edit\n")
+		select {
+		case first = <-batches:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if diff := gocmp.Diff([]string{main}, first); diff != "" {
+		t.Fatalf("batch mismatch (-want +got):\n%s", diff)
+	}
+
+	// Files of excluded directories and non-Go files are ignored, while Go
+	// files of new directories and atomically renamed files are reported.
+	appendFile(t, filepath.Join(excluded, "x.go"), "package x\n")
+	appendFile(t, filepath.Join(root, "README.md"), "# readme\n")
+	nested := filepath.Join(root, "pkg", "sub", "a.go")
+	if err := os.MkdirAll(filepath.Dir(nested), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	appendFile(t, nested, "package sub\n")
+	renamed := filepath.Join(root, "renamed.go")
+	tmp := filepath.Join(root, "renamed.tmp")
+	appendFile(t, tmp, "package p\n")
+	if err := os.Rename(tmp, renamed); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+
+	got := map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	for !got[nested] || !got[renamed] {
+		select {
+		case batch := <-batches:
+			for _, path := range batch {
+				got[path] = true
+			}
+		case <-deadline:
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	if got[filepath.Join(excluded, "x.go")] {
+		t.Fatalf("file of an excluded directory was reported: %v", got)
+	}
+}
+
+func TestRunAppliesWalkOptions(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	linked := t.TempDir()
+	if err := os.Symlink(linked, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	batches := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, []string{root}, Options{
+			Walk:  internalwalk.Options{FollowSymlinks: true, MaxDepth: 1},
+			Delay: 20 * time.Millisecond,
+		}, func(paths []string) { batches <- paths })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want context.Canceled", err)
+		}
+	})
+
+	// Files below the max depth, including those of a directory that appears
+	// at it, are written before the reported ones, so they would show up in
+	// the same or an earlier batch.
+	tooDeep := []string{filepath.Join(deep, "x.go"), filepath.Join(root, "a", "c", "y.go")}
+	linkedFile := filepath.Join(root, "link", "l.go")
+	shallow := filepath.Join(root, "a", "s.go")
+	got := map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	for !got[linkedFile] || !got[shallow] {
+		// Run adds its watches asynchronously, so keep writing until the
+		// events arrive.
+		appendFile(t, tooDeep[0], "// edit\n")
+		if err := os.MkdirAll(filepath.Dir(tooDeep[1]), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		appendFile(t, tooDeep[1], "// edit\n")
+		appendFile(t, linkedFile, "// edit\n")
+		appendFile(t, shallow, "// edit\n")
+		select {
+		case batch := <-batches:
+			for _, path := range batch {
+				got[path] = true
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	for _, path := range tooDeep {
+		if got[path] {
+			t.Fatalf("file below the max depth was reported: %v", got)
+		}
+	}
+}
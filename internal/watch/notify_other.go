@@ -0,0 +1,12 @@
+//go:build !linux
+
+package watch
+
+import (
+	"errors"
+	"runtime"
+)
+
+func newNotifier([]string, Options) (notifier, error) {
+	return nil, errors.New("watching files is not supported on " + runtime.GOOS)
+}
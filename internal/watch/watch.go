@@ -0,0 +1,130 @@
+// Package watch reports Go files that are written below directory trees.
+package watch
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/zeebo/xxh3"
+
+	internalwalk "github.com/zchee/goimports-rereviser/v4/internal/walk"
+)
+
+// DefaultDelay is how long Run waits for further events before it reports
+// the files written so far. Editors often save a file with several writes.
+const DefaultDelay = 100 * time.Millisecond
+
+// Options configures Run.
+type Options struct {
+	// IncludeDir reports whether the files of a directory, and the
+	// directories below it, are watched. Every directory is watched when nil.
+	IncludeDir func(dir string) bool
+	// IncludeFile reports whether a written Go file is reported. Every Go file
+	// is reported when nil.
+	IncludeFile func(path string) bool
+	// Walk configures how the directory trees are walked for directories to
+	// watch, like for the directory runs whose files are watched.
+	Walk internalwalk.Options
+	// Delay overrides DefaultDelay.
+	Delay time.Duration
+}
+
+// notifier delivers the paths of Go files that were written below the
+// watched directories, including the Go files of directories that appeared.
+type notifier interface {
+	events() <-chan string
+	errors() <-chan error
+	close() error
+}
+
+// Run watches the directory trees of roots until ctx is done and calls fn
+// with the Go files written since the previous call, once no event arrived for
+// the delay. fn is never called concurrently. Files whose content did not
+// change since fn last returned, such as the files fn fixed itself, are not
+// reported again.
+func Run(ctx context.Context, roots []string, opts Options, fn func(paths []string)) error {
+	if opts.IncludeDir == nil {
+		opts.IncludeDir = func(string) bool { return true }
+	}
+	if opts.IncludeFile == nil {
+		opts.IncludeFile = func(string) bool { return true }
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultDelay
+	}
+
+	n, err := newNotifier(roots, opts)
+	if err != nil {
+		return err
+	}
+	defer n.close()
+
+	return loop(ctx, n, opts, fn)
+}
+
+func loop(ctx context.Context, n notifier, opts Options, fn func(paths []string)) error {
+	var (
+		pending = make(map[string]struct{})
+		seen    = make(map[string]uint64) // path -> hash of the content after fn
+		timer   = time.NewTimer(opts.Delay)
+	)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-n.errors():
+			return err
+
+		case path, ok := <-n.events():
+			if !ok {
+				return nil
+			}
+			if opts.IncludeFile(path) {
+				pending[path] = struct{}{}
+				timer.Reset(opts.Delay)
+			}
+
+		case <-timer.C:
+			var changed []string
+			for path := range pending {
+				hash, ok := contentHash(path)
+				if !ok {
+					continue
+				}
+				if prev, ok := seen[path]; ok && prev == hash {
+					continue
+				}
+				changed = append(changed, path)
+			}
+			clear(pending)
+			if len(changed) == 0 {
+				continue
+			}
+
+			slices.Sort(changed)
+			fn(changed)
+
+			for _, path := range changed {
+				if hash, ok := contentHash(path); ok {
+					seen[path] = hash
+				} else {
+					delete(seen, path)
+				}
+			}
+		}
+	}
+}
+
+func contentHash(path string) (uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	return xxh3.Hash(data), true
+}
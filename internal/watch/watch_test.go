@@ -0,0 +1,147 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+type fakeNotifier struct {
+	eventCh chan string
+	errCh   chan error
+}
+
+func (n *fakeNotifier) events() <-chan string { return n.eventCh }
+
+func (n *fakeNotifier) errors() <-chan error { return n.errCh }
+
+func (n *fakeNotifier) close() error { return nil }
+
+// runLoop runs loop over a fake notifier and returns a function that sends
+// events to it, and a channel with every batch passed to fn. fn appends
+// "// fixed" to each file, like a fix that writes its result.
+func runLoop(t *testing.T, opts Options) (func(paths ...string), <-chan []string) {
+	t.Helper()
+
+	n := &fakeNotifier{eventCh: make(chan string), errCh: make(chan error)}
+	batches := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- loop(ctx, n, opts, func(paths []string) {
+			for _, path := range paths {
+				if err := appendString(path, "// fixed\n"); err != nil {
+					t.Error(err)
+				}
+			}
+			batches <- paths
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("loop returned %v, want context.Canceled", err)
+		}
+	})
+
+	send := func(paths ...string) {
+		for _, path := range paths {
+			n.eventCh <- path
+		}
+	}
+	return send, batches
+}
+
+func TestLoopDebouncesAndSkipsOwnWrites(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	excluded := filepath.Join(dir, "excluded.go")
+	for _, path := range []string{a, b, excluded} {
+		appendFile(t, path, "package p\n")
+	}
+
+	send, batches := runLoop(t, Options{
+		IncludeFile: func(path string) bool { return path != excluded },
+		Delay:       20 * time.Millisecond,
+	})
+
+	// Several events within the delay end up in a single sorted batch.
+	send(b, a, excluded, a)
+	if diff := gocmp.Diff([]string{a, b}, receive(t, batches)); diff != "" {
+		t.Fatalf("batch mismatch (-want +got):\n%s", diff)
+	}
+
+	// The writes of fn itself, and saves without changes, are not reported.
+	send(a, b)
+	appendFile(t, b, "// edited\n")
+	send(b)
+	if diff := gocmp.Diff([]string{b}, receive(t, batches)); diff != "" {
+		t.Fatalf("batch mismatch (-want +got):\n%s", diff)
+	}
+
+	// Deleted files are not reported.
+	if err := os.Remove(a); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	send(a)
+	select {
+	case batch := <-batches:
+		t.Fatalf("unexpected batch %v", batch)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLoopReturnsNotifierError(t *testing.T) {
+	t.Parallel()
+
+	n := &fakeNotifier{eventCh: make(chan string), errCh: make(chan error, 1)}
+	want := errors.New("watch failed")
+	n.errCh <- want
+
+	err := loop(t.Context(), n, Options{Delay: time.Millisecond}, func([]string) {
+		t.Error("fn must not be called")
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("loop returned %v, want %v", err, want)
+	}
+}
+
+func receive(t *testing.T, batches <-chan []string) []string {
+	t.Helper()
+
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a batch")
+		return nil
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := appendString(path, content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendString(path, content string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
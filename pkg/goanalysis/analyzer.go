@@ -1,6 +1,7 @@
 package goanalysis
 
 import (
+	"bytes"
 	"flag"
 	"go/ast"
 	"go/parser"
@@ -12,7 +13,10 @@ import (
 	"github.com/zchee/goimports-rereviser/v4/reviser"
 )
 
-const errMessage = "imports must be formatted"
+const (
+	errMessage = "imports must be formatted"
+	fixMessage = "Format imports"
+)
 
 func NewAnalyzer(flagSet *flag.FlagSet, localPkgPrefixes string, options ...reviser.SourceFileOption) *analysis.Analyzer {
 	return &analysis.Analyzer{
@@ -29,7 +33,18 @@ func run(localPkgPrefixes string, options ...reviser.SourceFileOption) func(pass
 	}
 
 	return func(pass *analysis.Pass) (any, error) {
-		inspect := func(formattedFile *ast.File, hasChanged bool) func(node ast.Node) bool {
+		inspect := func(formattedFile *ast.File, hasChanged bool, fix *analysis.SuggestedFix) func(node ast.Node) bool {
+			// Every diagnostic of a file shares the same whole-block fix, so it is
+			// attached to the first one only to keep drivers from applying it twice.
+			report := func(pos token.Pos) {
+				diagnostic := analysis.Diagnostic{Pos: pos, Message: errMessage}
+				if fix != nil {
+					diagnostic.SuggestedFixes = []analysis.SuggestedFix{*fix}
+					fix = nil
+				}
+				pass.Report(diagnostic)
+			}
+
 			return func(node ast.Node) bool {
 				file, ok := node.(*ast.File)
 				if !ok {
@@ -40,10 +55,7 @@ func run(localPkgPrefixes string, options ...reviser.SourceFileOption) func(pass
 				}
 
 				if len(file.Imports) != len(formattedFile.Imports) {
-					pass.Reportf(
-						file.Pos(),
-						errMessage,
-					)
+					report(file.Pos())
 				}
 
 				for i, originalDecl := range file.Decls {
@@ -57,10 +69,7 @@ func run(localPkgPrefixes string, options ...reviser.SourceFileOption) func(pass
 					}
 
 					if origDd != formattedFile.Decls[i] {
-						pass.Reportf(
-							file.Pos()+origDd.Lparen,
-							errMessage,
-						)
+						report(file.Pos() + origDd.Lparen)
 					}
 				}
 
@@ -81,7 +90,7 @@ func run(localPkgPrefixes string, options ...reviser.SourceFileOption) func(pass
 				}
 			}
 
-			formattedFileContent, _, hasChanged, err := reviser.NewSourceFile(projectName, filePath).Fix(options...)
+			formattedFileContent, originalContent, hasChanged, err := reviser.NewSourceFile(projectName, filePath).Fix(options...)
 			if err != nil {
 				return nil, err
 			}
@@ -90,14 +99,79 @@ func run(localPkgPrefixes string, options ...reviser.SourceFileOption) func(pass
 				continue
 			}
 
-			formattedFile, err := parser.ParseFile(token.NewFileSet(), filePath, formattedFileContent, parser.ImportsOnly)
+			formattedFset := token.NewFileSet()
+			formattedFile, err := parser.ParseFile(formattedFset, filePath, formattedFileContent, parser.ImportsOnly)
 			if err != nil {
 				panic(err)
 			}
 
-			ast.Inspect(f, inspect(formattedFile, hasChanged))
+			fix := suggestedFix(pass.Fset.File(f.Package), pass.Fset, f, originalContent, formattedFset, formattedFile, formattedFileContent)
+
+			ast.Inspect(f, inspect(formattedFile, hasChanged, fix))
 		}
 
 		return nil, nil
 	}
 }
+
+// suggestedFix returns a fix replacing the import block of original with the
+// import block of formatted. When formatting also changed code outside the
+// import block, the fix replaces the whole file instead. It returns nil when
+// the analyzed file no longer matches originalContent.
+func suggestedFix(
+	tokenFile *token.File,
+	originalFset *token.FileSet,
+	original *ast.File,
+	originalContent []byte,
+	formattedFset *token.FileSet,
+	formatted *ast.File,
+	formattedContent []byte,
+) *analysis.SuggestedFix {
+	if tokenFile == nil || tokenFile.Size() != len(originalContent) {
+		return nil
+	}
+
+	edit := analysis.TextEdit{
+		Pos:     tokenFile.Pos(0),
+		End:     tokenFile.Pos(len(originalContent)),
+		NewText: formattedContent,
+	}
+
+	origStart, origEnd, origOK := importBlockOffsets(originalFset, original)
+	fmtStart, fmtEnd, fmtOK := importBlockOffsets(formattedFset, formatted)
+	if origOK && fmtOK &&
+		bytes.Equal(originalContent[:origStart], formattedContent[:fmtStart]) &&
+		bytes.Equal(originalContent[origEnd:], formattedContent[fmtEnd:]) {
+		edit = analysis.TextEdit{
+			Pos:     tokenFile.Pos(origStart),
+			End:     tokenFile.Pos(origEnd),
+			NewText: formattedContent[fmtStart:fmtEnd],
+		}
+	}
+
+	return &analysis.SuggestedFix{
+		Message:   fixMessage,
+		TextEdits: []analysis.TextEdit{edit},
+	}
+}
+
+// importBlockOffsets returns the byte offsets spanning every import
+// declaration of file.
+func importBlockOffsets(fset *token.FileSet, file *ast.File) (int, int, bool) {
+	var (
+		start, end int
+		found      bool
+	)
+	for _, decl := range file.Decls {
+		dd, ok := decl.(*ast.GenDecl)
+		if !ok || dd.Tok != token.IMPORT {
+			continue
+		}
+		if !found {
+			start = fset.Position(dd.Pos()).Offset
+			found = true
+		}
+		end = fset.Position(dd.End()).Offset
+	}
+	return start, end, found
+}
@@ -178,3 +178,104 @@ func runAnalyzer(t *testing.T, source, localPkgPrefixes string, options ...revis
 
 	return diagnostics
 }
+
+func TestAnalyzerSuggestedFix(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		source        string
+		options       []reviser.SourceFileOption
+		wantEdit      string
+		wantWholeFile bool
+	}{
+		"fix replaces only the import block": {
+			source: `package sample
+
+import (
+	"example.com/project/internal/foo"
+	"fmt"
+)
+
+var _ = fmt.Println
+var _ = foo.Name
+`,
+			wantEdit: `import (
+	"fmt"
+
+	"example.com/project/internal/foo"
+)`,
+		},
+		"fix replaces the whole file when code formatting changes other lines": {
+			source: `package sample
+
+import (
+	"example.com/project/internal/foo"
+	"fmt"
+)
+
+var _ = fmt.Println
+var _   = foo.Name
+`,
+			options:       []reviser.SourceFileOption{reviser.WithCodeFormatting},
+			wantWholeFile: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/project\n\ngo 1.26\n"), 0o644); err != nil {
+				t.Fatalf("write go.mod: %v", err)
+			}
+			filePath := filepath.Join(dir, "sample.go")
+			if err := os.WriteFile(filePath, []byte(tt.source), 0o644); err != nil {
+				t.Fatalf("write sample.go: %v", err)
+			}
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, filePath, []byte(tt.source), parser.ParseComments)
+			if err != nil {
+				t.Fatalf("parse source: %v", err)
+			}
+
+			var fixes []analysis.SuggestedFix
+			analyzer := NewAnalyzer(flag.NewFlagSet(t.Name(), flag.ContinueOnError), "", tt.options...)
+			if _, err := analyzer.Run(&analysis.Pass{
+				Fset:  fset,
+				Files: []*ast.File{file},
+				Report: func(diagnostic analysis.Diagnostic) {
+					fixes = append(fixes, diagnostic.SuggestedFixes...)
+				},
+			}); err != nil {
+				t.Fatalf("run analyzer: %v", err)
+			}
+
+			if len(fixes) != 1 || len(fixes[0].TextEdits) != 1 {
+				t.Fatalf("expected a single suggested fix with one edit, got %+v", fixes)
+			}
+			edit := fixes[0].TextEdits[0]
+			if tt.wantEdit != "" {
+				if diff := gocmp.Diff(tt.wantEdit, string(edit.NewText)); diff != "" {
+					t.Errorf("edit text mismatch (-want +got):\n%s", diff)
+				}
+			}
+
+			start := fset.Position(edit.Pos).Offset
+			end := fset.Position(edit.End).Offset
+			if wholeFile := start == 0 && end == len(tt.source); wholeFile != tt.wantWholeFile {
+				t.Errorf("edit spans [%d:%d] of %d bytes, want whole file %t", start, end, len(tt.source), tt.wantWholeFile)
+			}
+
+			want, _, _, err := reviser.NewSourceFile("example.com/project", filePath).Fix(tt.options...)
+			if err != nil {
+				t.Fatalf("Fix returned error: %v", err)
+			}
+			got := tt.source[:start] + string(edit.NewText) + tt.source[end:]
+			if diff := gocmp.Diff(string(want), got); diff != "" {
+				t.Errorf("fixed source mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,7 +1,8 @@
 // Code generated by ./gen/gen.go DO NOT EDIT.
 package std
 
-// StdPackages is a set of go libs
+// StdPackages is a set of go libs. Packages falls back to it when the std
+// packages of the active Go toolchain cannot be resolved.
 var StdPackages = map[string]struct{}{
 	"archive/tar":                     {},
 	"archive/zip":                     {},
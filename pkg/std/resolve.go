@@ -0,0 +1,171 @@
+package std
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/atomicfile"
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+)
+
+const (
+	resolvedCacheFilePerm = 0o600
+	resolvedCacheDirPerm  = 0o700
+)
+
+// activePackages resolves the std packages of the active Go toolchain once
+// UseActiveToolchain enabled it.
+var activePackages atomic.Pointer[func() map[string]struct{}]
+
+// resolvedCacheEntry is a persisted result of Packages for a Go version.
+type resolvedCacheEntry struct {
+	Version  string   `json:"version"`
+	GoRoot   string   `json:"goroot"`
+	Packages []string `json:"packages"`
+}
+
+// UseActiveToolchain makes Packages return the std packages of the active Go
+// toolchain, the one 'go env' reports, found by scanning its GOROOT/src on the
+// first call to Packages. Unlike StdPackages, which is generated for the Go
+// release the tool was built with, they cover packages added by newer releases
+// and packages that only build with a GOEXPERIMENT. The result is persisted in
+// cacheDir, keyed by the version of the toolchain, so later processes skip
+// scanning GOROOT; an empty cacheDir disables persistence. Calling it again
+// resolves the packages anew.
+func UseActiveToolchain(cacheDir string) {
+	packages := sync.OnceValue(func() map[string]struct{} {
+		tc, err := goenv.Active(context.Background())
+		if err != nil {
+			return StdPackages
+		}
+		pkgs, err := resolve(tc, cacheDir)
+		if err != nil {
+			return StdPackages
+		}
+		return pkgs
+	})
+	activePackages.Store(&packages)
+}
+
+// Packages returns the std packages of the active Go toolchain when
+// UseActiveToolchain was called and the toolchain can be resolved, and
+// StdPackages otherwise, so library users never run the go command. The
+// result is shared and must not be modified.
+func Packages() map[string]struct{} {
+	if packages := activePackages.Load(); packages != nil {
+		return (*packages)()
+	}
+	return StdPackages
+}
+
+// IsStdPackage reports whether importPath is one of Packages.
+func IsStdPackage(importPath string) bool {
+	_, ok := Packages()[importPath]
+	return ok
+}
+
+// resolve returns the std packages of tc from the cache in cacheDir, and
+// scans its GOROOT and records the result otherwise. Failures of the cache
+// only cost a scan.
+func resolve(tc goenv.Toolchain, cacheDir string) (map[string]struct{}, error) {
+	var path string
+	if cacheDir != "" {
+		path = filepath.Join(cacheDir, fmt.Sprintf("std-%016x.json", xxh3.HashString(tc.Version)))
+		if pkgs, ok := readResolvedCacheEntry(path, tc); ok {
+			return pkgs, nil
+		}
+	}
+
+	pkgs, err := scanGoRoot(tc.GoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		entry := resolvedCacheEntry{Version: tc.Version, GoRoot: tc.GoRoot, Packages: slices.Sorted(maps.Keys(pkgs))}
+		_ = writeResolvedCacheEntry(cacheDir, path, entry)
+	}
+	return pkgs, nil
+}
+
+// scanGoRoot returns the import paths of the directories of goRoot/src that
+// hold non-test Go files, leaving out commands, the packages vendored by std
+// and the directories the go command ignores. Build constraints are not evaluated, so packages of every
+// platform and experiment are included.
+func scanGoRoot(goRoot string) (map[string]struct{}, error) {
+	src := filepath.Join(goRoot, "src")
+	cmd := filepath.Join(src, "cmd")
+	vendor := filepath.Join(src, "vendor")
+
+	pkgs := make(map[string]struct{})
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := d.Name()
+		if d.IsDir() {
+			if path != src && (path == cmd || path == vendor || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			pkgs[filepath.ToSlash(rel)] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", src, err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no std packages found in %s", src)
+	}
+	return pkgs, nil
+}
+
+func readResolvedCacheEntry(path string, tc goenv.Toolchain) (map[string]struct{}, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry resolvedCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil || entry.Version != tc.Version || entry.GoRoot != tc.GoRoot || len(entry.Packages) == 0 {
+		return nil, false
+	}
+
+	pkgs := make(map[string]struct{}, len(entry.Packages))
+	for _, pkg := range entry.Packages {
+		pkgs[pkg] = struct{}{}
+	}
+	return pkgs, true
+}
+
+func writeResolvedCacheEntry(cacheDir, path string, entry resolvedCacheEntry) error {
+	if err := os.MkdirAll(cacheDir, resolvedCacheDirPerm); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, payload, resolvedCacheFilePerm)
+}
@@ -0,0 +1,120 @@
+package std
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+
+	"github.com/zchee/goimports-rereviser/v4/internal/goenv"
+)
+
+func writeGoRoot(t *testing.T, files ...string) string {
+	t.Helper()
+
+	goRoot := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(goRoot, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package p\n"), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+	return goRoot
+}
+
+func TestScanGoRoot(t *testing.T) {
+	t.Parallel()
+
+	goRoot := writeGoRoot(t,
+		"fmt/print.go",
+		"fmt/testdata/fixture.go",
+		"iter/iter.go",
+		"simd/archsimd/ops_amd64.go",
+		"internal/onlytests/x_test.go",
+		"vendor/golang.org/x/net/dns/dnsmessage/message.go",
+		"cmd/go/main.go",
+		"_ignored/a.go",
+		".hidden/a.go",
+		"README",
+	)
+
+	got, err := scanGoRoot(goRoot)
+	if err != nil {
+		t.Fatalf("scanGoRoot returned error: %v", err)
+	}
+	want := []string{"fmt", "iter", "simd/archsimd"}
+	if diff := gocmp.Diff(want, slices.Sorted(maps.Keys(got))); diff != "" {
+		t.Fatalf("scanGoRoot mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := scanGoRoot(t.TempDir()); err == nil {
+		t.Fatalf("expected scanGoRoot of a directory without src to fail")
+	}
+}
+
+func TestResolveCachesByVersion(t *testing.T) {
+	t.Parallel()
+
+	goRoot := writeGoRoot(t, "fmt/print.go", "unique/handle.go")
+	cacheDir := filepath.Join(t.TempDir(), "std")
+	tc := goenv.Toolchain{GoRoot: goRoot, Version: "go1.99.0"}
+
+	want := map[string]struct{}{"fmt": {}, "unique": {}}
+	got, err := resolve(tc, cacheDir)
+	if err != nil {
+		t.Fatalf("resolve returned error: %v", err)
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("resolve mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := os.RemoveAll(filepath.Join(goRoot, "src")); err != nil {
+		t.Fatalf("failed to remove GOROOT/src: %v", err)
+	}
+	got, err = resolve(tc, cacheDir)
+	if err != nil {
+		t.Fatalf("cached resolve returned error: %v", err)
+	}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("cached resolve mismatch (-want +got):\n%s", diff)
+	}
+
+	tc.Version = "go1.100.0"
+	if _, err := resolve(tc, cacheDir); err == nil {
+		t.Fatalf("expected resolve of another Go version to scan GOROOT again")
+	}
+}
+
+func TestPackagesOfActiveToolchain(t *testing.T) {
+	t.Cleanup(func() { activePackages.Store(nil) })
+
+	activePackages.Store(nil)
+	if !IsStdPackage("fmt") {
+		t.Fatalf("IsStdPackage(%q) = false without the active toolchain, want true", "fmt")
+	}
+
+	cacheDir := t.TempDir()
+	UseActiveToolchain(cacheDir)
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 0 {
+		t.Fatalf("expected the std packages to be resolved on first use, found %d cache entries", len(entries))
+	}
+	for _, pkg := range []string{"fmt", "iter", "net/http"} {
+		if !IsStdPackage(pkg) {
+			t.Errorf("IsStdPackage(%q) = false, want true", pkg)
+		}
+	}
+	for _, pkg := range []string{"github.com/pkg/errors", "cmd/go", ""} {
+		if IsStdPackage(pkg) {
+			t.Errorf("IsStdPackage(%q) = true, want false", pkg)
+		}
+	}
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 1 {
+		t.Fatalf("expected the resolved std packages to be cached, found %d cache entries", len(entries))
+	}
+}
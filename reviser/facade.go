@@ -2,6 +2,7 @@ package reviser
 
 import (
 	internalengine "github.com/zchee/goimports-rereviser/v4/internal/engine"
+	"github.com/zchee/goimports-rereviser/v4/internal/modulepath"
 )
 
 const (
@@ -22,6 +23,15 @@ const (
 	BlankedImportsOrder = internalengine.BlankedImportsOrder
 	// DottedImportsOrder is separate group for "." imports.
 	DottedImportsOrder = internalengine.DottedImportsOrder
+	// WorkspaceImportsOrder is packages of the other modules of the go.work workspace.
+	WorkspaceImportsOrder = internalengine.WorkspaceImportsOrder
+
+	// SkipReasonGenerated is set for generated files when WithSkipGeneratedFile is used.
+	SkipReasonGenerated = internalengine.SkipReasonGenerated
+	// SkipReasonCgoOnly is set for files whose only import is "C".
+	SkipReasonCgoOnly = internalengine.SkipReasonCgoOnly
+	// SkipReasonCacheHit is set when the cache proved the file is already formatted.
+	SkipReasonCacheHit = internalengine.SkipReasonCacheHit
 )
 
 // ErrPathIsNotDir is returned when SourceDir is configured with a non-directory path.
@@ -38,10 +48,30 @@ type (
 	ImportsOrder = internalengine.ImportsOrder
 	// ImportsOrders alias to []ImportsOrder.
 	ImportsOrders = internalengine.ImportsOrders
+	// ImportGroup is a user-named import group matched by path patterns.
+	ImportGroup = internalengine.ImportGroup
+	// ImportAlias requires imports of a path to be named with an alias.
+	ImportAlias = internalengine.ImportAlias
+	// ImportRewrite replaces an import path prefix with another one.
+	ImportRewrite = internalengine.ImportRewrite
+	// PathRewrite records an import path replaced by an ImportRewrite.
+	PathRewrite = internalengine.PathRewrite
+	// ImportRule bans imports or restricts the packages that may import them.
+	ImportRule = internalengine.ImportRule
+	// ImportViolation is an import that breaks an ImportRule.
+	ImportViolation = internalengine.ImportViolation
 	// SourceDir validates and fixes imports under a directory.
 	SourceDir = internalengine.SourceDir
 	// UnformattedCollection is a collection of paths that require formatting.
 	UnformattedCollection = internalengine.UnformattedCollection
+	// Result is the outcome of SourceFile.FixResult.
+	Result = internalengine.Result
+	// SkipReason explains why a file was left untouched without being revised.
+	SkipReason = internalengine.SkipReason
+	// ImportChanges lists the import-level edits applied to a file.
+	ImportChanges = internalengine.ImportChanges
+	// AliasChange describes an import whose explicit package name changed.
+	AliasChange = internalengine.AliasChange
 	// CacheEntry represents the cached state of a file.
 	// Hash is always recorded; Size and ModTime are optional and only persisted
 	// when metadata-aware caching is enabled.
@@ -53,16 +83,83 @@ func NewSourceFile(projectName, filePath string) *SourceFile {
 	return internalengine.NewSourceFile(projectName, filePath)
 }
 
+// NewSourceFileFromBytes creates a SourceFile that fixes src instead of
+// reading filePath from disk or from stdin. filePath is still used for module
+// and build tag resolution.
+func NewSourceFileFromBytes(projectName, filePath string, src []byte) *SourceFile {
+	return internalengine.NewSourceFileFromBytes(projectName, filePath, src)
+}
+
+// FixSource revises the imports of src as if it were the content of filename,
+// without touching the filesystem or reading stdin. When projectName is
+// empty it is resolved from the go.mod enclosing filename. It returns the
+// fixed content and whether it differs from src; unchanged content may be src
+// itself. FixSource is safe for concurrent use.
+func FixSource(projectName, filename string, src []byte, options ...SourceFileOption) ([]byte, bool, error) {
+	if projectName == "" {
+		var err error
+		projectName, err = modulepath.DetermineProjectName("", filename)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	content, _, changed, err := internalengine.NewSourceFileFromBytes(projectName, filename, src).Fix(options...)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, changed, nil
+}
+
 // WithRemovingUnusedImports is an option to remove unused imports.
 func WithRemovingUnusedImports(f *SourceFile) error {
 	return internalengine.WithRemovingUnusedImports(f)
 }
 
+// WithTypeCheckedUnusedImports makes WithRemovingUnusedImports type-check the package to find unused imports.
+func WithTypeCheckedUnusedImports(f *SourceFile) error {
+	return internalengine.WithTypeCheckedUnusedImports(f)
+}
+
+// WithAddingMissingImports is an option to import the packages of unresolved references.
+func WithAddingMissingImports(f *SourceFile) error {
+	return internalengine.WithAddingMissingImports(f)
+}
+
 // WithUsingAliasForVersionSuffix is an option to set explicit package name in imports.
 func WithUsingAliasForVersionSuffix(f *SourceFile) error {
 	return internalengine.WithUsingAliasForVersionSuffix(f)
 }
 
+// WithRemovingRedundantAliases is an option to remove aliases that equal the package name.
+func WithRemovingRedundantAliases(f *SourceFile) error {
+	return internalengine.WithRemovingRedundantAliases(f)
+}
+
+// WithRequiredAliases names the imports of the given paths with the given
+// aliases and rewrites their uses.
+func WithRequiredAliases(aliases []ImportAlias) SourceFileOption {
+	return internalengine.WithRequiredAliases(aliases)
+}
+
+// WithImportRewrites replaces import path prefixes, keeping the comments of
+// the imports.
+func WithImportRewrites(rewrites []ImportRewrite) SourceFileOption {
+	return internalengine.WithImportRewrites(rewrites)
+}
+
+// WithImportRules reports the imports that break rules in Result.Violations,
+// replacing them when a rule has a replacement.
+func WithImportRules(rules []ImportRule) SourceFileOption {
+	return internalengine.WithImportRules(rules)
+}
+
+// WithWorkspaceModules classifies imports of the other modules of the go.work
+// workspace, and of modules replaced with local directories, like the project.
+func WithWorkspaceModules(f *SourceFile) error {
+	return internalengine.WithWorkspaceModules(f)
+}
+
 // WithCodeFormatting use to format the code.
 func WithCodeFormatting(f *SourceFile) error {
 	return internalengine.WithCodeFormatting(f)
@@ -80,6 +177,12 @@ func WithImportsOrder(orders []ImportsOrder) SourceFileOption {
 	return internalengine.WithImportsOrder(orders)
 }
 
+// WithImportGroups adds user-named import groups matched by path patterns.
+// A group is only applied when its name is part of the imports order.
+func WithImportGroups(groups []ImportGroup) SourceFileOption {
+	return internalengine.WithImportGroups(groups)
+}
+
 // WithSkipGeneratedFile will skip formatting and imports sorting for
 // auto-generated files.
 func WithSkipGeneratedFile(f *SourceFile) error {
@@ -104,6 +207,39 @@ func StringToImportsOrders(s string) (ImportsOrders, error) {
 	return internalengine.StringToImportsOrders(s)
 }
 
+// StringToImportsOrdersWithGroups is like StringToImportsOrders, but also
+// accepts the names of the given custom import groups.
+func StringToImportsOrdersWithGroups(s string, groups []ImportGroup) (ImportsOrders, error) {
+	return internalengine.StringToImportsOrdersWithGroups(s, groups)
+}
+
+// StringToImportGroups converts a string, like
+// "k8s=k8s.io/...,sigs.k8s.io/...;gen=re:/gen/", into ImportGroups.
+func StringToImportGroups(s string) ([]ImportGroup, error) {
+	return internalengine.StringToImportGroups(s)
+}
+
+// StringToImportAliases converts a string, like
+// "corev1=k8s.io/api/core/v1,metav1=k8s.io/apimachinery/pkg/apis/meta/v1",
+// into ImportAliases.
+func StringToImportAliases(s string) ([]ImportAlias, error) {
+	return internalengine.StringToImportAliases(s)
+}
+
+// StringToImportRewrites converts a string, like
+// "github.com/pkg/errors => errors,example.com/lib/v2 => example.com/lib/v3 lib",
+// into ImportRewrites.
+func StringToImportRewrites(s string) ([]ImportRewrite, error) {
+	return internalengine.StringToImportRewrites(s)
+}
+
+// StringToImportRules converts a string, like
+// "github.com/pkg/errors => errors;unsafe only pkg/lowlevel/...", into
+// ImportRules.
+func StringToImportRules(s string) ([]ImportRule, error) {
+	return internalengine.StringToImportRules(s)
+}
+
 // NewSourceDir constructor.
 func NewSourceDir(projectName, path string, isRecursive bool, excludes string) *SourceDir {
 	return internalengine.NewSourceDir(projectName, path, isRecursive, excludes)
@@ -1,6 +1,11 @@
 package reviser_test
 
 import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/zchee/goimports-rereviser/v4/reviser"
@@ -27,3 +32,74 @@ func TestFacadeExportsCompile(t *testing.T) {
 		t.Fatal("ComputeContentHash returned empty hash")
 	}
 }
+
+func TestFixSourceDoesNotTouchFilesystem(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/project\n\ngo 1.26\n"), 0o644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+	// The file is never written; only its directory is used for module resolution.
+	filename := filepath.Join(dir, "internal", "sample", "sample.go")
+
+	src := []byte(`package sample
+
+import (
+	"example.com/project/internal/foo"
+	"fmt"
+)
+
+var _ = fmt.Println
+var _ = foo.Name
+`)
+	want := `package sample
+
+import (
+	"fmt"
+
+	"example.com/project/internal/foo"
+)
+
+var _ = fmt.Println
+var _ = foo.Name
+`
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			got, changed, err := reviser.FixSource("", filename, src)
+			if err != nil {
+				t.Errorf("FixSource returned error: %v", err)
+				return
+			}
+			if !changed {
+				t.Errorf("expected FixSource to report a change")
+			}
+			if string(got) != want {
+				t.Errorf("FixSource output mismatch\nwant:\n%s\n got:\n%s", want, got)
+			}
+		})
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(filename); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected FixSource not to create %s, got %v", filename, err)
+	}
+}
+
+func TestFixSourceWithStandardInputNameDoesNotReadStdin(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("package sample\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\nvar _ = fmt.Println\nvar _ = os.Exit\n")
+	got, changed, err := reviser.FixSource("example.com/project", reviser.StandardInput, src)
+	if err != nil {
+		t.Fatalf("FixSource returned error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected FixSource to report a change")
+	}
+	if want := "package sample\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar _ = fmt.Println\nvar _ = os.Exit\n"; string(got) != want {
+		t.Fatalf("FixSource output mismatch\nwant:\n%s\n got:\n%s", want, got)
+	}
+}